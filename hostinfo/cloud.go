@@ -0,0 +1,141 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hostinfo
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"tailscale.com/syncs"
+	"tailscale.com/types/opt"
+	"tailscale.com/util/cloudenv"
+)
+
+// dmiInfo are the SMBIOS (DMI) identification strings of the machine.
+type dmiInfo struct {
+	BIOSVendor      string // "Amazon EC2", "Google", "Microsoft Corporation"
+	SysVendor       string // system manufacturer
+	ProductName     string // "Google Compute Engine", "Virtual Machine"
+	ChassisAssetTag string
+}
+
+// azureAssetTag is the chassis asset tag of every Azure VM, which
+// distinguishes them from other Hyper-V VMs.
+const azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+
+// offlineCloud guesses which cloud we're on from DMI and the CPUID
+// hypervisor bit alone, without any network access.
+//
+// If it can't tell, it returns the empty string with maybe reporting
+// whether asking the metadata server could: that is, the DMI strings
+// are ambiguous (such as a Hyper-V VM that might be Azure) and we're
+// not known to be on bare metal.
+func offlineCloud() (c cloudenv.Cloud, maybe bool) {
+	var d dmiInfo
+	if osDMI != nil {
+		d = osDMI()
+	}
+	var hv opt.Bool
+	if hypervisor != nil {
+		hv = hypervisor()
+	}
+	return guessCloud(d, hv)
+}
+
+// guessCloud is the pure logic of offlineCloud, given the DMI strings
+// and hypervisor bit.
+func guessCloud(d dmiInfo, hv opt.Bool) (c cloudenv.Cloud, maybe bool) {
+	switch {
+	case d.BIOSVendor == "Amazon EC2", strings.HasSuffix(d.BIOSVendor, ".amazon"), d.SysVendor == "Amazon EC2":
+		return cloudenv.AWS, false // including bare metal instances
+	case d.ProductName == "Google Compute Engine":
+		return cloudenv.GCP, false
+	case d.ChassisAssetTag == azureAssetTag:
+		return cloudenv.Azure, false
+	}
+	if virtual, ok := hv.Get(); ok && !virtual {
+		return "", false // bare metal; no metadata server to ask
+	}
+	// Old GCP VMs just say "Google"; Azure VMs without the asset tag
+	// look like any other Hyper-V VM.
+	ambiguous := d.ProductName == "Google" ||
+		d.ProductName == "Virtual Machine" ||
+		d.BIOSVendor == "Microsoft Corporation"
+	return "", ambiguous
+}
+
+// cloudenvGet is cloudenv.Get, but can be replaced by tests.
+var cloudenvGet = cloudenv.Get
+
+// cloudenvCached is cloudenv.Cached, but can be replaced by tests.
+var cloudenvCached = cloudenv.Cached
+
+// refreshedCloud is the cloud found by RefreshCloudMetadata, if it's
+// been called.
+var refreshedCloud syncs.AtomicValue[cloudenv.Cloud]
+
+// cachedOfflineCloud is the result of offlineCloud, which doesn't
+// change while we're running. It's a pointer so tests can reset it.
+var cachedOfflineCloud = new(offlineCloudResult)
+
+type offlineCloudResult struct {
+	once  sync.Once
+	c     cloudenv.Cloud
+	maybe bool
+}
+
+// offlineCloudCached returns offlineCloud's result, computing it only
+// the first time, so that New doesn't reread the DMI strings and CPUID
+// on every call.
+func offlineCloudCached() (c cloudenv.Cloud, maybe bool) {
+	r := cachedOfflineCloud
+	r.once.Do(func() { r.c, r.maybe = offlineCloud() })
+	return r.c, r.maybe
+}
+
+// RefreshCloudMetadata refines the cloud that New reports by asking
+// the cloud's link-local metadata server, for callers willing to take
+// the network hit; by default New only uses what it can find out
+// offline. ipnlocal.LocalBackend calls it in the background and sends
+// an updated Hostinfo if the answer changed, so that Azure VMs and old
+// GCP VMs, which the offline guess can't identify, are still reported.
+//
+// The metadata server is only consulted if the offline guess is
+// inconclusive, and then through util/cloudenv.Get, so its cached
+// answer is shared with (and not probed again for) net/dns. ctx bounds
+// how long this waits for it; if ctx is done first, the offline guess
+// is returned and New keeps using it. It returns the cloud found, or
+// the empty string if none.
+func RefreshCloudMetadata(ctx context.Context) cloudenv.Cloud {
+	c, maybe := offlineCloudCached()
+	if c == "" && maybe {
+		done := make(chan cloudenv.Cloud, 1)
+		go func() { done <- cloudenvGet() }()
+		select {
+		case c = <-done:
+		case <-ctx.Done():
+			return c
+		}
+	}
+	refreshedCloud.Store(c)
+	return c
+}
+
+// cloud returns the cloud for New: the result of RefreshCloudMetadata
+// if it's been called, or else of an earlier util/cloudenv.Get if
+// there was one, and otherwise the offline guess.
+func cloud() cloudenv.Cloud {
+	if c, ok := refreshedCloud.LoadOk(); ok {
+		return c
+	}
+	c, maybe := offlineCloudCached()
+	if c == "" && maybe {
+		if cc, ok := cloudenvCached(); ok {
+			return cc
+		}
+	}
+	return c
+}
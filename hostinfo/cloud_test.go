@@ -0,0 +1,158 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hostinfo
+
+import (
+	"context"
+	"testing"
+
+	"tailscale.com/syncs"
+	"tailscale.com/types/opt"
+	"tailscale.com/util/cloudenv"
+)
+
+func TestGuessCloud(t *testing.T) {
+	tests := []struct {
+		name      string
+		dmi       dmiInfo
+		hv        opt.Bool
+		want      cloudenv.Cloud
+		wantMaybe bool
+	}{
+		{"aws_nitro", dmiInfo{BIOSVendor: "Amazon EC2", SysVendor: "Amazon EC2", ProductName: "m5.large"}, "true", cloudenv.AWS, false},
+		{"aws_xen", dmiInfo{BIOSVendor: "Xen", ProductName: "HVM domU"}, "true", "", false},
+		{"aws_xen_amazon", dmiInfo{BIOSVendor: "4.11.amazon"}, "true", cloudenv.AWS, false},
+		{"aws_metal", dmiInfo{BIOSVendor: "Amazon EC2", ProductName: "i3.metal"}, "false", cloudenv.AWS, false},
+		{"gcp", dmiInfo{BIOSVendor: "Google", SysVendor: "Google", ProductName: "Google Compute Engine"}, "true", cloudenv.GCP, false},
+		{"gcp_old", dmiInfo{ProductName: "Google"}, "true", "", true},
+		{"azure", dmiInfo{BIOSVendor: "Microsoft Corporation", ProductName: "Virtual Machine", ChassisAssetTag: azureAssetTag}, "true", cloudenv.Azure, false},
+		{"hyperv", dmiInfo{BIOSVendor: "Microsoft Corporation", ProductName: "Virtual Machine"}, "true", "", true},
+		{"hyperv_no_cpuid", dmiInfo{BIOSVendor: "Microsoft Corporation", ProductName: "Virtual Machine"}, "", "", true},
+		{"bare_metal", dmiInfo{BIOSVendor: "Microsoft Corporation", ProductName: "Surface Laptop 4"}, "false", "", false},
+		{"laptop", dmiInfo{BIOSVendor: "LENOVO", ProductName: "20XW0055US"}, "false", "", false},
+		{"nothing", dmiInfo{}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, maybe := guessCloud(tt.dmi, tt.hv)
+			if got != tt.want || maybe != tt.wantMaybe {
+				t.Errorf("got %q, %v; want %q, %v", got, maybe, tt.want, tt.wantMaybe)
+			}
+		})
+	}
+}
+
+func TestRefreshCloudMetadata(t *testing.T) {
+	oldDMI, oldHV, oldGet, oldCached := osDMI, hypervisor, cloudenvGet, cloudenvCached
+	defer func() {
+		osDMI, hypervisor, cloudenvGet, cloudenvCached = oldDMI, oldHV, oldGet, oldCached
+		refreshedCloud = syncs.AtomicValue[cloudenv.Cloud]{}
+		cachedOfflineCloud = new(offlineCloudResult)
+	}()
+	hypervisor = func() opt.Bool { return "true" }
+	cloudenvCached = func() (cloudenv.Cloud, bool) { return "", false }
+
+	tests := []struct {
+		name        string
+		dmi         dmiInfo
+		metadata    cloudenv.Cloud
+		wantOffline cloudenv.Cloud
+		want        cloudenv.Cloud
+		wantProbe   bool
+	}{
+		{"offline_match", dmiInfo{ProductName: "Google Compute Engine"}, "", cloudenv.GCP, cloudenv.GCP, false},
+		{"azure_via_metadata", dmiInfo{ProductName: "Virtual Machine"}, cloudenv.Azure, "", cloudenv.Azure, true},
+		{"hyperv_not_cloud", dmiInfo{ProductName: "Virtual Machine"}, "", "", "", true},
+		{"not_a_vm_we_know", dmiInfo{ProductName: "VirtualBox"}, cloudenv.AWS, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshedCloud = syncs.AtomicValue[cloudenv.Cloud]{}
+			cachedOfflineCloud = new(offlineCloudResult)
+			osDMI = func() dmiInfo { return tt.dmi }
+			probed := false
+			cloudenvGet = func() cloudenv.Cloud {
+				probed = true
+				return tt.metadata
+			}
+			if got := cloud(); got != tt.wantOffline {
+				t.Errorf("before refresh, cloud = %q; want %q", got, tt.wantOffline)
+			}
+			if probed {
+				t.Fatal("cloud hit the network")
+			}
+			if got := RefreshCloudMetadata(context.Background()); got != tt.want {
+				t.Errorf("RefreshCloudMetadata = %q; want %q", got, tt.want)
+			}
+			if probed != tt.wantProbe {
+				t.Errorf("probed = %v; want %v", probed, tt.wantProbe)
+			}
+			if got := New().Cloud; got != string(tt.want) {
+				t.Errorf("after refresh, New().Cloud = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudSharesCloudenv(t *testing.T) {
+	oldDMI, oldHV, oldGet, oldCached := osDMI, hypervisor, cloudenvGet, cloudenvCached
+	defer func() {
+		osDMI, hypervisor, cloudenvGet, cloudenvCached = oldDMI, oldHV, oldGet, oldCached
+		refreshedCloud = syncs.AtomicValue[cloudenv.Cloud]{}
+		cachedOfflineCloud = new(offlineCloudResult)
+	}()
+	refreshedCloud = syncs.AtomicValue[cloudenv.Cloud]{}
+	cachedOfflineCloud = new(offlineCloudResult)
+	hypervisor = func() opt.Bool { return "true" }
+	osDMI = func() dmiInfo { return dmiInfo{ProductName: "Virtual Machine"} }
+
+	// Once net/dns has called cloudenv.Get, New uses its answer
+	// without a refresh.
+	cloudenvCached = func() (cloudenv.Cloud, bool) { return cloudenv.Azure, true }
+	if got := cloud(); got != cloudenv.Azure {
+		t.Errorf("with cloudenv cached, cloud = %q; want %q", got, cloudenv.Azure)
+	}
+
+	// A refresh that times out keeps the offline guess and doesn't
+	// record it as refreshed.
+	cloudenvCached = func() (cloudenv.Cloud, bool) { return "", false }
+	block := make(chan struct{})
+	defer close(block)
+	cloudenvGet = func() cloudenv.Cloud {
+		<-block
+		return cloudenv.Azure
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := RefreshCloudMetadata(ctx); got != "" {
+		t.Errorf("RefreshCloudMetadata with done ctx = %q; want offline guess", got)
+	}
+	if _, ok := refreshedCloud.LoadOk(); ok {
+		t.Error("timed-out refresh was recorded")
+	}
+}
+
+func TestOfflineCloudCached(t *testing.T) {
+	oldDMI, oldHV := osDMI, hypervisor
+	defer func() {
+		osDMI, hypervisor = oldDMI, oldHV
+		cachedOfflineCloud = new(offlineCloudResult)
+	}()
+	cachedOfflineCloud = new(offlineCloudResult)
+	hypervisor = func() opt.Bool { return "true" }
+	calls := 0
+	osDMI = func() dmiInfo {
+		calls++
+		return dmiInfo{ProductName: "Google Compute Engine"}
+	}
+	for i := 0; i < 3; i++ {
+		if got := cloud(); got != cloudenv.GCP {
+			t.Fatalf("cloud = %q; want %q", got, cloudenv.GCP)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("read DMI %d times; want 1", calls)
+	}
+}
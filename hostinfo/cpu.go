@@ -0,0 +1,112 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hostinfo
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+// The CPU feature sets consulted by cpuFeatures. They're variables so
+// tests can point them at fakes.
+var (
+	cpuX86   = &cpu.X86
+	cpuARM64 = &cpu.ARM64
+	cpuARM   = &cpu.ARM
+)
+
+// cpuFeature is a named instruction set extension and whether the CPU
+// has it.
+type cpuFeature struct {
+	name string
+	has  bool
+}
+
+// cpuFeatures returns the names of the instruction set extensions
+// ("avx2", "aes", "neon", etc) that the CPU supports and that matter to
+// our choice of code paths, mostly WireGuard's crypto.
+func cpuFeatures() []string {
+	return cpuFeaturesFor(runtime.GOARCH)
+}
+
+func cpuFeaturesFor(goarch string) []string {
+	var fs []cpuFeature
+	switch goarch {
+	case "amd64", "386":
+		x := cpuX86
+		fs = []cpuFeature{
+			{"sse2", x.HasSSE2},
+			{"sse3", x.HasSSE3},
+			{"ssse3", x.HasSSSE3},
+			{"sse4.1", x.HasSSE41},
+			{"sse4.2", x.HasSSE42},
+			{"avx", x.HasAVX},
+			{"avx2", x.HasAVX2},
+			{"avx512f", x.HasAVX512F},
+			{"bmi2", x.HasBMI2},
+			{"aes", x.HasAES},
+			{"pclmulqdq", x.HasPCLMULQDQ},
+		}
+	case "arm64":
+		a := cpuARM64
+		fs = []cpuFeature{
+			{"neon", a.HasASIMD},
+			{"aes", a.HasAES},
+			{"pmull", a.HasPMULL},
+			{"sha1", a.HasSHA1},
+			{"sha2", a.HasSHA2},
+			{"sha3", a.HasSHA3},
+			{"sha512", a.HasSHA512},
+			{"crc32", a.HasCRC32},
+			{"atomics", a.HasATOMICS},
+		}
+	case "arm":
+		a := cpuARM
+		fs = []cpuFeature{
+			{"neon", a.HasNEON},
+			{"aes", a.HasAES},
+			{"pmull", a.HasPMULL},
+			{"sha1", a.HasSHA1},
+			{"sha2", a.HasSHA2},
+			{"crc32", a.HasCRC32},
+		}
+	}
+	var ret []string
+	for _, f := range fs {
+		if f.has {
+			ret = append(ret, f.name)
+		}
+	}
+	return ret
+}
+
+// microArchLevel returns the x86-64 microarchitecture level (1 through
+// 4, as in GOAMD64=v3) that the CPU supports, or 0 if this isn't
+// amd64. x/sys/cpu doesn't report every feature the levels are defined
+// by (LAHF, F16C, LZCNT and MOVBE are missing), so this checks the
+// others, which in practice come along with them.
+func microArchLevel() int {
+	return microArchLevelFor(runtime.GOARCH)
+}
+
+func microArchLevelFor(goarch string) int {
+	if goarch != "amd64" {
+		return 0
+	}
+	x := cpuX86
+	if !(x.HasCX16 && x.HasPOPCNT && x.HasSSE3 && x.HasSSSE3 && x.HasSSE41 && x.HasSSE42) {
+		return 1
+	}
+	// AVX needs OS support for saving the YMM registers, which x/sys/cpu
+	// already folds into HasAVX and HasAVX2.
+	if !(x.HasAVX && x.HasAVX2 && x.HasBMI1 && x.HasBMI2 && x.HasFMA && x.HasOSXSAVE) {
+		return 2
+	}
+	if !(x.HasAVX512F && x.HasAVX512BW && x.HasAVX512CD && x.HasAVX512DQ && x.HasAVX512VL) {
+		return 3
+	}
+	return 4
+}
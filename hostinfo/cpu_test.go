@@ -0,0 +1,96 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hostinfo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCPUFeatures(t *testing.T) {
+	oldX86, oldARM64, oldARM := cpuX86, cpuARM64, cpuARM
+	defer func() { cpuX86, cpuARM64, cpuARM = oldX86, oldARM64, oldARM }()
+
+	// Start from all-false copies of the real feature sets.
+	x86, arm64, arm := zeroOf(cpuX86), zeroOf(cpuARM64), zeroOf(cpuARM)
+	cpuX86, cpuARM64, cpuARM = &x86, &arm64, &arm
+
+	x86.HasSSE2 = true
+	x86.HasAVX2 = true
+	x86.HasAES = true
+	if got, want := cpuFeaturesFor("amd64"), []string{"sse2", "avx2", "aes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("amd64 = %q; want %q", got, want)
+	}
+
+	arm64.HasASIMD = true
+	arm64.HasAES = true
+	arm64.HasPMULL = true
+	arm64.HasSHA2 = true
+	if got, want := cpuFeaturesFor("arm64"), []string{"neon", "aes", "pmull", "sha2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("arm64 = %q; want %q", got, want)
+	}
+
+	arm.HasNEON = true
+	if got, want := cpuFeaturesFor("arm"), []string{"neon"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("arm = %q; want %q", got, want)
+	}
+
+	if got := cpuFeaturesFor("mips"); got != nil {
+		t.Errorf("mips = %q; want nil", got)
+	}
+
+	// And make sure the real thing doesn't panic.
+	cpuX86, cpuARM64, cpuARM = oldX86, oldARM64, oldARM
+	t.Logf("cpuFeatures = %q", cpuFeatures())
+}
+
+// zeroOf returns the zero value of the type p points to, which is handy
+// for the anonymous struct types in x/sys/cpu.
+func zeroOf[T any](p *T) (zero T) { return }
+
+func TestMicroArchLevel(t *testing.T) {
+	old := cpuX86
+	defer func() { cpuX86 = old }()
+
+	v2 := []string{"HasCX16", "HasPOPCNT", "HasSSE3", "HasSSSE3", "HasSSE41", "HasSSE42"}
+	v3 := append(v2[:len(v2):len(v2)], "HasAVX", "HasAVX2", "HasBMI1", "HasBMI2", "HasFMA", "HasOSXSAVE")
+	v4 := append(v3[:len(v3):len(v3)], "HasAVX512F", "HasAVX512BW", "HasAVX512CD", "HasAVX512DQ", "HasAVX512VL")
+	tests := []struct {
+		name   string
+		goarch string
+		has    []string // cpu.X86 fields to set
+		not    []string // and then clear
+		want   int
+	}{
+		{"baseline", "amd64", []string{"HasSSE2"}, nil, 1},
+		{"v2_no_popcnt", "amd64", v2, []string{"HasPOPCNT"}, 1},
+		{"v2", "amd64", v2, nil, 2},
+		{"v3_no_bmi2", "amd64", v3, []string{"HasBMI2"}, 2},
+		{"v3", "amd64", v3, nil, 3},
+		{"v4_no_vl", "amd64", v4, []string{"HasAVX512VL"}, 3},
+		{"v4", "amd64", v4, nil, 4},
+		{"386", "386", v4, nil, 0},
+		{"arm64", "arm64", v4, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := zeroOf(old)
+			xv := reflect.ValueOf(&x).Elem()
+			for _, f := range tt.has {
+				xv.FieldByName(f).SetBool(true)
+			}
+			for _, f := range tt.not {
+				xv.FieldByName(f).SetBool(false)
+			}
+			cpuX86 = &x
+			if got := microArchLevelFor(tt.goarch); got != tt.want {
+				t.Errorf("got v%d; want v%d", got, tt.want)
+			}
+		})
+	}
+
+	cpuX86 = old
+	t.Logf("microArchLevel = %d", microArchLevel())
+}
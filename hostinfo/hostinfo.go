@@ -8,7 +8,13 @@
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	"net"
+	"net/netip"
 	"os"
 	"runtime"
 	"strings"
@@ -16,9 +22,9 @@
 	"time"
 
 	"go4.org/mem"
+	"tailscale.com/net/tsaddr"
 	"tailscale.com/tailcfg"
 	"tailscale.com/types/opt"
-	"tailscale.com/util/cloudenv"
 	"tailscale.com/util/dnsname"
 	"tailscale.com/util/lineread"
 	"tailscale.com/version"
@@ -40,16 +46,54 @@ func New() *tailcfg.Hostinfo {
 		GoArch:      runtime.GOARCH,
 		GoVersion:   runtime.Version(),
 		DeviceModel: deviceModel(),
-		Cloud:       string(cloudenv.Get()),
+		Cloud:       string(cloud()),
 	}
 }
 
 // non-nil on some platforms
 var (
-	osVersion   func() string
-	packageType func() string
+	osVersion     func() string
+	packageType   func() string
+	serviceStatus func() opt.Bool
+	rawMachineID  func() string
+	batteryStatus func() opt.Bool
+	fsTypeOf      func(path string) string
+	ipv6Status    func() opt.Bool
+	getBootTime   func() time.Time
+	osTimeZone    func() string
+	clockSynced   func() opt.Bool
+	listGPUs      func() []string
+	osDNSDomains  func() (domain string, search []string, ok bool)
+	defaultRoute  func() (iface string, err error)
+	isTranslated  func() opt.Bool
+	diskSpace     func(path string) (free, total uint64)
+	debugged      func() opt.Bool
+	syncDaemon    func() string
+	tpmStatus     func() tpmInfo
+	osLocale      func() localeInfo
+	osFirmware    func() string
+	secureBootOn  func() opt.Bool
+	cpuSpeed      func() cpuClock
+	osFormFactor  func() string
+	osDMI         func() dmiInfo
+	hypervisor    func() opt.Bool
+	resumeTime    func() time.Time
+	fdLimits      func() fdLimitInfo
+	elevated      func() opt.Bool
+	osProxy       func() proxyInfo
+	osDNSServers  func() (servers []netip.Addr, ok bool)
 )
 
+// runningAsService reports whether this process was started by the OS
+// service manager (systemd, launchd, the Windows SCM) rather than
+// interactively by a user. It's unset if that can't be determined.
+func runningAsService() opt.Bool {
+	if serviceStatus == nil {
+		return ""
+	}
+	return serviceStatus()
+}
+
 // GetOSVersion returns the OSVersion of current host if available.
 func GetOSVersion() string {
 	if s, _ := osVersionAtomic.Load().(string); s != "" {
@@ -266,6 +310,765 @@ func inDockerDesktop() bool {
 	return false
 }
 
+// hasBattery reports whether the device is battery powered, as a hint
+// that it's a laptop or other mobile device rather than a fixed one.
+// It's unset if the platform doesn't say.
+func hasBattery() opt.Bool {
+	if batteryStatus == nil {
+		return ""
+	}
+	return batteryStatus()
+}
+
+// ipv6Available reports whether the OS has IPv6 enabled. It's false
+// if IPv6 is disabled or not supported by the kernel, and unset if
+// that can't be determined.
+func ipv6Available() opt.Bool {
+	if ipv6Status == nil {
+		return ""
+	}
+	return ipv6Status()
+}
+
+// bootTime returns when the OS booted, or the zero time if unknown.
+func bootTime() time.Time {
+	if getBootTime == nil {
+		return time.Time{}
+	}
+	return getBootTime()
+}
+
+// fdLimitInfo is the limit on this process's open file descriptors.
+type fdLimitInfo struct {
+	Soft uint64 // the limit in effect
+	Hard uint64 // the most the soft limit can be raised to
+}
+
+// fdLimit returns the open file descriptor limit, as tailscaled can
+// run out with many peers. It's the zero value if unknown.
+func fdLimit() fdLimitInfo {
+	if fdLimits == nil {
+		return fdLimitInfo{}
+	}
+	return fdLimits()
+}
+
+// isElevated reports whether this process is running as root or, on
+// Windows, with an elevated (Administrator) token. It's unset if that
+// can't be determined.
+func isElevated() opt.Bool {
+	if elevated == nil {
+		return ""
+	}
+	return elevated()
+}
+
+// proxyInfo is a proxy configuration, in the style of the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type proxyInfo struct {
+	HTTPProxy  string // like "proxy.example.com:3128"
+	HTTPSProxy string
+	NoProxy    string // comma-separated hosts and domains
+}
+
+// getenv is os.Getenv, but can be replaced by tests.
+var getenv = os.Getenv
+
+// envProxy returns the proxy configuration from the environment. As
+// with Go's net/http, the upper case variables take precedence.
+func envProxy() proxyInfo {
+	get := func(name string) string {
+		if v := getenv(name); v != "" {
+			return v
+		}
+		return getenv(strings.ToLower(name))
+	}
+	return proxyInfo{
+		HTTPProxy:  get("HTTP_PROXY"),
+		HTTPSProxy: get("HTTPS_PROXY"),
+		NoProxy:    get("NO_PROXY"),
+	}
+}
+
+// systemProxy returns the proxy that connections to the control
+// plane may go through, or the zero value if there's none. The
+// environment variables take precedence, as they do for Go's HTTP
+// client. Without them, it's the OS's system-wide proxy settings on
+// platforms that have them.
+func systemProxy() proxyInfo {
+	if p := envProxy(); p != (proxyInfo{}) || osProxy == nil {
+		return p
+	}
+	return osProxy()
+}
+
+// lastResume returns when the OS last resumed from sleep or
+// hibernation, or the zero time if it hasn't since boot or that's
+// unknown. Network state is often stale just after a resume.
+func lastResume() time.Time {
+	if resumeTime == nil {
+		return time.Time{}
+	}
+	return resumeTime()
+}
+
+// sinceResume returns how long ago the OS last resumed, or zero if
+// unknown.
+func sinceResume() time.Duration {
+	t := lastResume()
+	if t.IsZero() {
+		return 0
+	}
+	return time.Since(t)
+}
+
+// uptime returns how long the OS has been up, or zero if unknown.
+func uptime() time.Duration {
+	bt := bootTime()
+	if bt.IsZero() {
+		return 0
+	}
+	return time.Since(bt)
+}
+
+// clockState describes the host's clock configuration, for diagnosing
+// auth failures caused by clock skew.
+type clockState struct {
+	TZ        string   // IANA time zone name ("America/Toronto"), or empty if unknown
+	NTPSynced opt.Bool // whether the clock is synchronized to a time source
+
+	// SyncDaemon is the time synchronization daemon, such as
+	// "chronyd" or "systemd-timesyncd", or empty if unknown.
+	SyncDaemon string
+}
+
+func getClockState() clockState {
+	st := clockState{TZ: timeZone()}
+	if clockSynced != nil {
+		st.NTPSynced = clockSynced()
+	}
+	if syncDaemon != nil {
+		st.SyncDaemon = syncDaemon()
+	}
+	return st
+}
+
+// localtimeReadlink is os.Readlink, but can be replaced by tests.
+var localtimeReadlink = os.Readlink
+
+// timeZone returns the IANA name of the local time zone, from the TZ
+// environment variable, the OS's own setting, or the /etc/localtime
+// symlink, in that order. It returns the empty string if unknown.
+func timeZone() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	if osTimeZone != nil {
+		if tz := osTimeZone(); tz != "" {
+			return tz
+		}
+	}
+	target, err := localtimeReadlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	return zoneFromLocaltimeLink(target)
+}
+
+// zoneFromLocaltimeLink returns the zone name from an /etc/localtime
+// symlink target such as "/usr/share/zoneinfo/Europe/Paris" or
+// "/var/db/timezone/zoneinfo/Asia/Tokyo".
+func zoneFromLocaltimeLink(target string) string {
+	const dir = "zoneinfo/"
+	i := strings.LastIndex(target, dir)
+	if i == -1 {
+		return ""
+	}
+	return target[i+len(dir):]
+}
+
+// gpus returns a description ("NVIDIA [10de:2204]", "AMD Radeon Pro
+// 5500M") of each GPU on the host, for correlating driver issues. It
+// only reads what the OS already knows; it never loads drivers.
+func gpus() []string {
+	if listGPUs == nil {
+		return nil
+	}
+	return listGPUs()
+}
+
+// translated reports whether this binary is running under binary
+// translation (Rosetta 2, QEMU user-mode emulation), which can make
+// CPU-heavy work like crypto unexpectedly slow.
+func translated() opt.Bool {
+	if isTranslated == nil {
+		return ""
+	}
+	return isTranslated()
+}
+
+// underDebugger reports whether a debugger or tracer is attached to
+// this process, which explains otherwise mysterious hangs.
+func underDebugger() opt.Bool {
+	if debugged == nil {
+		return ""
+	}
+	return debugged()
+}
+
+// tpmInfo describes the host's Trusted Platform Module.
+type tpmInfo struct {
+	Present opt.Bool
+	Version string // "1.2" or "2.0", or empty if unknown
+}
+
+// tpm returns whether the host has a TPM, for device trust features.
+func tpm() tpmInfo {
+	if tpmStatus == nil {
+		return tpmInfo{}
+	}
+	return tpmStatus()
+}
+
+// firmwareMode returns how the host booted: "uefi", "bios" for legacy
+// BIOS, or empty if unknown.
+func firmwareMode() string {
+	if osFirmware == nil {
+		return ""
+	}
+	return osFirmware()
+}
+
+// secureBoot returns whether UEFI Secure Boot is enabled. It's false
+// when booted from legacy BIOS, which doesn't support it.
+func secureBoot() (ret opt.Bool) {
+	if firmwareMode() == "bios" {
+		ret.Set(false)
+		return ret
+	}
+	if secureBootOn == nil {
+		return ""
+	}
+	return secureBootOn()
+}
+
+// cpuClock is the CPU's clock speed, in MHz. Either field is zero if
+// unknown.
+type cpuClock struct {
+	Nominal float64 // rated (base) speed
+	Current float64 // speed at the moment, subject to scaling
+}
+
+// cpuMHz returns the CPU clock speed, for performance triage.
+func cpuMHz() cpuClock {
+	if cpuSpeed == nil {
+		return cpuClock{}
+	}
+	return cpuSpeed()
+}
+
+// formFactor returns the machine's coarse form factor: "laptop",
+// "desktop", "server" or "tablet", or empty if unknown.
+func formFactor() string {
+	if osFormFactor == nil {
+		return ""
+	}
+	return osFormFactor()
+}
+
+// chassisFormFactor maps an SMBIOS (DMI) system enclosure type, as
+// reported by Linux's chassis_type and Windows' Win32_SystemEnclosure,
+// to a form factor. Types that don't clearly map to one, such as
+// "Other" (1) and "Unknown" (2), return the empty string.
+func chassisFormFactor(chassisType int) string {
+	switch chassisType {
+	case 3, 4, 5, 6, 7, 13, 15, 16, 24, 35, 36:
+		// Desktop, low profile desktop, pizza box, mini tower,
+		// tower, all in one, space-saving, lunch box, sealed-case
+		// PC, mini PC, stick PC.
+		return "desktop"
+	case 8, 9, 10, 14, 31, 32:
+		// Portable, laptop, notebook, sub notebook, convertible,
+		// detachable.
+		return "laptop"
+	case 30:
+		return "tablet"
+	case 17, 23, 25, 28, 29:
+		// Main server chassis, rack mount chassis, multi-system
+		// chassis, blade, blade enclosure.
+		return "server"
+	}
+	return ""
+}
+
+// localeInfo is the host's locale.
+type localeInfo struct {
+	Language string // ISO 639 language code, such as "en"
+	Country  string // ISO 3166 country code, such as "US", or empty
+	Charset  string // character set, such as "UTF-8", or empty
+}
+
+// locale returns the locale from the LC_ALL or LANG environment
+// variables, in that order, and failing that from the OS's own
+// setting. It's the zero value for the C (or POSIX) locale or if
+// unknown.
+func locale() localeInfo {
+	for _, env := range []string{"LC_ALL", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			return parseLocale(v)
+		}
+	}
+	if osLocale != nil {
+		return osLocale()
+	}
+	return localeInfo{}
+}
+
+// parseLocale parses a POSIX locale name of the form
+// language[_territory][.codeset][@modifier], such as "en_US.UTF-8" or
+// "de_DE@euro". It also accepts BCP 47 tags like "en-US" or
+// "zh-Hans-CN", skipping any script subtag.
+func parseLocale(s string) localeInfo {
+	s, _, _ = strings.Cut(s, "@")
+	s, charset, _ := strings.Cut(s, ".")
+	if s == "C" || s == "POSIX" || s == "" {
+		return localeInfo{}
+	}
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' })
+	if len(parts) == 0 {
+		return localeInfo{}
+	}
+	lang, country := parts[0], ""
+	for _, p := range parts[1:] {
+		if len(p) == 2 || len(p) == 3 { // region, not a 4-letter script
+			country = p
+			break
+		}
+	}
+	charset = strings.ToUpper(charset)
+	if charset == "UTF8" {
+		charset = "UTF-8"
+	}
+	return localeInfo{
+		Language: strings.ToLower(lang),
+		Country:  strings.ToUpper(country),
+		Charset:  charset,
+	}
+}
+
+// networkSummary is a coarse summary of the host's network
+// configuration, for connectivity diagnostics.
+type networkSummary struct {
+	InterfaceCount  int      // number of network interfaces, including loopback
+	HasDefaultRoute opt.Bool // unset if routes couldn't be enumerated
+	DefaultIface    string   // name of the default route's interface, if any
+}
+
+// netInterfaces is net.Interfaces, but can be replaced by tests.
+var netInterfaces = net.Interfaces
+
+// netSummary returns a summary of the host's network interfaces and
+// default route.
+func netSummary() networkSummary {
+	var ret networkSummary
+	if ifs, err := netInterfaces(); err == nil {
+		ret.InterfaceCount = len(ifs)
+	}
+	if defaultRoute == nil {
+		return ret
+	}
+	iface, err := defaultRoute()
+	if err != nil {
+		return ret
+	}
+	ret.HasDefaultRoute.Set(iface != "")
+	ret.DefaultIface = iface
+	return ret
+}
+
+// defaultMTU returns the MTU of the default route's interface, or 0
+// if unknown.
+func defaultMTU() int {
+	if defaultRoute == nil {
+		return 0
+	}
+	name, err := defaultRoute()
+	if err != nil || name == "" {
+		return 0
+	}
+	ifs, err := netInterfaces()
+	if err != nil {
+		return 0
+	}
+	for _, ifc := range ifs {
+		if ifc.Name == name {
+			return ifc.MTU
+		}
+	}
+	return 0
+}
+
+// vpnIfacePrefixes are the name prefixes of VPN and tunnel
+// interfaces: utun (macOS), tun and tap (OpenVPN and friends), ppp
+// (PPTP, L2TP) and wg (WireGuard).
+var vpnIfacePrefixes = []string{"utun", "tun", "tap", "ppp", "wg"}
+
+// existingVPNInterfaces returns the names of the up interfaces that
+// look like another VPN's, whose routes may conflict with ours: those
+// with a VPN-like name, and other point-to-point links.
+//
+// Tailscale's own interface is skipped. It's usually "tailscale0", but
+// on macOS and iOS it's a utunN like any other VPN's, so it's also
+// recognized by having an address in Tailscale's IPv6 range.
+func existingVPNInterfaces() []string {
+	ifs, err := netInterfaces()
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	for i := range ifs {
+		ifc := &ifs[i]
+		if ifc.Flags&net.FlagUp == 0 || ifc.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		name := strings.ToLower(ifc.Name)
+		if strings.HasPrefix(name, "tailscale") || hasTailscaleAddr(ifc) {
+			continue
+		}
+		vpn := ifc.Flags&net.FlagPointToPoint != 0
+		for _, p := range vpnIfacePrefixes {
+			if strings.HasPrefix(name, p) {
+				vpn = true
+				break
+			}
+		}
+		if vpn {
+			ret = append(ret, ifc.Name)
+		}
+	}
+	return ret
+}
+
+// hasTailscaleAddr reports whether ifc has an address in Tailscale's
+// IPv6 ULA range, which (unlike 100.64.0.0/10) no one else uses.
+func hasTailscaleAddr(ifc *net.Interface) bool {
+	addrs, err := interfaceAddrs(ifc)
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip, ok := netip.AddrFromSlice(ipn.IP); ok && tsaddr.TailscaleULARange().Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryAddr is the host's primary IP address and how it's
+// classified.
+type primaryAddr struct {
+	Addr    netip.Addr // invalid if unknown
+	Private opt.Bool   // in RFC 1918 or ULA (fc00::/7) space
+	CGNAT   opt.Bool   // in the 100.64.0.0/10 shared address space
+}
+
+// interfaceAddrs is (*net.Interface).Addrs, but can be replaced by
+// tests.
+var interfaceAddrs = func(ifc *net.Interface) ([]net.Addr, error) {
+	return ifc.Addrs()
+}
+
+// cgnatRange is the RFC 6598 shared address space used by carrier-grade
+// NATs, 100.64.0.0/10.
+var cgnatRange = netip.MustParsePrefix("100.64.0.0/10")
+
+// primaryIP returns the address of the default route's interface,
+// preferring IPv4 and skipping link-local addresses. The fields are
+// unset (and Addr invalid) if there's no default route or it can't be
+// determined.
+//
+// Tailscale's own addresses are also in 100.64.0.0/10. When an exit
+// node is in use the default route is via the Tailscale interface, so
+// CGNAT is then reporting our own address, not the ISP's NAT; callers
+// should compare Addr against the node's Tailscale addresses before
+// drawing conclusions from it.
+func primaryIP() (ret primaryAddr) {
+	if defaultRoute == nil {
+		return ret
+	}
+	name, err := defaultRoute()
+	if err != nil || name == "" {
+		return ret
+	}
+	ifs, err := netInterfaces()
+	if err != nil {
+		return ret
+	}
+	var ifc *net.Interface
+	for i := range ifs {
+		if ifs[i].Name == name {
+			ifc = &ifs[i]
+			break
+		}
+	}
+	if ifc == nil {
+		return ret
+	}
+	addrs, err := interfaceAddrs(ifc)
+	if err != nil {
+		return ret
+	}
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(ipn.IP)
+		if !ok {
+			continue
+		}
+		ip = ip.Unmap()
+		if ip.IsLinkLocalUnicast() || ip.IsLoopback() {
+			continue
+		}
+		if !ret.Addr.IsValid() || (ip.Is4() && !ret.Addr.Is4()) {
+			ret.Addr = ip
+		}
+	}
+	if ret.Addr.IsValid() {
+		ret.Private.Set(ret.Addr.IsPrivate())
+		ret.CGNAT.Set(cgnatRange.Contains(ret.Addr))
+	}
+	return ret
+}
+
+// dnsNames are the host's DNS names.
+type dnsNames struct {
+	FQDN          string   // fully-qualified hostname, without trailing dot; the short hostname if unknown
+	SearchDomains []string // resolver search domains, in order
+}
+
+// Hostname and resolver configuration sources. They're variables so
+// tests can replace them.
+var (
+	osHostname     = os.Hostname
+	resolvConfRead = os.ReadFile
+	etcHostsRead   = os.ReadFile
+)
+
+// getDNSNames returns the host's fully-qualified name and search
+// domains.
+//
+// The domain and search domains come from the OS's resolver settings:
+// the registry on Windows, /etc/resolv.conf elsewhere. If the hostname
+// is short (as it usually is), it's qualified the way "hostname -f"
+// does: by a matching alias in /etc/hosts, or else the resolver's
+// domain.
+func getDNSNames() dnsNames {
+	host, _ := osHostname()
+	host = strings.TrimSuffix(host, ".")
+
+	var domain string
+	var search []string
+	ok := false
+	if osDNSDomains != nil {
+		domain, search, ok = osDNSDomains()
+	}
+	if !ok {
+		if bs, err := resolvConfRead("/etc/resolv.conf"); err == nil {
+			domain, search = parseResolvConfDomains(bs)
+		}
+	}
+	ret := dnsNames{FQDN: host, SearchDomains: search}
+	if host == "" || strings.Contains(host, ".") {
+		return ret
+	}
+	if bs, err := etcHostsRead("/etc/hosts"); err == nil {
+		if fqdn := fqdnFromHosts(bs, host); fqdn != "" {
+			ret.FQDN = fqdn
+			return ret
+		}
+	}
+	if domain != "" {
+		ret.FQDN = host + "." + domain
+	}
+	return ret
+}
+
+// hostnameInfo is the host's name and what Tailscale makes of it.
+type hostnameInfo struct {
+	Raw       string // as returned by os.Hostname
+	Sanitized string // a valid DNS label, as used for the node's name
+	Changed   bool   // whether Sanitized differs from Raw
+}
+
+// sanitizedHostname returns the host's name along with the DNS label
+// Tailscale derives from it.
+func sanitizedHostname() hostnameInfo {
+	raw, _ := osHostname()
+	return sanitizeHostname(raw)
+}
+
+// sanitizeHostname applies Tailscale's node naming rules to the
+// hostname raw: only its first label is used (as in New), common local
+// suffixes are dropped, and the rest is lowercased, stripped of
+// characters that aren't valid in a DNS label and truncated to 63
+// bytes, by dnsname.SanitizeHostname.
+func sanitizeHostname(raw string) hostnameInfo {
+	s := dnsname.SanitizeHostname(dnsname.FirstLabel(raw))
+	return hostnameInfo{
+		Raw:       raw,
+		Sanitized: s,
+		Changed:   s != raw,
+	}
+}
+
+// parseResolvConfDomains returns the local domain and search list from
+// resolv.conf contents. As with the C resolver, "domain" and "search"
+// are mutually exclusive and the last one wins; the domain defaults to
+// the first search domain.
+func parseResolvConfDomains(bs []byte) (domain string, search []string) {
+	lineread.Reader(bytes.NewReader(bs), func(line []byte) error {
+		f := strings.Fields(string(line))
+		if len(f) < 2 {
+			return nil
+		}
+		switch f[0] {
+		case "domain":
+			domain = strings.TrimSuffix(f[1], ".")
+			search = []string{domain}
+		case "search":
+			search = search[:0:0]
+			for _, d := range f[1:] {
+				if d = strings.TrimSuffix(d, "."); d != "" {
+					search = append(search, d)
+				}
+			}
+			domain = ""
+			if len(search) > 0 {
+				domain = search[0]
+			}
+		}
+		return nil
+	})
+	return domain, search
+}
+
+// dnsServers returns the host's configured upstream DNS servers, in
+// order, or nil if there are none. They come from the OS's resolver
+// settings where it has its own, and /etc/resolv.conf otherwise.
+func dnsServers() []netip.Addr {
+	if osDNSServers != nil {
+		if servers, ok := osDNSServers(); ok {
+			return servers
+		}
+	}
+	bs, err := resolvConfRead("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	return parseResolvConfServers(bs)
+}
+
+// parseResolvConfServers returns the nameservers in resolv.conf
+// contents bs, skipping any that aren't IP addresses.
+func parseResolvConfServers(bs []byte) []netip.Addr {
+	var ret []netip.Addr
+	lineread.Reader(bytes.NewReader(bs), func(line []byte) error {
+		f := strings.Fields(string(line))
+		if len(f) < 2 || f[0] != "nameserver" {
+			return nil
+		}
+		if ip, err := netip.ParseAddr(f[1]); err == nil {
+			ret = append(ret, ip)
+		}
+		return nil
+	})
+	return ret
+}
+
+// fqdnFromHosts returns the canonical name that /etc/hosts contents bs
+// give the short hostname host, if it's of the form "host.domain".
+func fqdnFromHosts(bs []byte, host string) string {
+	var ret string
+	lineread.Reader(bytes.NewReader(bs), func(line []byte) error {
+		if i := bytes.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		f := strings.Fields(string(line))
+		if len(f) < 3 {
+			return nil
+		}
+		canon := strings.TrimSuffix(f[1], ".")
+		if !strings.HasPrefix(strings.ToLower(canon), strings.ToLower(host)+".") {
+			return nil
+		}
+		for _, alias := range f[2:] {
+			if strings.EqualFold(alias, host) {
+				ret = canon
+				return io.EOF // stop
+			}
+		}
+		return nil
+	})
+	return ret
+}
+
+// fsType returns the name of the filesystem type that path is on, such
+// as "ext4", "overlay", "nfs", "apfs" or "ntfs", or the empty string if
+// unknown.
+//
+// Some filesystems, notably overlayfs in containers and network
+// filesystems, are known to corrupt or lose tailscaled state.
+func fsType(path string) string {
+	if fsTypeOf == nil {
+		return ""
+	}
+	return fsTypeOf(path)
+}
+
+// diskFree returns the bytes free for unprivileged use and the total
+// size of the filesystem containing path, or zeros if unknown. A
+// nearly full state directory risks corrupting tailscaled's state.
+func diskFree(path string) (free, total uint64) {
+	if diskSpace == nil {
+		return 0, 0
+	}
+	return diskSpace(path)
+}
+
+// machineID returns a stable identifier for this machine, or the empty
+// string if none is available.
+//
+// It's derived from the OS's own machine identifier (/etc/machine-id on
+// Linux, MachineGuid on Windows, the IOPlatformUUID on macOS), which
+// survives reinstalls of Tailscale but not of the OS. The raw OS value
+// is never returned: some other software treats it as confidential,
+// and leaking it would let anything that sees it correlate the machine
+// across every other app that reads it. Instead it's used as an HMAC
+// key over a fixed Tailscale-specific string, so the result identifies
+// the machine only to us.
+func machineID() string {
+	if rawMachineID == nil {
+		return ""
+	}
+	return hashMachineID(rawMachineID())
+}
+
+func hashMachineID(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	h := hmac.New(sha256.New, []byte(raw))
+	io.WriteString(h, "tailscale.com/hostinfo machine ID")
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
 type etcAptSrcResult struct {
 	mod      time.Time
 	disabled bool
@@ -0,0 +1,47 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package hostinfo
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	getBootTime = bootTimeBSD
+	diskSpace = diskSpaceBSD
+}
+
+// sysctlTimeval is unix.SysctlTimeval, but can be replaced by tests.
+var sysctlTimeval = unix.SysctlTimeval
+
+func bootTimeBSD() time.Time {
+	tv, err := sysctlTimeval("kern.boottime")
+	if err != nil || (tv.Sec == 0 && tv.Usec == 0) {
+		return time.Time{}
+	}
+	return time.Unix(tv.Unix())
+}
+
+// statfs is unix.Statfs, but can be replaced by tests.
+var statfs = unix.Statfs
+
+func diskSpaceBSD(path string) (free, total uint64) {
+	var st unix.Statfs_t
+	if err := statfs(path, &st); err != nil {
+		return 0, 0
+	}
+	// The field types differ between darwin and freebsd, and
+	// freebsd's Bavail can be negative when the reserved space is in
+	// use.
+	if st.Bavail > 0 {
+		free = uint64(st.Bavail) * uint64(st.Bsize)
+	}
+	return free, uint64(st.Blocks) * uint64(st.Bsize)
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package hostinfo
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestBootTimeBSD(t *testing.T) {
+	old := sysctlTimeval
+	defer func() { sysctlTimeval = old }()
+
+	want := time.Unix(1660000000, 250000000)
+	sysctlTimeval = func(name string) (*unix.Timeval, error) {
+		if name != "kern.boottime" {
+			return nil, syscall.ENOENT
+		}
+		tv := unix.NsecToTimeval(want.UnixNano())
+		return &tv, nil
+	}
+	if got := bootTimeBSD(); !got.Equal(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	sysctlTimeval = func(string) (*unix.Timeval, error) { return nil, syscall.ENOENT }
+	if got := bootTimeBSD(); !got.IsZero() {
+		t.Errorf("with no sysctl, got %v; want zero", got)
+	}
+}
+
+func TestDiskSpaceBSD(t *testing.T) {
+	old := statfs
+	defer func() { statfs = old }()
+
+	statfs = func(path string, st *unix.Statfs_t) error {
+		st.Bsize = 4096
+		st.Blocks = 1000
+		st.Bfree = 300
+		st.Bavail = 250
+		return nil
+	}
+	if free, total := diskSpaceBSD("/var/db/tailscale"); free != 250*4096 || total != 1000*4096 {
+		t.Errorf("got %v, %v; want %v, %v", free, total, 250*4096, 1000*4096)
+	}
+
+	statfs = func(string, *unix.Statfs_t) error { return syscall.ENOENT }
+	if free, total := diskSpaceBSD("/nonexistent"); free != 0 || total != 0 {
+		t.Errorf("on error, got %v, %v; want zeros", free, total)
+	}
+}
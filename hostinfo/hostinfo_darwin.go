@@ -8,12 +8,40 @@
 package hostinfo
 
 import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"tailscale.com/types/opt"
 )
 
 func init() {
 	packageType = packageTypeDarwin
+	serviceStatus = serviceStatusDarwin
+	rawMachineID = machineIDDarwin
+	batteryStatus = batteryStatusDarwin
+	fsTypeOf = fsTypeDarwin
+	ipv6Status = ipv6StatusDarwin
+	listGPUs = gpusDarwin
+	isTranslated = translatedDarwin
+	debugged = debuggedDarwin
+	osLocale = localeDarwin
+	cpuSpeed = cpuMHzDarwin
+	osFormFactor = formFactorDarwin
+	resumeTime = resumeTimeDarwin
+	osProxy = proxyDarwin
+	osDNSServers = dnsServersDarwin
+
+	if v := darwinDeviceModel(); v != "" {
+		SetDeviceModel(v)
+	}
 }
 
 func packageTypeDarwin() string {
@@ -21,3 +49,429 @@ func packageTypeDarwin() string {
 	exe, _ := os.Executable()
 	return filepath.Base(exe)
 }
+
+// sysctlString is unix.Sysctl, but can be replaced by tests.
+var sysctlString = unix.Sysctl
+
+// sysctlStringOrEmpty returns the named sysctl string value, or the
+// empty string if it's missing or unreadable.
+func sysctlStringOrEmpty(name string) string {
+	v, err := sysctlString(name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(v)
+}
+
+// darwinDeviceModel returns the hardware model identifier
+// ("MacBookPro18,1") followed by the marketing chip name in
+// parentheses ("Apple M1 Pro"), if known.
+func darwinDeviceModel() string {
+	model := sysctlStringOrEmpty("hw.model")
+	chip := sysctlStringOrEmpty("machdep.cpu.brand_string")
+	switch {
+	case model == "":
+		return chip
+	case chip == "":
+		return model
+	}
+	return model + " (" + chip + ")"
+}
+
+// ioregPlatformExpert returns the output of ioreg describing the
+// IOPlatformExpertDevice, the IOKit node with the hardware's identity.
+// It's a variable so tests can replace it.
+var ioregPlatformExpert = func() ([]byte, error) {
+	return exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+}
+
+// machineIDDarwin returns the hardware UUID, the IOPlatformUUID
+// property of IOPlatformExpertDevice (as shown in System
+// Information), which is the same across OS updates and reinstalls.
+// (The kern.uuid sysctl is no substitute: it identifies the kernel
+// binary, and so changes with every macOS update.)
+func machineIDDarwin() string {
+	out, err := ioregPlatformExpert()
+	if err != nil {
+		return ""
+	}
+	return parseIOPlatformUUID(out)
+}
+
+// parseIOPlatformUUID returns the IOPlatformUUID property from ioreg
+// output, which has a line like:
+//
+//	"IOPlatformUUID" = "5D3F1C0A-8E2B-4A8F-9C1D-0123456789AB"
+func parseIOPlatformUUID(out []byte) string {
+	for _, line := range strings.Split(string(out), "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if ok && k == `"IOPlatformUUID"` {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
+
+func serviceStatusDarwin() opt.Bool {
+	return darwinServiceStatus(os.Getppid(), os.Getenv("XPC_SERVICE_NAME"))
+}
+
+// darwinServiceStatus reports whether a process with the given parent
+// PID and XPC_SERVICE_NAME environment variable is running as a launchd
+// service.
+//
+// launchd is PID 1 and sets XPC_SERVICE_NAME to the job label for the
+// jobs it starts. Processes started from a terminal have a shell (or
+// sudo) as their parent, and often inherit XPC_SERVICE_NAME=0 from
+// Terminal.app. An orphan reparented to launchd is ambiguous.
+func darwinServiceStatus(ppid int, xpcServiceName string) (ret opt.Bool) {
+	switch {
+	case ppid != 1:
+		ret.Set(false)
+	case xpcServiceName != "" && xpcServiceName != "0":
+		ret.Set(true)
+	}
+	return ret
+}
+
+// pmsetBatt returns the output of "pmset -g batt". It's a variable so
+// tests can replace it.
+var pmsetBatt = func() ([]byte, error) {
+	return exec.Command("pmset", "-g", "batt").Output()
+}
+
+func batteryStatusDarwin() opt.Bool {
+	out, err := pmsetBatt()
+	if err != nil {
+		return ""
+	}
+	return parsePmsetBatt(string(out))
+}
+
+// parsePmsetBatt parses the output of "pmset -g batt", which lists one
+// "-InternalBattery-N" line per battery after a line naming the current
+// power source:
+//
+//	Now drawing from 'Battery Power'
+//	 -InternalBattery-0 (id=4653155)	84%; discharging; 5:12 remaining present: true
+//
+// Desktop Macs list only the power source (and any attached UPS).
+func parsePmsetBatt(out string) (ret opt.Bool) {
+	if !strings.Contains(out, "Now drawing from") {
+		return ""
+	}
+	ret.Set(strings.Contains(out, "InternalBattery"))
+	return ret
+}
+
+// fsTypeDarwin returns the filesystem type name reported by statfs,
+// such as "apfs".
+func fsTypeDarwin(path string) string {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(st.Fstypename[:])
+}
+
+// openInet6Socket opens and closes an IPv6 UDP socket. It's a variable
+// so tests can replace it.
+var openInet6Socket = func() error {
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	return unix.Close(fd)
+}
+
+// ipv6StatusDarwin reports whether the kernel supports IPv6 sockets.
+// macOS always has IPv6 compiled in, so this is false only in unusual
+// sandboxes.
+func ipv6StatusDarwin() (ret opt.Bool) {
+	err := openInet6Socket()
+	switch {
+	case err == nil:
+		ret.Set(true)
+	case errors.Is(err, unix.EAFNOSUPPORT), errors.Is(err, unix.EPROTONOSUPPORT):
+		ret.Set(false)
+	}
+	return ret
+}
+
+// systemProfilerDisplays returns the output of system_profiler's JSON
+// report on displays and graphics. It's a variable so tests can
+// replace it.
+var systemProfilerDisplays = func() ([]byte, error) {
+	return exec.Command("system_profiler", "-json", "-detailLevel", "mini", "SPDisplaysDataType").Output()
+}
+
+func gpusDarwin() []string {
+	out, err := systemProfilerDisplays()
+	if err != nil {
+		return nil
+	}
+	return parseSPDisplays(out)
+}
+
+// parseSPDisplays returns the GPU models ("Apple M1 Pro", "AMD Radeon
+// Pro 5500M") in system_profiler's SPDisplaysDataType JSON report.
+func parseSPDisplays(out []byte) []string {
+	var report struct {
+		Displays []struct {
+			Model string `json:"sppci_model"`
+		} `json:"SPDisplaysDataType"`
+	}
+	if err := json.Unmarshal(out, &report); err != nil {
+		return nil
+	}
+	var ret []string
+	for _, d := range report.Displays {
+		if d.Model != "" {
+			ret = append(ret, d.Model)
+		}
+	}
+	return ret
+}
+
+// sysctlUint32 is unix.SysctlUint32, but can be replaced by tests.
+var sysctlUint32 = unix.SysctlUint32
+
+// translatedDarwin reports whether we're an x86-64 binary running
+// under Rosetta 2 on Apple silicon. The sysctl doesn't exist on Macs
+// that can't run Rosetta, which means we're native.
+func translatedDarwin() (ret opt.Bool) {
+	v, err := sysctlUint32("sysctl.proc_translated")
+	switch {
+	case err == unix.ENOENT:
+		ret.Set(false)
+	case err == nil:
+		ret.Set(v == 1)
+	}
+	return ret
+}
+
+// sysctlKinfoProc is unix.SysctlKinfoProc, but can be replaced by
+// tests.
+var sysctlKinfoProc = unix.SysctlKinfoProc
+
+// pTraced is P_TRACED from <sys/proc.h>, missing from x/sys/unix.
+const pTraced = 0x00000800
+
+// debuggedDarwin reports whether this process is being traced, the
+// way Apple's technical Q&A QA1361 suggests.
+func debuggedDarwin() (ret opt.Bool) {
+	kp, err := sysctlKinfoProc("kern.proc.pid", os.Getpid())
+	if err != nil {
+		return ""
+	}
+	ret.Set(kp.Proc.P_flag&pTraced != 0)
+	return ret
+}
+
+// readAppleLocale returns the AppleLocale user default, such as
+// "en_US" or "en_GB@rg=nlzzzz". It's a variable so tests can replace
+// it.
+var readAppleLocale = func() (string, error) {
+	out, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// localeDarwin returns the locale from the system preferences. macOS
+// always uses UTF-8.
+func localeDarwin() localeInfo {
+	v, err := readAppleLocale()
+	if err != nil {
+		return localeInfo{}
+	}
+	li := parseLocale(v)
+	if li.Language != "" {
+		li.Charset = "UTF-8"
+	}
+	return li
+}
+
+// sysctlUint64 is unix.SysctlUint64, but can be replaced by tests.
+var sysctlUint64 = unix.SysctlUint64
+
+// cpuMHzDarwin returns the nominal CPU speed from hw.cpufrequency,
+// which is in Hz. Only Intel Macs have it, and macOS has no way to
+// read the current speed.
+func cpuMHzDarwin() cpuClock {
+	hz, err := sysctlUint64("hw.cpufrequency")
+	if err != nil {
+		return cpuClock{}
+	}
+	return cpuClock{Nominal: float64(hz) / 1e6}
+}
+
+func formFactorDarwin() string {
+	return darwinModelFormFactor(sysctlStringOrEmpty("hw.model"))
+}
+
+// darwinModelFormFactor infers the form factor from a Mac model
+// identifier such as "MacBookPro18,3". Apple silicon Macs other than
+// the first few use generic "MacNN,N" identifiers that don't say, so
+// those return the empty string.
+func darwinModelFormFactor(model string) string {
+	switch {
+	case strings.HasPrefix(model, "MacBook"): // MacBook, MacBookAir, MacBookPro
+		return "laptop"
+	case strings.HasPrefix(model, "iMac"),
+		strings.HasPrefix(model, "Macmini"),
+		strings.HasPrefix(model, "MacPro"):
+		return "desktop"
+	case strings.HasPrefix(model, "Xserve"):
+		return "server"
+	}
+	return ""
+}
+
+// resumeTimeDarwin returns the kernel's record of when the system last
+// woke, which is zero if it hasn't slept since boot.
+func resumeTimeDarwin() time.Time {
+	tv, err := sysctlTimeval("kern.waketime")
+	if err != nil || tv.Sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(tv.Unix())
+}
+
+// scutilProxy returns the output of "scutil --proxy", the
+// SystemConfiguration proxy settings. It's a variable so tests can
+// replace it.
+var scutilProxy = func() ([]byte, error) {
+	return exec.Command("scutil", "--proxy").Output()
+}
+
+// proxyDarwin returns the enabled HTTP and HTTPS proxies and the proxy
+// exceptions from the system settings.
+func proxyDarwin() proxyInfo {
+	out, err := scutilProxy()
+	if err != nil {
+		return proxyInfo{}
+	}
+	return parseScutilProxy(out)
+}
+
+// parseScutilProxy parses the output of "scutil --proxy", like:
+//
+//	<dictionary> {
+//	  ExceptionsList : <array> {
+//	    0 : *.local
+//	    1 : 169.254/16
+//	  }
+//	  HTTPEnable : 1
+//	  HTTPPort : 3128
+//	  HTTPProxy : proxy.example.com
+//	}
+func parseScutilProxy(out []byte) proxyInfo {
+	vals := map[string]string{}
+	var exceptions []string
+	inExceptions := false
+	for _, line := range strings.Split(string(out), "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), " : ")
+		switch {
+		case inExceptions && strings.TrimSpace(line) == "}":
+			inExceptions = false
+		case inExceptions && ok:
+			exceptions = append(exceptions, v)
+		case ok && k == "ExceptionsList":
+			inExceptions = true
+		case ok:
+			vals[k] = v
+		}
+	}
+	proxy := func(scheme string) string {
+		if vals[scheme+"Enable"] != "1" || vals[scheme+"Proxy"] == "" {
+			return ""
+		}
+		if port := vals[scheme+"Port"]; port != "" {
+			return net.JoinHostPort(vals[scheme+"Proxy"], port)
+		}
+		return vals[scheme+"Proxy"]
+	}
+	var ret proxyInfo
+	ret.HTTPProxy = proxy("HTTP")
+	ret.HTTPSProxy = proxy("HTTPS")
+	if ret.HTTPProxy != "" || ret.HTTPSProxy != "" {
+		ret.NoProxy = strings.Join(exceptions, ",")
+	}
+	return ret
+}
+
+// scutilDNS returns the output of "scutil --dns", the
+// SystemConfiguration DNS settings. It's a variable so tests can
+// replace it.
+var scutilDNS = func() ([]byte, error) {
+	return exec.Command("scutil", "--dns").Output()
+}
+
+// dnsServersDarwin returns the nameservers of the default resolvers.
+// /etc/resolv.conf on macOS only reflects the first of them.
+func dnsServersDarwin() (servers []netip.Addr, ok bool) {
+	out, err := scutilDNS()
+	if err != nil {
+		return nil, false
+	}
+	return parseScutilDNS(out), true
+}
+
+// parseScutilDNS returns the nameservers of the unscoped resolvers
+// without a domain, which are the ones that queries go to by default,
+// in the output of "scutil --dns", like:
+//
+//	DNS configuration
+//
+//	resolver #1
+//	  search domain[0] : lan
+//	  nameserver[0] : 192.168.1.1
+//	  if_index : 6 (en0)
+//
+//	resolver #2
+//	  domain   : local
+//	  options  : mdns
+//
+//	DNS configuration (for scoped queries)
+//	...
+func parseScutilDNS(out []byte) []netip.Addr {
+	var ret []netip.Addr
+	seen := map[netip.Addr]bool{}
+	var resolver []netip.Addr // nameservers of the current resolver
+	hasDomain := false
+	flush := func() {
+		if !hasDomain {
+			for _, ip := range resolver {
+				if !seen[ip] {
+					seen[ip] = true
+					ret = append(ret, ip)
+				}
+			}
+		}
+		resolver, hasDomain = nil, false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "DNS configuration (") {
+			break // scoped and other special-purpose resolvers
+		}
+		if strings.HasPrefix(line, "resolver #") {
+			flush()
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch {
+		case k == "domain":
+			hasDomain = true
+		case strings.HasPrefix(k, "nameserver["):
+			if ip, err := netip.ParseAddr(v); err == nil {
+				resolver = append(resolver, ip)
+			}
+		}
+	}
+	flush()
+	return ret
+}
@@ -0,0 +1,492 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin
+// +build darwin
+
+package hostinfo
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"tailscale.com/types/opt"
+)
+
+func TestDarwinDeviceModel(t *testing.T) {
+	tests := []struct {
+		name   string
+		sysctl map[string]string
+		want   string
+	}{
+		{
+			name: "apple_silicon",
+			sysctl: map[string]string{
+				"hw.model":                 "MacBookPro18,1",
+				"machdep.cpu.brand_string": "Apple M1 Pro",
+			},
+			want: "MacBookPro18,1 (Apple M1 Pro)",
+		},
+		{
+			name:   "no_chip",
+			sysctl: map[string]string{"hw.model": "Macmini9,1"},
+			want:   "Macmini9,1",
+		},
+		{
+			name:   "no_model",
+			sysctl: map[string]string{"machdep.cpu.brand_string": "Apple M2\n"},
+			want:   "Apple M2",
+		},
+		{
+			name: "none",
+			want: "",
+		},
+	}
+	old := sysctlString
+	defer func() { sysctlString = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysctlString = func(name string) (string, error) {
+				if v, ok := tt.sysctl[name]; ok {
+					return v, nil
+				}
+				return "", syscall.ENOENT
+			}
+			if got := darwinDeviceModel(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachineIDDarwin(t *testing.T) {
+	old := ioregPlatformExpert
+	defer func() { ioregPlatformExpert = old }()
+
+	ioregPlatformExpert = func() ([]byte, error) {
+		return []byte(`+-o J314sAP  <class IOPlatformExpertDevice, id 0x100000110, registered, matched, active, busy 0 (283 ms), retain 36>
+    {
+      "IOPolledInterface" = "AppleARMWatchdogTimerHibernateHandler is not serializable"
+      "IOPlatformSerialNumber" = "C02XXXXXXXXX"
+      "IOPlatformUUID" = "5D3F1C0A-8E2B-4A8F-9C1D-0123456789AB"
+      "model" = <"MacBookPro18,3">
+    }
+`), nil
+	}
+	if got, want := machineIDDarwin(), "5D3F1C0A-8E2B-4A8F-9C1D-0123456789AB"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	ioregPlatformExpert = func() ([]byte, error) { return []byte("+-o Root\n"), nil }
+	if got := machineIDDarwin(); got != "" {
+		t.Errorf("without IOPlatformUUID, got %q; want empty", got)
+	}
+	ioregPlatformExpert = func() ([]byte, error) { return nil, errors.New("no ioreg") }
+	if got := machineIDDarwin(); got != "" {
+		t.Errorf("with ioreg failing, got %q; want empty", got)
+	}
+}
+
+func TestDarwinServiceStatus(t *testing.T) {
+	tests := []struct {
+		ppid int
+		xpc  string
+		want opt.Bool
+	}{
+		{1, "com.tailscale.tailscaled", "true"},
+		{1, "", ""},
+		{1, "0", ""},
+		{4242, "0", "false"},
+		{4242, "com.apple.Terminal", "false"},
+	}
+	for _, tt := range tests {
+		if got := darwinServiceStatus(tt.ppid, tt.xpc); got != tt.want {
+			t.Errorf("darwinServiceStatus(%d, %q) = %q; want %q", tt.ppid, tt.xpc, got, tt.want)
+		}
+	}
+}
+
+func TestParsePmsetBatt(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want opt.Bool
+	}{
+		{
+			name: "laptop",
+			out:  "Now drawing from 'Battery Power'\n -InternalBattery-0 (id=4653155)\t84%; discharging; 5:12 remaining present: true\n",
+			want: "true",
+		},
+		{
+			name: "desktop",
+			out:  "Now drawing from 'AC Power'\n",
+			want: "false",
+		},
+		{
+			name: "unknown",
+			out:  "",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePmsetBatt(tt.out); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPv6StatusDarwin(t *testing.T) {
+	tests := []struct {
+		err  error
+		want opt.Bool
+	}{
+		{nil, "true"},
+		{syscall.EAFNOSUPPORT, "false"},
+		{syscall.EMFILE, ""},
+	}
+	old := openInet6Socket
+	defer func() { openInet6Socket = old }()
+	for _, tt := range tests {
+		openInet6Socket = func() error { return tt.err }
+		if got := ipv6StatusDarwin(); got != tt.want {
+			t.Errorf("with socket error %v, got %q; want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseSPDisplays(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "apple_silicon",
+			in:   `{"SPDisplaysDataType":[{"_name":"kHW_AppleM1ProItem","sppci_model":"Apple M1 Pro","spdisplays_vendor":"sppci_vendor_Apple"}]}`,
+			want: []string{"Apple M1 Pro"},
+		},
+		{
+			name: "intel_dual_gpu",
+			in: `{"SPDisplaysDataType":[
+				{"sppci_model":"Intel UHD Graphics 630","spdisplays_vendor":"Intel"},
+				{"sppci_model":"AMD Radeon Pro 5500M","spdisplays_vendor":"sppci_vendor_amd"}
+			]}`,
+			want: []string{"Intel UHD Graphics 630", "AMD Radeon Pro 5500M"},
+		},
+		{
+			name: "empty",
+			in:   `{"SPDisplaysDataType":[]}`,
+		},
+		{
+			name: "garbage",
+			in:   `not json`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSPDisplays([]byte(tt.in)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslatedDarwin(t *testing.T) {
+	tests := []struct {
+		name string
+		v    uint32
+		err  error
+		want opt.Bool
+	}{
+		{"rosetta", 1, nil, "true"},
+		{"native_arm64", 0, nil, "false"},
+		{"intel", 0, syscall.ENOENT, "false"},
+		{"error", 0, syscall.EPERM, ""},
+	}
+	old := sysctlUint32
+	defer func() { sysctlUint32 = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysctlUint32 = func(name string) (uint32, error) {
+				if name != "sysctl.proc_translated" {
+					t.Errorf("unexpected sysctl %q", name)
+				}
+				return tt.v, tt.err
+			}
+			if got := translatedDarwin(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebuggedDarwin(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags int32
+		err   error
+		want  opt.Bool
+	}{
+		{"traced", 0x4004 | pTraced, nil, "true"},
+		{"not_traced", 0x4004, nil, "false"},
+		{"error", 0, syscall.EPERM, ""},
+	}
+	old := sysctlKinfoProc
+	defer func() { sysctlKinfoProc = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysctlKinfoProc = func(name string, args ...int) (*unix.KinfoProc, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				kp := new(unix.KinfoProc)
+				kp.Proc.P_flag = tt.flags
+				return kp, nil
+			}
+			if got := debuggedDarwin(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocaleDarwin(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		err  error
+		want localeInfo
+	}{
+		{"en_us", "en_US", nil, localeInfo{"en", "US", "UTF-8"}},
+		{"region_override", "en_GB@rg=nlzzzz", nil, localeInfo{"en", "GB", "UTF-8"}},
+		{"language_only", "fr", nil, localeInfo{"fr", "", "UTF-8"}},
+		{"unset", "", errors.New("exit status 1"), localeInfo{}},
+	}
+	old := readAppleLocale
+	defer func() { readAppleLocale = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readAppleLocale = func() (string, error) { return tt.v, tt.err }
+			if got := localeDarwin(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPUMHzDarwin(t *testing.T) {
+	tests := []struct {
+		name string
+		hz   uint64
+		err  error
+		want cpuClock
+	}{
+		{"intel", 2600000000, nil, cpuClock{Nominal: 2600}},
+		{"apple_silicon", 0, unix.ENOENT, cpuClock{}},
+	}
+	old := sysctlUint64
+	defer func() { sysctlUint64 = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysctlUint64 = func(name string, _ ...int) (uint64, error) {
+				if name != "hw.cpufrequency" {
+					t.Errorf("unexpected sysctl %q", name)
+				}
+				return tt.hz, tt.err
+			}
+			if got := cpuMHzDarwin(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDarwinModelFormFactor(t *testing.T) {
+	tests := []struct {
+		model, want string
+	}{
+		{"MacBookPro18,3", "laptop"},
+		{"MacBookAir10,1", "laptop"},
+		{"MacBook10,1", "laptop"},
+		{"iMac21,1", "desktop"},
+		{"Macmini9,1", "desktop"},
+		{"MacPro7,1", "desktop"},
+		{"Xserve3,1", "server"},
+		{"Mac13,1", ""}, // Mac Studio, but generic identifier
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := darwinModelFormFactor(tt.model); got != tt.want {
+			t.Errorf("darwinModelFormFactor(%q) = %q; want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestResumeTimeDarwin(t *testing.T) {
+	tests := []struct {
+		name string
+		tv   *unix.Timeval
+		err  error
+		want time.Time
+	}{
+		{"woke", &unix.Timeval{Sec: 1660000000, Usec: 500000}, nil, time.Unix(1660000000, 500000000)},
+		{"never_slept", &unix.Timeval{}, nil, time.Time{}},
+		{"error", nil, unix.ENOENT, time.Time{}},
+	}
+	old := sysctlTimeval
+	defer func() { sysctlTimeval = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysctlTimeval = func(string) (*unix.Timeval, error) { return tt.tv, tt.err }
+			if got := resumeTimeDarwin(); !got.Equal(tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyDarwin(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		err  error
+		want proxyInfo
+	}{
+		{
+			name: "both",
+			out: `<dictionary> {
+  ExceptionsList : <array> {
+    0 : *.local
+    1 : 169.254/16
+  }
+  FTPPassive : 1
+  HTTPEnable : 1
+  HTTPPort : 3128
+  HTTPProxy : proxy.example.com
+  HTTPSEnable : 1
+  HTTPSPort : 3129
+  HTTPSProxy : proxy.example.com
+}
+`,
+			want: proxyInfo{
+				HTTPProxy:  "proxy.example.com:3128",
+				HTTPSProxy: "proxy.example.com:3129",
+				NoProxy:    "*.local,169.254/16",
+			},
+		},
+		{
+			name: "disabled",
+			out: `<dictionary> {
+  ExceptionsList : <array> {
+    0 : *.local
+  }
+  HTTPEnable : 0
+  HTTPPort : 3128
+  HTTPProxy : proxy.example.com
+}
+`,
+			want: proxyInfo{},
+		},
+		{
+			name: "https_only",
+			out: `<dictionary> {
+  HTTPSEnable : 1
+  HTTPSPort : 8080
+  HTTPSProxy : 10.0.0.1
+}
+`,
+			want: proxyInfo{HTTPSProxy: "10.0.0.1:8080"},
+		},
+		{
+			name: "none",
+			out: `<dictionary> {
+  HTTPEnable : 0
+  HTTPSEnable : 0
+}
+`,
+			want: proxyInfo{},
+		},
+		{
+			name: "error",
+			err:  errors.New("exit status 1"),
+			want: proxyInfo{},
+		},
+	}
+	old := scutilProxy
+	defer func() { scutilProxy = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scutilProxy = func() ([]byte, error) { return []byte(tt.out), tt.err }
+			if got := proxyDarwin(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSServersDarwin(t *testing.T) {
+	const out = `DNS configuration
+
+resolver #1
+  search domain[0] : lan
+  nameserver[0] : 192.168.1.1
+  nameserver[1] : fe80::1%en0
+  if_index : 6 (en0)
+  flags    : Request A records, Request AAAA records
+  reach    : 0x00020002 (Reachable,Directly Reachable Address)
+
+resolver #2
+  domain   : local
+  options  : mdns
+  timeout  : 5
+  flags    : Request A records, Request AAAA records
+  reach    : 0x00000000 (Not Reachable)
+  order    : 300000
+
+resolver #3
+  domain   : corp.example.com
+  nameserver[0] : 10.0.0.53
+
+resolver #4
+  nameserver[0] : 192.168.1.1
+  nameserver[1] : 1.1.1.1
+
+DNS configuration (for scoped queries)
+
+resolver #1
+  search domain[0] : lan
+  nameserver[0] : 192.168.1.254
+  if_index : 6 (en0)
+  flags    : Scoped, Request A records
+`
+	old := scutilDNS
+	defer func() { scutilDNS = old }()
+
+	scutilDNS = func() ([]byte, error) { return []byte(out), nil }
+	want := []netip.Addr{
+		netip.MustParseAddr("192.168.1.1"),
+		netip.MustParseAddr("fe80::1%en0"),
+		netip.MustParseAddr("1.1.1.1"),
+	}
+	if got, ok := dnsServersDarwin(); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v; want %v, true", got, ok, want)
+	}
+
+	scutilDNS = func() ([]byte, error) { return []byte("DNS configuration\n\nNo DNS configuration available\n"), nil }
+	if got, ok := dnsServersDarwin(); !ok || len(got) != 0 {
+		t.Errorf("none: got %v, %v; want empty, true", got, ok)
+	}
+
+	scutilDNS = func() ([]byte, error) { return nil, errors.New("exit status 1") }
+	if got, ok := dnsServersDarwin(); ok || got != nil {
+		t.Errorf("error: got %v, %v; want nil, false", got, ok)
+	}
+}
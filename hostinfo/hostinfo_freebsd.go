@@ -19,6 +19,7 @@
 
 func init() {
 	osVersion = osVersionFreebsd
+	fsTypeOf = fsTypeFreebsd
 }
 
 func osVersionFreebsd() string {
@@ -53,3 +54,13 @@ func osVersionFreebsd() string {
 	// the /etc/version files end in a newline
 	return fmt.Sprintf("%s%s", strings.TrimSuffix(version, "\n"), attr)
 }
+
+// fsTypeFreebsd returns the filesystem type name reported by statfs,
+// such as "ufs".
+func fsTypeFreebsd(path string) string {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(st.Fstypename[:])
+}
@@ -10,11 +10,22 @@
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/netip"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
+	"tailscale.com/types/opt"
 	"tailscale.com/util/lineread"
 	"tailscale.com/util/strs"
 	"tailscale.com/version/distro"
@@ -22,7 +33,29 @@
 
 func init() {
 	osVersion = osVersionLinux
+	osDNSServers = dnsServersLinux
 	packageType = packageTypeLinux
+	serviceStatus = serviceStatusLinux
+	rawMachineID = machineIDLinux
+	batteryStatus = batteryStatusLinux
+	fsTypeOf = fsTypeLinux
+	ipv6Status = ipv6StatusLinux
+	getBootTime = bootTimeLinux
+	clockSynced = clockSyncedLinux
+	listGPUs = gpusLinux
+	defaultRoute = defaultRouteLinux
+	isTranslated = translatedLinux
+	diskSpace = diskSpaceLinux
+	debugged = debuggedLinux
+	syncDaemon = timeSyncDaemon
+	tpmStatus = tpmLinux
+	osFirmware = firmwareModeLinux
+	secureBootOn = secureBootLinux
+	cpuSpeed = cpuMHzLinux
+	osFormFactor = formFactorLinux
+	osDMI = dmiLinux
+	hypervisor = hypervisorLinux
+	resumeTime = resumeTimeLinux
 
 	if v := linuxDeviceModel(); v != "" {
 		SetDeviceModel(v)
@@ -141,3 +174,2276 @@ func packageTypeLinux() string {
 	}
 	return ""
 }
+
+// Self-update sources. They're variables so tests can replace them.
+var (
+	osExecutable = os.Executable
+
+	// pkgOwns reports whether the package manager mgr ("dpkg",
+	// "rpm" or "pacman") has a package owning path. It returns an
+	// error if mgr isn't installed or can't be queried, including if
+	// it fails in any way other than reporting that path has no owner.
+	pkgOwns = func(mgr, path string) (bool, error) {
+		args := map[string][]string{
+			"dpkg":   {"-S", path},
+			"rpm":    {"-qf", path},
+			"pacman": {"-Qo", path},
+		}[mgr]
+		bin, err := exec.LookPath(mgr)
+		if err != nil {
+			return false, err
+		}
+		cmd := exec.Command(bin, args...)
+		cmd.Env = append(os.Environ(), "LC_ALL=C") // for pkgNotOwned
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			return true, nil
+		}
+		if ee, ok := err.(*exec.ExitError); ok && pkgNotOwned(mgr, ee.ExitCode(), out) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s %s: %w: %s", mgr, strings.Join(args, " "), err, bytes.TrimSpace(out))
+	}
+)
+
+// pkgNotOwnedMessages are what each package manager prints, in the C
+// locale, when asked about a path that no package owns.
+var pkgNotOwnedMessages = map[string]string{
+	"dpkg":   "no path found matching pattern",
+	"rpm":    "is not owned by any package",
+	"pacman": "No package owns",
+}
+
+// pkgNotOwned reports whether a package manager query that exited with
+// code and printed out means that the path has no owner, as opposed to
+// the query failing. All three exit with status 1 for that, but also
+// for other failures, like the path not existing, so the message is
+// checked too.
+func pkgNotOwned(mgr string, code int, out []byte) bool {
+	msg, ok := pkgNotOwnedMessages[mgr]
+	return ok && code == 1 && bytes.Contains(out, []byte(msg))
+}
+
+// selfUpdateAllowed reports whether tailscaled may update its own
+// binary, which it shouldn't do if the binary belongs to the OS
+// package manager (or is in a snap). It's false for packaged
+// installs, true for standalone (e.g. tarball) installs, and unset if
+// none of the package managers it knows could be queried.
+func selfUpdateAllowed() (ret opt.Bool) {
+	if packageTypeLinux() == "snap" {
+		ret.Set(false)
+		return ret
+	}
+	exe, err := osExecutable()
+	if err != nil {
+		return ""
+	}
+	for _, mgr := range []string{"dpkg", "rpm", "pacman"} {
+		owned, err := pkgOwns(mgr, exe)
+		if err != nil {
+			continue
+		}
+		if owned {
+			ret.Set(false)
+			return ret
+		}
+		ret.Set(true)
+	}
+	return ret
+}
+
+func serviceStatusLinux() opt.Bool {
+	return linuxServiceStatus(os.Getenv("INVOCATION_ID"), hasControllingTTY())
+}
+
+// hasControllingTTY reports whether the process has a controlling
+// terminal. Opening /dev/tty fails with ENXIO if it doesn't.
+func hasControllingTTY() bool {
+	f, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// linuxServiceStatus reports whether a process with the given systemd
+// INVOCATION_ID environment variable and controlling terminal state is
+// running as a service.
+//
+// systemd sets INVOCATION_ID for the processes of every unit it starts,
+// and services don't have a controlling terminal. Without
+// INVOCATION_ID, a process with no terminal might be a container
+// entrypoint, an init script or just nohup, so the answer is unknown.
+func linuxServiceStatus(invocationID string, hasTTY bool) (ret opt.Bool) {
+	switch {
+	case invocationID != "" && !hasTTY:
+		ret.Set(true)
+	case invocationID == "" && hasTTY:
+		ret.Set(false)
+	}
+	return ret
+}
+
+// machineIDFiles are the locations of the systemd/D-Bus machine ID, in
+// order of preference.
+var machineIDFiles = []string{
+	"/etc/machine-id",
+	"/var/lib/dbus/machine-id", // pre-systemd distros
+}
+
+func machineIDLinux() string {
+	for _, path := range machineIDFiles {
+		b, _ := os.ReadFile(path)
+		if s := strings.TrimSpace(string(b)); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// powerSupplyDir is where the kernel lists power supplies. It's a
+// variable so tests can replace it.
+var powerSupplyDir = "/sys/class/power_supply"
+
+// batteryStatusLinux reports whether any system power supply is a
+// battery. With no power supplies listed at all (common in VMs and
+// containers, but also on some desktops) the answer is unknown.
+func batteryStatusLinux() (ret opt.Bool) {
+	ents, err := os.ReadDir(powerSupplyDir)
+	if err != nil || len(ents) == 0 {
+		return ""
+	}
+	for _, ent := range ents {
+		dir := filepath.Join(powerSupplyDir, ent.Name())
+		typ, _ := os.ReadFile(filepath.Join(dir, "type"))
+		if strings.TrimSpace(string(typ)) != "Battery" {
+			continue
+		}
+		// Batteries in peripherals (wireless mice, etc.) have
+		// scope "Device" and don't power the system.
+		scope, _ := os.ReadFile(filepath.Join(dir, "scope"))
+		if strings.TrimSpace(string(scope)) == "Device" {
+			continue
+		}
+		ret.Set(true)
+		return ret
+	}
+	ret.Set(false)
+	return ret
+}
+
+// statfsMagic returns the f_type of the filesystem containing path.
+// It's a variable so tests can replace it.
+var statfsMagic = func(path string) (uint32, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	// The width and signedness of Type varies by architecture, but
+	// the magic numbers are all 32 bits.
+	return uint32(st.Type), nil
+}
+
+// statfs is unix.Statfs, but can be replaced by tests.
+var statfs = unix.Statfs
+
+func diskSpaceLinux(path string) (free, total uint64) {
+	var st unix.Statfs_t
+	if err := statfs(path, &st); err != nil {
+		return 0, 0
+	}
+	// Block counts are in units of the fragment size, which older
+	// kernels don't report.
+	bsize := uint64(st.Frsize)
+	if bsize == 0 {
+		bsize = uint64(st.Bsize)
+	}
+	return st.Bavail * bsize, st.Blocks * bsize
+}
+
+func fsTypeLinux(path string) string {
+	magic, err := statfsMagic(path)
+	if err != nil {
+		return ""
+	}
+	return linuxFSTypeName(magic)
+}
+
+// Filesystem magic numbers missing from x/sys/unix.
+const (
+	aufsSuperMagic = 0x61756673
+	cifsMagic      = 0xff534d42
+	smb2Magic      = 0xfe534d42
+	zfsSuperMagic  = 0x2fc12fc1
+)
+
+// linuxFSTypeName returns the name of the filesystem with the given
+// statfs magic number, or the number in hex if it's not one we know.
+func linuxFSTypeName(magic uint32) string {
+	switch magic {
+	case unix.EXT4_SUPER_MAGIC: // also ext2 and ext3
+		return "ext4"
+	case unix.XFS_SUPER_MAGIC:
+		return "xfs"
+	case unix.BTRFS_SUPER_MAGIC:
+		return "btrfs"
+	case zfsSuperMagic:
+		return "zfs"
+	case unix.F2FS_SUPER_MAGIC:
+		return "f2fs"
+	case unix.JFFS2_SUPER_MAGIC:
+		return "jffs2"
+	case unix.SQUASHFS_MAGIC:
+		return "squashfs"
+	case unix.MSDOS_SUPER_MAGIC:
+		return "vfat"
+	case unix.EXFAT_SUPER_MAGIC:
+		return "exfat"
+	case unix.ECRYPTFS_SUPER_MAGIC:
+		return "ecryptfs"
+	case unix.OVERLAYFS_SUPER_MAGIC:
+		return "overlay"
+	case aufsSuperMagic:
+		return "aufs"
+	case unix.TMPFS_MAGIC:
+		return "tmpfs"
+	case unix.RAMFS_MAGIC:
+		return "ramfs"
+	case unix.FUSE_SUPER_MAGIC:
+		return "fuse"
+	case unix.NFS_SUPER_MAGIC:
+		return "nfs"
+	case cifsMagic:
+		return "cifs"
+	case smb2Magic:
+		return "smb2"
+	case unix.V9FS_MAGIC:
+		return "9p"
+	}
+	return fmt.Sprintf("0x%x", magic)
+}
+
+// Locations of thermal sensor and throttling state. They're variables
+// so tests can replace them.
+var (
+	thermalClassDir = "/sys/class/thermal"
+
+	// rpiThrottledFile is the Raspberry Pi firmware's throttling
+	// state, the same value "vcgencmd get_throttled" reports.
+	rpiThrottledFile = "/sys/devices/platform/soc/soc:firmware/get_throttled"
+)
+
+// Bits of the Raspberry Pi get_throttled value that describe the
+// current state. Higher bits record whether each has occurred since
+// boot.
+const (
+	rpiUnderVoltage = 1 << 0
+	rpiFreqCapped   = 1 << 1
+	rpiThrottled    = 1 << 2
+	rpiSoftTemp     = 1 << 3
+)
+
+// thermalState returns the hottest temperature reported by any thermal
+// zone, in millidegrees Celsius, and whether the board is currently
+// throttling or under-voltage. This is mostly useful on embedded boards
+// like the Raspberry Pi, where bad power supplies and lack of cooling
+// are common causes of flaky connectivity.
+//
+// Both are zero values where unsupported.
+func thermalState() (milliC int, throttled bool) {
+	zones, _ := filepath.Glob(filepath.Join(thermalClassDir, "thermal_zone*", "temp"))
+	for _, zone := range zones {
+		b, err := os.ReadFile(zone)
+		if err != nil {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err == nil && v > milliC {
+			milliC = v
+		}
+	}
+	if b, err := os.ReadFile(rpiThrottledFile); err == nil {
+		v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 16, 32)
+		if err == nil {
+			throttled = v&(rpiUnderVoltage|rpiFreqCapped|rpiThrottled|rpiSoftTemp) != 0
+		}
+	}
+	return milliC, throttled
+}
+
+// resolvConfReadlink is os.Readlink, but can be replaced by tests.
+var resolvConfReadlink = os.Readlink
+
+// dnsMode reports who manages /etc/resolv.conf, to help explain how
+// MagicDNS interacts with the host's DNS configuration. It returns one
+// of:
+//
+//   - "systemd-resolved": a symlink to, or a file generated by,
+//     systemd-resolved
+//   - "NetworkManager": a symlink to, or a file generated by,
+//     NetworkManager
+//   - "resolvconf": a symlink to, or a file generated by, resolvconf
+//   - "systemd-resolved-stub": an unmanaged file that points at
+//     systemd-resolved's stub listener on 127.0.0.53
+//   - "static": an unmanaged file
+//
+// or the empty string if /etc/resolv.conf can't be read.
+func dnsMode() string {
+	const path = "/etc/resolv.conf"
+	target, _ := resolvConfReadlink(path) // "" if not a symlink
+	bs, err := resolvConfRead(path)
+	if err != nil {
+		return ""
+	}
+	return linuxDNSMode(target, bs)
+}
+
+// linuxDNSMode returns the dnsMode for a resolv.conf with the given
+// symlink target (empty if it's not a symlink) and contents.
+func linuxDNSMode(target string, contents []byte) string {
+	switch {
+	case strings.Contains(target, "/systemd/resolve/"):
+		return "systemd-resolved"
+	case strings.Contains(target, "/NetworkManager/"):
+		return "NetworkManager"
+	case strings.Contains(target, "/resolvconf/"):
+		return "resolvconf"
+	}
+
+	owner := ""
+	directives, stub := false, false
+	lineread.Reader(bytes.NewReader(contents), func(line []byte) error {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] == '#' {
+			// Like net/dns, only trust headers before the first
+			// directive.
+			if owner == "" && !directives {
+				switch {
+				case bytes.Contains(line, []byte("systemd-resolved")):
+					owner = "systemd-resolved"
+				case bytes.Contains(line, []byte("NetworkManager")):
+					owner = "NetworkManager"
+				case bytes.Contains(line, []byte("resolvconf")):
+					owner = "resolvconf"
+				}
+			}
+			return nil
+		}
+		directives = directives || len(line) > 0
+		if f := strings.Fields(string(line)); len(f) == 2 && f[0] == "nameserver" && f[1] == "127.0.0.53" {
+			stub = true
+		}
+		return nil
+	})
+	switch {
+	case owner != "":
+		return owner
+	case stub:
+		return "systemd-resolved-stub"
+	}
+	return "static"
+}
+
+// resolvedRunning reports whether systemd-resolved is running. It's
+// a variable so tests can replace it.
+var resolvedRunning = func() (ret opt.Bool) {
+	names, err := procNames()
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	for _, n := range names {
+		if n == "systemd-resolve" { // truncated to 15 bytes
+			ret.Set(true)
+			return ret
+		}
+	}
+	ret.Set(false)
+	return ret
+}
+
+// resolvedStubActive reports whether DNS queries go to
+// systemd-resolved's stub listener: /etc/resolv.conf names 127.0.0.53
+// as a nameserver and systemd-resolved is running to answer there.
+// It's unset if /etc/resolv.conf can't be read, or if it points at the
+// stub but we can't tell whether systemd-resolved is running.
+func resolvedStubActive() (ret opt.Bool) {
+	bs, err := resolvConfRead("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	if !hasStubNameserver(bs) {
+		ret.Set(false)
+		return ret
+	}
+	return resolvedRunning()
+}
+
+// hasStubNameserver reports whether resolv.conf contents bs name
+// systemd-resolved's stub listener as a nameserver.
+func hasStubNameserver(bs []byte) bool {
+	var ret bool
+	lineread.Reader(bytes.NewReader(bs), func(line []byte) error {
+		if f := strings.Fields(string(line)); len(f) >= 2 && f[0] == "nameserver" && f[1] == "127.0.0.53" {
+			ret = true
+			return io.EOF // stop
+		}
+		return nil
+	})
+	return ret
+}
+
+// procSysNet is where the kernel's network sysctls live. It's a
+// variable so tests can replace it.
+var procSysNet = "/proc/sys/net"
+
+// ipv6StatusLinux reports whether IPv6 is enabled. If /proc/sys/net
+// exists but has no ipv6 directory, the ipv6 module isn't loaded or
+// the kernel was booted with ipv6.disable=1, so IPv6 is unavailable. If
+// /proc/sys/net itself is missing (e.g. /proc isn't mounted), we can't
+// tell.
+func ipv6StatusLinux() (ret opt.Bool) {
+	if _, err := os.Stat(procSysNet); err != nil {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Join(procSysNet, "ipv6")); os.IsNotExist(err) {
+		ret.Set(false)
+		return ret
+	} else if err != nil {
+		return ""
+	}
+	b, err := os.ReadFile(filepath.Join(procSysNet, "ipv6", "conf", "all", "disable_ipv6"))
+	if err != nil {
+		return ""
+	}
+	ret.Set(strings.TrimSpace(string(b)) == "0")
+	return ret
+}
+
+// ipForwardingState is whether the kernel forwards packets between
+// interfaces, as a subnet router or exit node needs.
+type ipForwardingState struct {
+	IPv4, IPv6 opt.Bool
+}
+
+// ipForwarding reports the net.ipv4.ip_forward and
+// net.ipv6.conf.all.forwarding sysctls. Each is unset if it can't be
+// read, as when IPv6 is disabled.
+func ipForwarding() ipForwardingState {
+	return ipForwardingState{
+		IPv4: sysctlBool(filepath.Join(procSysNet, "ipv4", "ip_forward")),
+		IPv6: sysctlBool(filepath.Join(procSysNet, "ipv6", "conf", "all", "forwarding")),
+	}
+}
+
+// sysctlBool returns the boolean value of the sysctl file at path,
+// which is set if the file holds a number: true if it's non-zero.
+func sysctlBool(path string) (ret opt.Bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+		ret.Set(v != 0)
+	}
+	return ret
+}
+
+// udpBufferInfo is the most that sockets can set their receive and
+// send buffer sizes to, in bytes.
+type udpBufferInfo struct {
+	RmemMax, WmemMax uint64
+}
+
+// udpBufferLimits reports the net.core.rmem_max and net.core.wmem_max
+// sysctls. At the kernel's usual default of 212992 bytes, bursts of
+// WireGuard packets overflow the buffers, limiting throughput. Each is
+// zero if it can't be read.
+func udpBufferLimits() udpBufferInfo {
+	return udpBufferInfo{
+		RmemMax: sysctlUint(filepath.Join(procSysNet, "core", "rmem_max")),
+		WmemMax: sysctlUint(filepath.Join(procSysNet, "core", "wmem_max")),
+	}
+}
+
+// sysctlUint returns the value of the numeric sysctl file at path, or
+// zero if it can't be read or parsed.
+func sysctlUint(path string) uint64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	v, _ := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	return v
+}
+
+// procNetConntrack lists the netfilter connection tracking table's
+// entries. It's a variable so tests can replace it.
+var procNetConntrack = "/proc/net/nf_conntrack"
+
+// conntrackInfo is the size and usage of the netfilter connection
+// tracking table.
+type conntrackInfo struct {
+	Max   int // net.netfilter.nf_conntrack_max
+	Count int // current number of entries
+}
+
+// conntrack reports the connection tracking table's size and how full
+// it is. Subnet routers and exit nodes track a connection for each
+// flow they forward, and when the table is full, new connections are
+// dropped. Both are zero if the nf_conntrack module isn't loaded.
+func conntrack() conntrackInfo {
+	nf := filepath.Join(procSysNet, "netfilter")
+	ret := conntrackInfo{
+		Max:   int(sysctlUint(filepath.Join(nf, "nf_conntrack_max"))),
+		Count: int(sysctlUint(filepath.Join(nf, "nf_conntrack_count"))),
+	}
+	if ret.Max == 0 || ret.Count > 0 {
+		return ret
+	}
+	// No count sysctl (or an empty table); count the entries instead.
+	lineread.File(procNetConntrack, func([]byte) error {
+		ret.Count++
+		return nil
+	})
+	return ret
+}
+
+// procUptimeFile is the kernel's uptime in seconds. It's a variable so
+// tests can replace it.
+var procUptimeFile = "/proc/uptime"
+
+func bootTimeLinux() time.Time {
+	b, err := os.ReadFile(procUptimeFile)
+	if err != nil {
+		return time.Time{}
+	}
+	// "350735.47 234388.90": uptime, then idle time summed over CPUs.
+	f := strings.Fields(string(b))
+	if len(f) == 0 {
+		return time.Time{}
+	}
+	secs, err := strconv.ParseFloat(f[0], 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-time.Duration(secs * float64(time.Second)))
+}
+
+// adjtimex is unix.Adjtimex, but can be replaced by tests.
+var adjtimex = unix.Adjtimex
+
+// clockUnsyncedMaxError is the kernel's maximum error, in microseconds,
+// at and beyond which the clock is considered unsynchronized. It's
+// 16 seconds, as with NTP_PHASE_MAX.
+const clockUnsyncedMaxError = 16_000_000
+
+// clockSyncedLinux reports whether the kernel considers the clock
+// synchronized, the same way systemd-timedated computes timedatectl's
+// NTPSynchronized property. This works with any NTP daemon (chrony,
+// ntpd, systemd-timesyncd) that disciplines the kernel clock.
+func clockSyncedLinux() (ret opt.Bool) {
+	var tx unix.Timex // zero Modes: read only
+	if _, err := adjtimex(&tx); err != nil {
+		return ""
+	}
+	// Like systemd, ignore STA_UNSYNC, which some daemons set just to
+	// stop the kernel from writing the RTC.
+	ret.Set(int64(tx.Maxerror) < clockUnsyncedMaxError)
+	return ret
+}
+
+// clockSourceDir is where the kernel describes its clock sources. It's
+// a variable so tests can replace it.
+var clockSourceDir = "/sys/devices/system/clocksource/clocksource0"
+
+// clockSource returns the kernel's current clock source, like "tsc",
+// "hpet", "kvm-clock" or "xen", or the empty string if unknown. VMs
+// that fall back from a stable TSC to something like "hpet" or
+// "acpi_pm" have slower, and sometimes jumpy, timers.
+func clockSource() string {
+	return readSysfsString(filepath.Join(clockSourceDir, "current_clocksource"))
+}
+
+// Time sync daemon sources. They're variables so tests can replace
+// them.
+var (
+	// procNames returns the command names (/proc/<pid>/comm) of all
+	// running processes.
+	procNames = func() ([]string, error) {
+		comms, err := filepath.Glob("/proc/[0-9]*/comm")
+		if err != nil {
+			return nil, err
+		}
+		if len(comms) == 0 {
+			return nil, os.ErrNotExist
+		}
+		var names []string
+		for _, comm := range comms {
+			if b, err := os.ReadFile(comm); err == nil {
+				names = append(names, strings.TrimSpace(string(b)))
+			}
+		}
+		return names, nil
+	}
+
+	fileExists = func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+)
+
+// timeSyncDaemons are the time sync daemons timeSyncDaemon knows, with
+// their command names (truncated to 15 bytes, as the kernel does) and
+// the files they leave in /run.
+var timeSyncDaemons = []struct {
+	name    string
+	comm    string
+	markers []string
+}{
+	{"chronyd", "chronyd", []string{"/run/chrony/chronyd.pid", "/var/run/chronyd.pid"}},
+	{"systemd-timesyncd", "systemd-timesyn", []string{"/run/systemd/timesync/synchronized"}},
+	{"openntpd", "openntpd", []string{"/var/run/ntpd.sock"}}, // control socket
+	{"ntpd", "ntpd", []string{"/run/ntpd.pid", "/var/run/ntpd.pid"}},
+}
+
+// timeSyncDaemon returns which time sync daemon is running: "chronyd",
+// "ntpd", "systemd-timesyncd", "openntpd", or the empty string if none
+// is found. It looks for running processes, falling back to the files
+// the daemons leave behind when other users' processes are hidden (as
+// with /proc mounted hidepid=2).
+func timeSyncDaemon() string {
+	if names, err := procNames(); err == nil {
+		running := map[string]bool{}
+		for _, n := range names {
+			running[n] = true
+		}
+		for _, d := range timeSyncDaemons {
+			if running[d.comm] {
+				return d.name
+			}
+		}
+	}
+	for _, d := range timeSyncDaemons {
+		for _, m := range d.markers {
+			if fileExists(m) {
+				return d.name
+			}
+		}
+	}
+	return ""
+}
+
+// Where the kernel lists PCI devices and DRM (graphics) devices. They're
+// variables so tests can replace them.
+var (
+	pciDevicesDir = "/sys/bus/pci/devices"
+	drmClassDir   = "/sys/class/drm"
+)
+
+// pciVendors names the PCI vendor IDs of common GPUs, including the
+// emulated ones of popular hypervisors.
+var pciVendors = map[string]string{
+	"0x1002": "AMD",
+	"0x10de": "NVIDIA",
+	"0x8086": "Intel",
+	"0x102b": "Matrox",
+	"0x1a03": "ASPEED",
+	"0x1234": "QEMU",
+	"0x1414": "Microsoft",
+	"0x15ad": "VMware",
+	"0x1af4": "virtio",
+	"0x80ee": "VirtualBox",
+}
+
+// gpusLinux returns the display controllers on the PCI bus, or, on
+// systems without PCI GPUs (like most ARM boards), the drivers of the
+// DRM devices.
+func gpusLinux() []string {
+	if ret := pciGPUs(); len(ret) > 0 {
+		return ret
+	}
+	return drmDrivers()
+}
+
+func readSysfsString(path string) string {
+	b, _ := os.ReadFile(path)
+	return strings.TrimSpace(string(b))
+}
+
+func pciGPUs() []string {
+	ents, _ := os.ReadDir(pciDevicesDir)
+	var ret []string
+	for _, ent := range ents {
+		dir := filepath.Join(pciDevicesDir, ent.Name())
+		// PCI base class 0x03 is "display controller".
+		if !strings.HasPrefix(readSysfsString(filepath.Join(dir, "class")), "0x03") {
+			continue
+		}
+		vendor := readSysfsString(filepath.Join(dir, "vendor"))
+		device := readSysfsString(filepath.Join(dir, "device"))
+		name, ok := pciVendors[vendor]
+		if !ok {
+			name = "unknown"
+		}
+		ret = append(ret, fmt.Sprintf("%s [%s:%s]", name, strings.TrimPrefix(vendor, "0x"), strings.TrimPrefix(device, "0x")))
+	}
+	return ret
+}
+
+func drmDrivers() []string {
+	cards, _ := filepath.Glob(filepath.Join(drmClassDir, "card[0-9]*", "device", "uevent"))
+	var ret []string
+	seen := map[string]bool{}
+	for _, uevent := range cards {
+		lineread.File(uevent, func(line []byte) error {
+			if driver, ok := strs.CutPrefix(string(line), "DRIVER="); ok && !seen[driver] {
+				seen[driver] = true
+				ret = append(ret, driver)
+			}
+			return nil
+		})
+	}
+	return ret
+}
+
+// containerInfo describes the container we're running in, if any.
+type containerInfo struct {
+	Runtime      string // "docker", "containerd", "cri-o", "podman", or "" if unknown
+	Orchestrator string // "kubernetes", "nomad", or "none"
+}
+
+// procSelfCgroup is the cgroup membership file for this process. It's
+// a variable so tests can replace it.
+var procSelfCgroup = "/proc/self/cgroup"
+
+// containerRuntime returns the container runtime and orchestrator
+// we're running under, or the zero value if we're not containerized
+// as far as we can tell.
+func containerRuntime() containerInfo {
+	bs, _ := os.ReadFile(procSelfCgroup)
+	return linuxContainerRuntime(bs, os.Getenv)
+}
+
+// linuxContainerRuntime returns the containerRuntime for the given
+// /proc/self/cgroup contents and environment.
+//
+// With cgroup v2 and a private cgroup namespace (the default on
+// modern Docker), the cgroup path is just "/", so the environment is
+// often the only clue.
+func linuxContainerRuntime(cgroup []byte, getenv func(string) string) containerInfo {
+	var ret containerInfo
+	kubepods := false
+	lineread.Reader(bytes.NewReader(cgroup), func(line []byte) error {
+		// Lines are "hierarchy-ID:controllers:path".
+		f := bytes.SplitN(line, []byte(":"), 3)
+		if len(f) != 3 {
+			return nil
+		}
+		path := string(f[2])
+		if strings.Contains(path, "kubepods") {
+			kubepods = true
+		}
+		if ret.Runtime == "" {
+			ret.Runtime = cgroupRuntime(path)
+		}
+		return nil
+	})
+	if ret.Runtime == "" && getenv("container") == "podman" {
+		ret.Runtime = "podman"
+	}
+
+	switch {
+	case kubepods || getenv("KUBERNETES_SERVICE_HOST") != "":
+		ret.Orchestrator = "kubernetes"
+	case getenv("NOMAD_ALLOC_ID") != "":
+		ret.Orchestrator = "nomad"
+	case ret.Runtime != "":
+		ret.Orchestrator = "none"
+	}
+	return ret
+}
+
+// sysFSCgroup is where the cgroup hierarchies are mounted. It's a
+// variable so tests can replace it.
+var sysFSCgroup = "/sys/fs/cgroup"
+
+// containerLimitInfo is the memory and CPU limits of our cgroup, as
+// set for containers.
+type containerLimitInfo struct {
+	MemoryLimitBytes uint64  // 0 if unlimited
+	CPUQuotaCores    float64 // CPU time allowed, in cores; 0 if unlimited
+}
+
+// containerLimits returns the memory and CPU limits of our cgroup, or
+// of its closest ancestor that has them, using cgroup v1 for each
+// controller it's mounted for and v2 otherwise. Limits are zero if
+// unlimited or unknown.
+func containerLimits() (ret containerLimitInfo) {
+	bs, _ := os.ReadFile(procSelfCgroup)
+	var v2Path, memPath, cpuPath string
+	var v2, memV1, cpuV1 bool
+	lineread.Reader(bytes.NewReader(bs), func(line []byte) error {
+		// Lines are "hierarchy-ID:controllers:path"; the v2 one is
+		// "0::path".
+		f := strings.SplitN(string(line), ":", 3)
+		if len(f) != 3 {
+			return nil
+		}
+		if f[0] == "0" && f[1] == "" {
+			v2, v2Path = true, f[2]
+			return nil
+		}
+		for _, c := range strings.Split(f[1], ",") {
+			switch c {
+			case "memory":
+				memV1, memPath = true, f[2]
+			case "cpu":
+				cpuV1, cpuPath = true, f[2]
+			}
+		}
+		return nil
+	})
+
+	switch {
+	case memV1:
+		ret.MemoryLimitBytes = cgroupMemoryLimit(filepath.Join(sysFSCgroup, "memory"), memPath, false)
+	case v2:
+		ret.MemoryLimitBytes = cgroupMemoryLimit(sysFSCgroup, v2Path, true)
+	}
+	switch {
+	case cpuV1:
+		ret.CPUQuotaCores = cgroupCPUQuota(filepath.Join(sysFSCgroup, "cpu"), cpuPath, false)
+	case v2:
+		ret.CPUQuotaCores = cgroupCPUQuota(sysFSCgroup, v2Path, true)
+	}
+	return ret
+}
+
+// cgroupDirs returns the directory of the cgroup path in the
+// hierarchy mounted at root, followed by those of its ancestors up to
+// root, whose limits also apply. In a container with its own cgroup
+// namespace, path is "/" and root is the container's cgroup, but
+// without one, path is the host's path, which isn't mounted in the
+// container, so then only root is returned.
+func cgroupDirs(root, path string) []string {
+	dir := filepath.Join(root, path)
+	if _, err := os.Stat(dir); err != nil {
+		return []string{root}
+	}
+	var ret []string
+	for ; len(dir) > len(root); dir = filepath.Dir(dir) {
+		ret = append(ret, dir)
+	}
+	return append(ret, root)
+}
+
+// cgroupMemoryLimit returns the smallest memory limit of the cgroup
+// path or its ancestors, or 0 if they're unlimited: from memory.max
+// in cgroup v2, where "max" means unlimited, or memory.limit_in_bytes
+// in v1, where unlimited is a huge number.
+func cgroupMemoryLimit(root, path string, v2 bool) uint64 {
+	var ret uint64
+	for _, dir := range cgroupDirs(root, path) {
+		var v string
+		if v2 {
+			v = readSysfsString(filepath.Join(dir, "memory.max"))
+		} else {
+			v = readSysfsString(filepath.Join(dir, "memory.limit_in_bytes"))
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || n >= 1<<62 { // "max", or v1's page-rounded LONG_MAX
+			continue
+		}
+		if ret == 0 || n < ret {
+			ret = n
+		}
+	}
+	return ret
+}
+
+// cgroupCPUQuota returns the smallest CPU quota of the cgroup path or
+// its ancestors, in cores, or 0 if they're unlimited: from cpu.max
+// ("quota period", or "max period" if unlimited) in cgroup v2, or
+// cpu.cfs_quota_us (-1 if unlimited) and cpu.cfs_period_us in v1.
+func cgroupCPUQuota(root, path string, v2 bool) float64 {
+	var ret float64
+	for _, dir := range cgroupDirs(root, path) {
+		var quota, period string
+		if v2 {
+			quota, period, _ = strings.Cut(readSysfsString(filepath.Join(dir, "cpu.max")), " ")
+		} else {
+			quota = readSysfsString(filepath.Join(dir, "cpu.cfs_quota_us"))
+			period = readSysfsString(filepath.Join(dir, "cpu.cfs_period_us"))
+		}
+		q, err1 := strconv.ParseUint(quota, 10, 64)
+		p, err2 := strconv.ParseUint(period, 10, 64)
+		if err1 != nil || err2 != nil || p == 0 {
+			continue
+		}
+		if cores := float64(q) / float64(p); ret == 0 || cores < ret {
+			ret = cores
+		}
+	}
+	return ret
+}
+
+// systemdContainerFile is where systemd records the container manager
+// it's running under ("systemd-nspawn", "docker", "lxc", etc). systemd
+// only writes it inside containers. It's a variable so tests can
+// replace it.
+var systemdContainerFile = "/run/systemd/container"
+
+// systemdContainerManager returns the name of the container manager,
+// following systemd's container interface: from the container
+// environment variable (set for PID 1, and so usually only visible to
+// us when we are PID 1), or else /run/systemd/container. The name is
+// empty if we're not in a container; ok is false if we can't tell,
+// because systemd isn't running.
+func systemdContainerManager() (name string, ok bool) {
+	if v := os.Getenv("container"); v != "" {
+		return v, true
+	}
+	b, err := os.ReadFile(systemdContainerFile)
+	if err == nil {
+		return strings.TrimSpace(string(b)), true
+	}
+	if _, err := os.Stat(filepath.Dir(systemdContainerFile)); err == nil {
+		return "", true
+	}
+	return "", false
+}
+
+// inNspawn reports whether we're in a systemd-nspawn container (which
+// includes machinectl-managed ones), as opposed to an OCI container
+// or none at all.
+func inNspawn() (ret opt.Bool) {
+	name, ok := systemdContainerManager()
+	if !ok {
+		return ""
+	}
+	ret.Set(name == "systemd-nspawn")
+	return ret
+}
+
+// cgroupRuntime returns the container runtime that created the cgroup
+// path, or the empty string if it's not recognizably a container's.
+// Runtimes using the systemd cgroup driver name their scopes
+// "<prefix>-<id>.scope"; with the cgroupfs driver they're directories.
+func cgroupRuntime(path string) string {
+	switch {
+	case strings.Contains(path, "/libpod-") || strings.Contains(path, "/libpod_parent/"):
+		return "podman"
+	case strings.Contains(path, "/crio-"):
+		return "cri-o"
+	case strings.Contains(path, "/cri-containerd-") || strings.Contains(path, "/containerd/"):
+		return "containerd"
+	case strings.Contains(path, "/docker-") || strings.Contains(path, "/docker/"):
+		return "docker"
+	}
+	return ""
+}
+
+// procSelfStatus is this process's status file. It's a variable so
+// tests can replace it.
+var procSelfStatus = "/proc/self/status"
+
+// procStatusField returns the value of the named field of
+// /proc/self/status, such as "CapEff", or the empty string if it's
+// missing or the file can't be read.
+func procStatusField(name string) string {
+	var ret string
+	prefix := name + ":"
+	lineread.File(procSelfStatus, func(line []byte) error {
+		if rest, ok := strs.CutPrefix(string(line), prefix); ok {
+			ret = strings.TrimSpace(rest)
+			return io.EOF // stop
+		}
+		return nil
+	})
+	return ret
+}
+
+// hasNetAdmin reports whether this process has CAP_NET_ADMIN in its
+// effective capability set, which it needs to create and configure
+// network interfaces. It's unset if /proc/self/status can't be read
+// or parsed.
+func hasNetAdmin() (ret opt.Bool) {
+	caps, err := strconv.ParseUint(procStatusField("CapEff"), 16, 64)
+	if err == nil {
+		ret.Set(caps&(1<<unix.CAP_NET_ADMIN) != 0)
+	}
+	return ret
+}
+
+// debuggedLinux reports whether this process is being ptraced, by a
+// debugger or by strace and the like.
+func debuggedLinux() (ret opt.Bool) {
+	pid, err := strconv.Atoi(procStatusField("TracerPid"))
+	if err == nil {
+		ret.Set(pid != 0)
+	}
+	return ret
+}
+
+// Firewall backend sources. They're variables so tests can replace
+// them.
+var (
+	// iptablesPath returns the resolved path of the iptables binary.
+	iptablesPath = func() (string, error) {
+		p, err := exec.LookPath("iptables")
+		if err != nil {
+			return "", err
+		}
+		return filepath.EvalSymlinks(p)
+	}
+
+	// nftHasTables reports whether any nftables tables exist.
+	nftHasTables = func() bool {
+		out, err := exec.Command("nft", "list", "tables").Output()
+		return err == nil && len(bytes.TrimSpace(out)) > 0
+	}
+)
+
+// firewallBackend returns which netfilter interface the host's
+// firewall uses:
+//
+//   - "iptables-legacy": iptables using the xtables kernel API
+//   - "iptables-nft": iptables translated to nftables rules
+//   - "nftables": nftables without iptables installed
+//
+// or the empty string if it can't tell. iptables is identified by the
+// multi-call binary that Debian-style alternatives and most distros'
+// packaging resolve it to.
+func firewallBackend() string {
+	if p, err := iptablesPath(); err == nil {
+		switch base := filepath.Base(p); {
+		case strings.Contains(base, "nft"):
+			return "iptables-nft"
+		case strings.Contains(base, "legacy"):
+			return "iptables-legacy"
+		}
+		return ""
+	}
+	if nftHasTables() {
+		return "nftables"
+	}
+	return ""
+}
+
+// The kernel's routing tables. They're variables so tests can replace
+// them.
+var (
+	procNetRoute     = "/proc/net/route"
+	procNetIPv6Route = "/proc/net/ipv6_route"
+)
+
+// Route flags from <linux/route.h>.
+const (
+	rtfUp     = 0x0001
+	rtfReject = 0x0200
+)
+
+// defaultRouteLinux returns the interface of the IPv4 default route,
+// or failing that the IPv6 one, or the empty string if there's
+// neither. It's an error if neither routing table can be read.
+func defaultRouteLinux() (iface string, err error) {
+	// $ cat /proc/net/route
+	// Iface   Destination  Gateway   Flags  RefCnt  Use  Metric  Mask      MTU  Window  IRTT
+	// eth0    00000000     0102A8C0  0003   0       0    100     00000000  0    0       0
+	err4 := lineread.File(procNetRoute, func(line []byte) error {
+		f := strings.Fields(string(line))
+		if len(f) < 8 || f[1] != "00000000" || f[7] != "00000000" {
+			return nil
+		}
+		if flags, err := strconv.ParseUint(f[3], 16, 32); err == nil && flags&rtfUp != 0 {
+			iface = f[0]
+			return io.EOF // stop
+		}
+		return nil
+	})
+	if iface != "" {
+		return iface, nil
+	}
+	// $ cat /proc/net/ipv6_route
+	// dest                             plen src                              plen next-hop                         metric   refcnt   use      flags    iface
+	// 00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 eth0
+	err6 := lineread.File(procNetIPv6Route, func(line []byte) error {
+		f := strings.Fields(string(line))
+		if len(f) < 10 || strings.Trim(f[0], "0") != "" || f[1] != "00" {
+			return nil
+		}
+		// The kernel keeps a rejecting default route on lo when
+		// there's no real one.
+		if flags, err := strconv.ParseUint(f[8], 16, 32); err == nil && flags&rtfUp != 0 && flags&rtfReject == 0 {
+			iface = f[9]
+			return io.EOF
+		}
+		return nil
+	})
+	if iface == "" && err4 != nil && err6 != nil {
+		return "", err4
+	}
+	return iface, nil
+}
+
+// QEMU user-mode emulation sources. They're variables so tests can
+// replace them.
+var (
+	environ         = os.Environ
+	selfExeReadlink = func() (string, error) { return os.Readlink("/proc/self/exe") }
+)
+
+// translatedLinux reports whether we're running under QEMU user-mode
+// emulation, either configured through QEMU_* environment variables
+// or with qemu itself as the executable (when it's run directly
+// rather than through binfmt_misc).
+//
+// When binfmt_misc runs qemu for us, neither shows, so it only reports
+// false if binfmt_misc has no enabled QEMU handler for our own
+// architecture, and is otherwise unset. It's also unset if
+// /proc/self/exe can't be read.
+func translatedLinux() (ret opt.Bool) {
+	for _, kv := range environ() {
+		if strings.HasPrefix(kv, "QEMU_") {
+			ret.Set(true)
+			return ret
+		}
+	}
+	exe, err := selfExeReadlink()
+	if err != nil {
+		return ""
+	}
+	if strings.HasPrefix(filepath.Base(exe), "qemu-") {
+		ret.Set(true)
+		return ret
+	}
+	registered, ok := qemuBinfmtRegistered(runtime.GOARCH)
+	if !ok || registered {
+		return ""
+	}
+	ret.Set(false)
+	return ret
+}
+
+// binfmtMiscDir is where binfmt_misc lists its handlers. It's a
+// variable so tests can replace it.
+var binfmtMiscDir = "/proc/sys/fs/binfmt_misc"
+
+// qemuArch maps GOARCH values to the architecture names in QEMU's
+// user-mode emulators and the binfmt_misc handlers that run them, as
+// in qemu-aarch64.
+var qemuArch = map[string]string{
+	"386":      "i386",
+	"amd64":    "x86_64",
+	"arm":      "arm",
+	"arm64":    "aarch64",
+	"loong64":  "loongarch64",
+	"mips":     "mips",
+	"mipsle":   "mipsel",
+	"mips64":   "mips64",
+	"mips64le": "mips64el",
+	"ppc64":    "ppc64",
+	"ppc64le":  "ppc64le",
+	"riscv64":  "riscv64",
+	"s390x":    "s390x",
+}
+
+// qemuBinfmtRegistered reports whether binfmt_misc has an enabled QEMU
+// handler for binaries of goarch. The ok result is false if that can't
+// be told, because binfmt_misc isn't mounted or readable or goarch has
+// no known QEMU name.
+func qemuBinfmtRegistered(goarch string) (registered, ok bool) {
+	status, err := os.ReadFile(filepath.Join(binfmtMiscDir, "status"))
+	if err != nil {
+		return false, false
+	}
+	if strings.TrimSpace(string(status)) != "enabled" {
+		return false, true
+	}
+	arch, known := qemuArch[goarch]
+	if !known {
+		return false, false
+	}
+	handler, err := os.ReadFile(filepath.Join(binfmtMiscDir, "qemu-"+arch))
+	if os.IsNotExist(err) {
+		return false, true
+	}
+	if err != nil {
+		return false, false
+	}
+	state, _, _ := strings.Cut(string(handler), "\n")
+	return state == "enabled", true
+}
+
+// sysModuleDir is where the kernel lists loaded and built-in modules.
+// It's a variable so tests can replace it.
+var sysModuleDir = "/sys/module"
+
+// wireguardKernel reports whether the kernel has WireGuard, either
+// built in or as a loaded module, and if so its version, such as
+// "1.0.0". It's unset if /sys/module can't be read.
+//
+// WireGuard declares a module version, so it's listed in /sys/module
+// even when built in, and that's all this checks; it doesn't try to
+// load the module.
+func wireguardKernel() (ret opt.Bool, version string) {
+	if _, err := os.Stat(sysModuleDir); err != nil {
+		return "", ""
+	}
+	dir := filepath.Join(sysModuleDir, "wireguard")
+	if _, err := os.Stat(dir); err != nil {
+		ret.Set(false)
+		return ret, ""
+	}
+	ret.Set(true)
+	return ret, readSysfsString(filepath.Join(dir, "version"))
+}
+
+// Where the kernel lists TPMs. The tpmrm class is the in-kernel
+// resource manager, which only TPM 2.0 devices have. They're variables
+// so tests can replace them.
+var (
+	tpmClassDir   = "/sys/class/tpm"
+	tpmrmClassDir = "/sys/class/tpmrm"
+)
+
+// tpmLinux reports the first TPM the kernel knows. Its version comes
+// from tpm_version_major (Linux 5.6+), or else from whether it has a
+// resource manager. Present is unset if the kernel has no TPM support
+// at all, as the tpm class is missing then.
+func tpmLinux() (ret tpmInfo) {
+	ents, err := os.ReadDir(tpmClassDir)
+	if err != nil {
+		return tpmInfo{}
+	}
+	if len(ents) == 0 {
+		ret.Present.Set(false)
+		return ret
+	}
+	ret.Present.Set(true)
+	name := ents[0].Name() // "tpm0"
+	switch readSysfsString(filepath.Join(tpmClassDir, name, "tpm_version_major")) {
+	case "2":
+		ret.Version = "2.0"
+	case "1":
+		ret.Version = "1.2"
+	case "":
+		if _, err := os.Stat(filepath.Join(tpmrmClassDir, "tpmrm"+strings.TrimPrefix(name, "tpm"))); err == nil {
+			ret.Version = "2.0"
+		}
+	}
+	return ret
+}
+
+// efiDir exists when the kernel was booted by UEFI firmware. It's a
+// variable so tests can replace it.
+var efiDir = "/sys/firmware/efi"
+
+func firmwareModeLinux() string {
+	return linuxFirmwareMode(runtime.GOARCH)
+}
+
+// linuxFirmwareMode returns the firmware mode of a goarch machine.
+// Without EFI, only x86 has a legacy BIOS; other platforms boot
+// through things like U-Boot or device trees, so we don't guess.
+func linuxFirmwareMode(goarch string) string {
+	if _, err := os.Stat(efiDir); err == nil {
+		return "uefi"
+	}
+	if goarch != "amd64" && goarch != "386" {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Dir(efiDir)); err != nil {
+		return "" // no sysfs; can't tell
+	}
+	return "bios"
+}
+
+// secureBootEFIVar is the EFI global variable holding the Secure Boot
+// state, relative to efiDir.
+const secureBootEFIVar = "efivars/SecureBoot-8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+// secureBootLinux reads the SecureBoot EFI variable, whose contents
+// are 4 bytes of attributes followed by a 1 byte value.
+func secureBootLinux() (ret opt.Bool) {
+	b, err := os.ReadFile(filepath.Join(efiDir, secureBootEFIVar))
+	if err != nil || len(b) != 5 {
+		return ""
+	}
+	ret.Set(b[4] == 1)
+	return ret
+}
+
+// procMeminfo and procPressureMemory are the kernel's memory usage and
+// memory pressure stall information (PSI) files. They're variables so
+// tests can replace them.
+var (
+	procMeminfo        = "/proc/meminfo"
+	procPressureMemory = "/proc/pressure/memory"
+)
+
+// memPressureInfo is the host's swap configuration and whether it's
+// short of memory.
+type memPressureInfo struct {
+	SwapTotal uint64 // bytes
+	SwapFree  uint64 // bytes
+
+	// UnderPressure is whether, over the last 10 seconds, tasks were
+	// stalled waiting on memory at least memPressureThreshold percent
+	// of the time. It's unset if PSI isn't available (kernels before
+	// 4.20, or built without CONFIG_PSI).
+	UnderPressure opt.Bool
+}
+
+// memPressureThreshold is the PSI "some" avg10 percentage at or above
+// which memPressure reports the host as under pressure.
+const memPressureThreshold = 10.0
+
+// memPressure returns the swap configuration and memory pressure, to
+// help explain the OOM killer taking out tailscaled.
+func memPressure() (ret memPressureInfo) {
+	lineread.File(procMeminfo, func(line []byte) error {
+		f := strings.Fields(string(line)) // "SwapTotal:  2097148 kB"
+		if len(f) < 2 {
+			return nil
+		}
+		var dst *uint64
+		switch f[0] {
+		case "SwapTotal:":
+			dst = &ret.SwapTotal
+		case "SwapFree:":
+			dst = &ret.SwapFree
+		default:
+			return nil
+		}
+		if v, err := strconv.ParseUint(f[1], 10, 64); err == nil {
+			if len(f) > 2 && f[2] == "kB" {
+				v *= 1024
+			}
+			*dst = v
+		}
+		return nil
+	})
+	lineread.File(procPressureMemory, func(line []byte) error {
+		// "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+		f := strings.Fields(string(line))
+		if len(f) < 2 || f[0] != "some" {
+			return nil
+		}
+		if v, ok := strs.CutPrefix(f[1], "avg10="); ok {
+			if pct, err := strconv.ParseFloat(v, 64); err == nil {
+				ret.UnderPressure.Set(pct >= memPressureThreshold)
+			}
+		}
+		return io.EOF // stop
+	})
+	return ret
+}
+
+// procCPUInfo and cpuFreqDir are where the kernel reports the CPU's
+// clock speed. They're variables so tests can replace them.
+var (
+	procCPUInfo = "/proc/cpuinfo"
+	cpuFreqDir  = "/sys/devices/system/cpu/cpu0/cpufreq"
+)
+
+// cpuMHzLinux returns the first CPU's clock speed. The current speed
+// comes from /proc/cpuinfo's "cpu MHz", which only x86 has, falling
+// back to cpufreq's scaling_cur_freq. The nominal speed is cpufreq's
+// base_frequency (from intel_pstate), falling back to
+// cpuinfo_max_freq.
+func cpuMHzLinux() (ret cpuClock) {
+	lineread.File(procCPUInfo, func(line []byte) error {
+		k, v, ok := strings.Cut(string(line), ":") // "cpu MHz		: 2893.202"
+		if !ok || strings.TrimSpace(k) != "cpu MHz" {
+			return nil
+		}
+		if mhz, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			ret.Current = mhz
+		}
+		return io.EOF // stop
+	})
+	if ret.Current == 0 {
+		ret.Current = cpuFreqMHz("scaling_cur_freq")
+	}
+	ret.Nominal = cpuFreqMHz("base_frequency")
+	if ret.Nominal == 0 {
+		ret.Nominal = cpuFreqMHz("cpuinfo_max_freq")
+	}
+	return ret
+}
+
+// cpuFreqMHz returns the value of the named cpufreq file, which is in
+// kHz, in MHz. It returns 0 if the file is missing or unparseable.
+func cpuFreqMHz(name string) float64 {
+	khz, err := strconv.ParseUint(readSysfsString(filepath.Join(cpuFreqDir, name)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return float64(khz) / 1000
+}
+
+// sysNodeDir and sysCPUOnline are where the kernel lists NUMA nodes
+// and online CPUs. They're variables so tests can replace them.
+var (
+	sysNodeDir   = "/sys/devices/system/node"
+	sysCPUOnline = "/sys/devices/system/cpu/online"
+)
+
+// numaInfo is the host's NUMA topology.
+type numaInfo struct {
+	Nodes       int     // number of NUMA nodes
+	CPUsPerNode [][]int // CPUs of each node, by node number; a node can have none
+}
+
+// numaTopology returns the host's NUMA nodes and their CPUs. Without
+// NUMA support, as on most small machines, it reports a single node
+// with all the online CPUs.
+func numaTopology() numaInfo {
+	dirs, _ := filepath.Glob(filepath.Join(sysNodeDir, "node[0-9]*"))
+	var nodes []int
+	for _, d := range dirs {
+		if n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(d), "node")); err == nil {
+			nodes = append(nodes, n)
+		}
+	}
+	if len(nodes) == 0 {
+		cpus, err := parseCPUList(readSysfsString(sysCPUOnline))
+		if err != nil || len(cpus) == 0 {
+			cpus = nil
+			for i := 0; i < runtime.NumCPU(); i++ {
+				cpus = append(cpus, i)
+			}
+		}
+		return numaInfo{Nodes: 1, CPUsPerNode: [][]int{cpus}}
+	}
+	sort.Ints(nodes)
+	ret := numaInfo{Nodes: len(nodes)}
+	for _, n := range nodes {
+		cpus, _ := parseCPUList(readSysfsString(filepath.Join(sysNodeDir, fmt.Sprintf("node%d/cpulist", n))))
+		ret.CPUsPerNode = append(ret.CPUsPerNode, cpus)
+	}
+	return ret
+}
+
+// parseCPUList parses a kernel CPU list like "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ret []int
+	for _, r := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU list %q", s)
+			}
+		}
+		for c := first; c <= last; c++ {
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
+
+// dmiDir is where the kernel exposes the SMBIOS (DMI) tables, which
+// only machines with SMBIOS (generally x86) have. It's a variable so
+// tests can replace it.
+var dmiDir = "/sys/class/dmi/id"
+
+// formFactorLinux returns the form factor from the DMI chassis type.
+func formFactorLinux() string {
+	v, err := strconv.Atoi(readSysfsString(filepath.Join(dmiDir, "chassis_type")))
+	if err != nil {
+		return ""
+	}
+	return chassisFormFactor(v)
+}
+
+func dmiLinux() dmiInfo {
+	return dmiInfo{
+		BIOSVendor:      readSysfsString(filepath.Join(dmiDir, "bios_vendor")),
+		SysVendor:       readSysfsString(filepath.Join(dmiDir, "sys_vendor")),
+		ProductName:     readSysfsString(filepath.Join(dmiDir, "product_name")),
+		ChassisAssetTag: readSysfsString(filepath.Join(dmiDir, "chassis_asset_tag")),
+	}
+}
+
+// hypervisorLinux reports the CPUID hypervisor bit, which the kernel
+// lists as the "hypervisor" flag in /proc/cpuinfo on x86. It's unset
+// on other architectures.
+func hypervisorLinux() (ret opt.Bool) {
+	lineread.File(procCPUInfo, func(line []byte) error {
+		k, v, ok := strings.Cut(string(line), ":")
+		if !ok || strings.TrimSpace(k) != "flags" {
+			return nil
+		}
+		ret.Set(false)
+		for _, f := range strings.Fields(v) {
+			if f == "hypervisor" {
+				ret.Set(true)
+			}
+		}
+		return io.EOF // stop
+	})
+	return ret
+}
+
+// etcHostname and etcMachineInfo are where systemd-hostnamed keeps the
+// static and pretty hostnames. They're variables so tests can replace
+// them.
+var (
+	etcHostname    = "/etc/hostname"
+	etcMachineInfo = "/etc/machine-info"
+)
+
+// hostnames are the host's hostnames, as systemd-hostnamed defines
+// them.
+type hostnames struct {
+	Static    string // configured in /etc/hostname
+	Transient string // the kernel's current hostname, maybe from DHCP
+	Pretty    string // free-form, for display, like "Alice's Laptop"
+}
+
+// hostnameSource returns the host's static, transient and pretty
+// hostnames. Each is empty if unavailable. A transient hostname that
+// differs from the static one was set at runtime, by DHCP or by hand.
+func hostnameSource() (ret hostnames) {
+	lineread.File(etcHostname, func(line []byte) error {
+		if s := strings.TrimSpace(string(line)); s != "" && !strings.HasPrefix(s, "#") {
+			ret.Static = s
+			return io.EOF // stop
+		}
+		return nil
+	})
+	ret.Transient, _ = osHostname()
+	lineread.File(etcMachineInfo, func(line []byte) error {
+		if v, ok := strs.CutPrefix(string(line), "PRETTY_HOSTNAME="); ok {
+			ret.Pretty = strings.Trim(strings.TrimSpace(v), `"'`)
+			return io.EOF // stop
+		}
+		return nil
+	})
+	return ret
+}
+
+// suspendedTotal returns how long the system has spent suspended since
+// boot: the difference between CLOCK_BOOTTIME, which counts time
+// asleep, and CLOCK_MONOTONIC, which doesn't. It's a variable so tests
+// can replace it.
+var suspendedTotal = func() (time.Duration, error) {
+	var boot, mono unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_BOOTTIME, &boot); err != nil {
+		return 0, err
+	}
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &mono); err != nil {
+		return 0, err
+	}
+	return time.Duration(boot.Nano() - mono.Nano()), nil
+}
+
+// resumeSlop is how much suspendedTotal must grow between calls to
+// count as a suspend, as the two clocks aren't read atomically.
+const resumeSlop = 100 * time.Millisecond
+
+var resumeState struct {
+	mu         sync.Mutex
+	sampled    bool
+	suspended  time.Duration // suspendedTotal as of the last call
+	lastResume time.Time
+}
+
+// resumeTimeLinux returns when the system last resumed, as far as it
+// can tell. Linux doesn't record that anywhere, so it's detected by
+// the total time suspended going up between calls, and dated to the
+// time of the first call that notices. Resumes before the first call
+// aren't reported, so callers that care should call it periodically.
+func resumeTimeLinux() time.Time {
+	total, err := suspendedTotal()
+	if err != nil {
+		return time.Time{}
+	}
+	rs := &resumeState
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.sampled && total-rs.suspended > resumeSlop {
+		rs.lastResume = time.Now()
+	}
+	rs.sampled, rs.suspended = true, total
+	return rs.lastResume
+}
+
+// sysClassNet is where the kernel lists network interfaces. It's a
+// variable so tests can replace it.
+var sysClassNet = "/sys/class/net"
+
+// netAdapters returns the hardware behind each network interface, like
+// "eth0: e1000e (pci 8086:15bb)" or "enx0: r8152 (usb 0bda:8153)", so
+// driver quirks can be worked around. Virtual interfaces like loopback,
+// bridges and tunnels have no device and are skipped.
+func netAdapters() []string {
+	ents, _ := os.ReadDir(sysClassNet)
+	var ret []string
+	for _, ent := range ents {
+		// Resolve the device symlink so ".." below is its real parent.
+		dev, err := filepath.EvalSymlinks(filepath.Join(sysClassNet, ent.Name(), "device"))
+		if err != nil {
+			continue
+		}
+		driver := "unknown"
+		if p, err := os.Readlink(filepath.Join(dev, "driver")); err == nil {
+			driver = filepath.Base(p)
+		}
+		bus := "unknown"
+		if p, err := os.Readlink(filepath.Join(dev, "subsystem")); err == nil {
+			bus = filepath.Base(p)
+		}
+		var vendor, device string
+		switch bus {
+		case "usb":
+			// The device is the USB interface; its IDs are on the
+			// USB device it belongs to.
+			vendor = readSysfsString(filepath.Join(filepath.Dir(dev), "idVendor"))
+			device = readSysfsString(filepath.Join(filepath.Dir(dev), "idProduct"))
+		default: // pci, virtio
+			vendor = strings.TrimPrefix(readSysfsString(filepath.Join(dev, "vendor")), "0x")
+			device = strings.TrimPrefix(readSysfsString(filepath.Join(dev, "device")), "0x")
+		}
+		s := fmt.Sprintf("%s: %s (%s", ent.Name(), driver, bus)
+		if vendor != "" {
+			s += fmt.Sprintf(" %s:%s", vendor, device)
+		}
+		ret = append(ret, s+")")
+	}
+	return ret
+}
+
+// sriovVF is a network interface that's an SR-IOV virtual function.
+type sriovVF struct {
+	Interface string // the VF's interface, like "ens1f0v0"
+	PF        string // its physical function's interface, or PCI address if it has none
+}
+
+// sriov returns the network interfaces that are SR-IOV virtual
+// functions of a physical NIC, whose offloads behave differently from
+// the PF's. The PF is named by its interface, or by its PCI address if
+// no driver has created one (as when it's passed through with vfio).
+func sriov() []sriovVF {
+	ents, _ := os.ReadDir(sysClassNet)
+	var ret []sriovVF
+	for _, ent := range ents {
+		pf, err := filepath.EvalSymlinks(filepath.Join(sysClassNet, ent.Name(), "device/physfn"))
+		if err != nil {
+			continue
+		}
+		vf := sriovVF{Interface: ent.Name(), PF: filepath.Base(pf)}
+		if names, _ := os.ReadDir(filepath.Join(pf, "net")); len(names) > 0 {
+			vf.PF = names[0].Name()
+		}
+		ret = append(ret, vf)
+	}
+	return ret
+}
+
+// ethtoolOffloads are the offloads nicOffloads reports, with the
+// legacy ethtool ioctl commands that query them.
+var ethtoolOffloads = []struct {
+	name string
+	cmd  uint32
+}{
+	{"gro", unix.ETHTOOL_GGRO},
+	{"gso", unix.ETHTOOL_GGSO},
+	{"tso", unix.ETHTOOL_GTSO},
+	{"rx-checksum", unix.ETHTOOL_GRXCSUM},
+	{"tx-checksum", unix.ETHTOOL_GTXCSUM},
+}
+
+// nicOffloads returns whether each of the GRO, GSO, TSO and checksum
+// offloads is on for the network interface iface, such as the default
+// route's, which affect WireGuard's throughput. Offloads that can't be
+// queried are omitted, and it returns an empty map for virtual
+// interfaces, which have no NIC behind them.
+func nicOffloads(iface string) map[string]bool {
+	ret := map[string]bool{}
+	if _, err := os.Stat(filepath.Join(sysClassNet, iface, "device")); err != nil {
+		return ret
+	}
+	for _, o := range ethtoolOffloads {
+		if v, err := ethtoolGetValue(iface, o.cmd); err == nil {
+			ret[o.name] = v != 0
+		}
+	}
+	return ret
+}
+
+// linkSpeed returns the link speed of the network interface iface in
+// Mbps, or 0 if unknown. Virtual interfaces report -1 (or fail to
+// read at all), as do NICs whose link is down.
+func linkSpeed(iface string) int {
+	n, err := strconv.Atoi(readSysfsString(filepath.Join(sysClassNet, iface, "speed")))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// defaultLinkSpeed returns the link speed in Mbps of the default
+// route's interface, or 0 if unknown.
+func defaultLinkSpeed() int {
+	iface, err := defaultRouteLinux()
+	if err != nil || iface == "" {
+		return 0
+	}
+	return linkSpeed(iface)
+}
+
+// ethtoolGetValue runs the ethtool ioctl cmd, one that gets a struct
+// ethtool_value, on iface and returns the value. It's a variable so
+// tests can replace it.
+var ethtoolGetValue = func(iface string, cmd uint32) (uint32, error) {
+	if len(iface) >= unix.IFNAMSIZ {
+		return 0, unix.EINVAL
+	}
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+
+	// unix.Ifreq can't carry the pointer to the ethtool_value that
+	// SIOCETHTOOL takes, so this is its equivalent.
+	v := struct{ cmd, data uint32 }{cmd: cmd}
+	var ifr struct {
+		name [unix.IFNAMSIZ]byte
+		data unsafe.Pointer
+		_    [unsafe.Sizeof(unix.Ifreq{}) - unix.IFNAMSIZ - unsafe.Sizeof(uintptr(0))]byte
+	}
+	copy(ifr.name[:], iface)
+	ifr.data = unsafe.Pointer(&v)
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return 0, errno
+	}
+	return v.data, nil
+}
+
+// sysBusDir is where the kernel lists buses, including the
+// paravirtual ones. It's a variable so tests can replace it.
+var sysBusDir = "/sys/bus"
+
+// dmiHypervisor returns the hypervisor named by the DMI strings, or
+// the empty string if they don't say.
+func dmiHypervisor(d dmiInfo) string {
+	switch {
+	case d.SysVendor == "QEMU", d.ProductName == "KVM", d.BIOSVendor == "SeaBIOS":
+		return "kvm"
+	case d.SysVendor == "VMware, Inc.":
+		return "vmware"
+	case d.SysVendor == "Xen", d.BIOSVendor == "Xen":
+		return "xen"
+	case d.SysVendor == "Microsoft Corporation" && d.ProductName == "Virtual Machine":
+		return "hyperv"
+	case d.SysVendor == "innotek GmbH":
+		return "virtualbox"
+	}
+	return ""
+}
+
+// paravirtPCIVendors maps the PCI vendor IDs of paravirtual devices to
+// the hypervisor that provides them.
+var paravirtPCIVendors = map[string]string{
+	"0x1af4": "kvm", // virtio (Red Hat)
+	"0x15ad": "vmware",
+	"0x5853": "xen", // XenSource
+	"0x1414": "hyperv",
+	"0x80ee": "virtualbox",
+}
+
+// paravirtHypervisor returns the hypervisor whose paravirtual devices
+// are present: virtio, Xen or Hyper-V VMBus devices, or PCI devices
+// from a hypervisor vendor. It returns the empty string if there are
+// none, as on bare metal.
+func paravirtHypervisor() string {
+	for _, bus := range []struct{ dir, hv string }{
+		{"virtio", "kvm"},
+		{"xen", "xen"},
+		{"vmbus", "hyperv"},
+	} {
+		if ents, _ := os.ReadDir(filepath.Join(sysBusDir, bus.dir, "devices")); len(ents) > 0 {
+			return bus.hv
+		}
+	}
+	ents, _ := os.ReadDir(pciDevicesDir)
+	for _, ent := range ents {
+		if hv, ok := paravirtPCIVendors[readSysfsString(filepath.Join(pciDevicesDir, ent.Name(), "vendor"))]; ok {
+			return hv
+		}
+	}
+	return ""
+}
+
+// hypervisorName returns which hypervisor we're a guest of ("kvm",
+// "vmware", "xen", "hyperv" or "virtualbox"), or the empty string on
+// bare metal or if unknown. The DMI strings are used if they're
+// conclusive; cloud images often have ambiguous ones, so otherwise it
+// goes by paravirtual devices.
+func hypervisorName() string {
+	if hv := dmiHypervisor(dmiLinux()); hv != "" {
+		return hv
+	}
+	return paravirtHypervisor()
+}
+
+// These are variables so tests can replace them.
+var (
+	procSelfMountinfo = "/proc/self/mountinfo"
+	sysBlockDir       = "/sys/block"
+)
+
+// storageInfo is the disk backing a filesystem.
+type storageInfo struct {
+	Device string // disk name, like "sda" or "mmcblk0"
+	Type   string // "nvme", "ssd", "hdd", "sd" or "emmc"
+}
+
+// rootStorage returns the disk that the root filesystem is on, so
+// state corruption on a worn-out SD card can be told apart from bugs.
+// It returns the zero value if the disk can't be determined, as with
+// overlay, network or btrfs roots, which have no block device number.
+func rootStorage() storageInfo {
+	devno := rootDevNo()
+	if devno == "" {
+		return storageInfo{}
+	}
+	disk := diskOfDevNo(devno)
+	if disk == "" {
+		return storageInfo{}
+	}
+	return storageInfo{Device: disk, Type: diskType(disk)}
+}
+
+// rootDevNo returns the "major:minor" device number of the filesystem
+// mounted at /, or the empty string if unknown.
+func rootDevNo() (devno string) {
+	lineread.File(procSelfMountinfo, func(line []byte) error {
+		// 36 35 98:0 / / rw,noatime master:1 - ext4 /dev/root rw
+		f := strings.Fields(string(line))
+		if len(f) >= 5 && f[4] == "/" {
+			// The last one wins, as later mounts hide earlier ones.
+			devno = f[2]
+		}
+		return nil
+	})
+	return devno
+}
+
+// diskOfDevNo returns the name of the disk in sysBlockDir whose device
+// number, or one of whose partitions' device number, is devno.
+func diskOfDevNo(devno string) string {
+	disks, _ := os.ReadDir(sysBlockDir)
+	for _, disk := range disks {
+		dir := filepath.Join(sysBlockDir, disk.Name())
+		if readSysfsString(filepath.Join(dir, "dev")) == devno {
+			return disk.Name()
+		}
+		parts, _ := os.ReadDir(dir)
+		for _, part := range parts {
+			if !strings.HasPrefix(part.Name(), disk.Name()) {
+				continue
+			}
+			if readSysfsString(filepath.Join(dir, part.Name(), "dev")) == devno {
+				return disk.Name()
+			}
+		}
+	}
+	return ""
+}
+
+// diskType returns the storage medium of the named disk in
+// sysBlockDir, or the empty string if unknown.
+func diskType(disk string) string {
+	dir := filepath.Join(sysBlockDir, disk)
+	switch {
+	case strings.HasPrefix(disk, "nvme"):
+		return "nvme"
+	case strings.HasPrefix(disk, "mmcblk"):
+		// Soldered-on eMMC and removable SD cards share a driver.
+		if readSysfsString(filepath.Join(dir, "device/type")) == "MMC" {
+			return "emmc"
+		}
+		return "sd"
+	}
+	switch readSysfsString(filepath.Join(dir, "queue/rotational")) {
+	case "0":
+		return "ssd"
+	case "1":
+		return "hdd"
+	}
+	return ""
+}
+
+// These are variables so tests can replace them.
+var (
+	procEntropyAvail = "/proc/sys/kernel/random/entropy_avail"
+	hwRandomDir      = "/sys/class/misc/hw_random"
+)
+
+// entropyAvailable returns the kernel's entropy estimate in bits, or -1
+// if unknown. On fresh VMs and embedded boards a low estimate means
+// key generation may block. Since Linux 5.18 it's always 256.
+func entropyAvailable() int {
+	n, err := strconv.Atoi(readSysfsString(procEntropyAvail))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// hardwareRNG returns the name of the hardware random number generator
+// feeding the kernel's entropy pool, like "tpm-rng-0" or "virtio_rng.0",
+// or the empty string if there's none.
+func hardwareRNG() string {
+	rng := readSysfsString(filepath.Join(hwRandomDir, "rng_current"))
+	if rng == "none" {
+		return ""
+	}
+	return rng
+}
+
+// These are variables so tests can replace them.
+var (
+	sysClassIOMMU = "/sys/class/iommu"
+	procCmdline   = "/proc/cmdline"
+)
+
+// iommu reports whether an IOMMU (Intel VT-d or AMD-Vi) is active, as
+// accelerated packet paths that give devices to user space need. Each
+// active IOMMU is listed in /sys/class/iommu. If it's empty or the
+// kernel command line turns the IOMMU off, there's none; it's unset if
+// neither says.
+func iommu() (ret opt.Bool) {
+	ents, err := os.ReadDir(sysClassIOMMU)
+	if len(ents) > 0 {
+		ret.Set(true)
+		return ret
+	}
+	params := kernelCmdline()
+	for _, k := range []string{"iommu", "intel_iommu", "amd_iommu"} {
+		if params[k] == "off" {
+			ret.Set(false)
+			return ret
+		}
+	}
+	if err == nil {
+		ret.Set(false)
+	}
+	return ret
+}
+
+// ntpConfigFiles are the time sync daemons' config files that
+// ntpStatus reads servers from, with whether they use systemd's
+// "NTP=" syntax rather than ntpd's "server" and "pool" lines (which
+// chrony shares). It's a variable so tests can replace it.
+var ntpConfigFiles = []struct {
+	path    string
+	systemd bool
+}{
+	{"/etc/chrony.conf", false},
+	{"/etc/chrony/chrony.conf", false},
+	{"/etc/ntp.conf", false},
+	{"/etc/ntpsec/ntp.conf", false},
+	{"/etc/systemd/timesyncd.conf", true},
+}
+
+// staNano is the kernel's STA_NANO status bit, set when the timex
+// offset is in nanoseconds rather than microseconds.
+const staNano = 0x2000
+
+// ntpInfo is the state of the NTP client.
+type ntpInfo struct {
+	Servers    []string      // configured servers and pools
+	LastOffset time.Duration // the kernel's last measured clock offset
+	Synced     opt.Bool
+}
+
+// ntpStatus returns the configured NTP servers and how far off the
+// clock was when last adjusted, so clock skew is visible in bug
+// reports. The offset is what the NTP daemon last told the kernel, so
+// it works without querying the daemon.
+func ntpStatus() ntpInfo {
+	ret := ntpInfo{Synced: clockSyncedLinux()}
+	seen := map[string]bool{}
+	for _, f := range ntpConfigFiles {
+		for _, s := range ntpConfigServers(f.path, f.systemd) {
+			if !seen[s] {
+				seen[s] = true
+				ret.Servers = append(ret.Servers, s)
+			}
+		}
+	}
+	var tx unix.Timex // zero Modes: read only
+	if _, err := adjtimex(&tx); err == nil {
+		unit := time.Microsecond
+		if tx.Status&staNano != 0 {
+			unit = time.Nanosecond
+		}
+		ret.LastOffset = time.Duration(tx.Offset) * unit
+	}
+	return ret
+}
+
+// ntpConfigServers returns the servers in the NTP config file at path.
+func ntpConfigServers(path string, systemd bool) (servers []string) {
+	lineread.File(path, func(line []byte) error {
+		s := strings.TrimSpace(string(line))
+		if systemd {
+			if strings.HasPrefix(s, "NTP=") {
+				servers = append(servers, strings.Fields(strings.TrimPrefix(s, "NTP="))...)
+			}
+			return nil
+		}
+		if f := strings.Fields(s); len(f) > 1 && (f[0] == "server" || f[0] == "pool") {
+			servers = append(servers, f[1])
+		}
+		return nil
+	})
+	return servers
+}
+
+// tunDevice is the TUN/TAP clone device. It's a variable so tests can
+// replace it.
+var tunDevice = "/dev/net/tun"
+
+// tunAvailable reports whether the TUN clone device exists, as it
+// doesn't in many containers unless passed in. It's unset if it can't
+// be checked.
+func tunAvailable() (ret opt.Bool) {
+	fi, err := os.Stat(tunDevice)
+	if os.IsNotExist(err) {
+		ret.Set(false)
+		return ret
+	}
+	if err != nil {
+		return ""
+	}
+	ret.Set(fi.Mode()&os.ModeCharDevice != 0)
+	return ret
+}
+
+// netStackReason returns why tailscaled would have to fall back to
+// userspace networking: "no-tun-device" without /dev/net/tun, or
+// "no-net-admin" without CAP_NET_ADMIN to configure the interface. It
+// returns the empty string if kernel networking should work, or if the
+// probes can't tell.
+//
+// There's no reason for a missing WireGuard kernel module (see
+// wireguardKernel): tailscaled runs wireguard-go on the TUN device
+// either way, so the module never decides whether it falls back.
+func netStackReason() string {
+	if v, ok := tunAvailable().Get(); ok && !v {
+		return "no-tun-device"
+	}
+	if v, ok := hasNetAdmin().Get(); ok && !v {
+		return "no-net-admin"
+	}
+	return ""
+}
+
+// kernelCmdline returns the parameters on the kernel command line, from
+// procCmdline, by name. Parameters without a value, like "quiet", map
+// to the empty string. As in the kernel, double quotes group words
+// with spaces into one parameter and are removed, a later duplicate
+// of a parameter overrides an earlier one, and parameters after "--"
+// are for init and aren't included. It returns nil if the command
+// line can't be read.
+func kernelCmdline() map[string]string {
+	b, err := os.ReadFile(procCmdline)
+	if err != nil {
+		return nil
+	}
+	params := map[string]string{}
+	for _, arg := range splitCmdline(string(b)) {
+		if arg == "--" {
+			break
+		}
+		k, v, _ := strings.Cut(arg, "=")
+		params[k] = v
+	}
+	return params
+}
+
+// splitCmdline splits a kernel command line into parameters at spaces
+// outside double quotes, removing the quotes.
+func splitCmdline(s string) []string {
+	var args []string
+	var arg strings.Builder
+	inQuote, inArg := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			inArg = true
+		case !inQuote && (r == ' ' || r == '\t' || r == '\n'):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// netCmdlineParams are the kernel command line parameters that affect
+// networking.
+var netCmdlineParams = []string{
+	"ipv6.disable",               // no IPv6 at all
+	"ipv6.disable_ipv6",          // IPv6 off on all interfaces
+	"net.ifnames", "biosdevname", // interface naming
+	"ip", "rd.neednet", "ifname", "bond", // initramfs network setup
+	"iommu", "intel_iommu", "amd_iommu",
+}
+
+// netCmdline returns the networking related kernel command line
+// parameters that are set, by name.
+func netCmdline() map[string]string {
+	params := kernelCmdline()
+	ret := map[string]string{}
+	for _, k := range netCmdlineParams {
+		if v, ok := params[k]; ok {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// resolvedUpstreamConf is where systemd-resolved lists the upstream
+// DNS servers of all links. It's a variable so tests can replace it.
+var resolvedUpstreamConf = "/run/systemd/resolve/resolv.conf"
+
+// dnsServersLinux returns systemd-resolved's upstream servers when
+// /etc/resolv.conf points at its stub listener, which would otherwise
+// be all dnsServers reports.
+func dnsServersLinux() (servers []netip.Addr, ok bool) {
+	if v, _ := resolvedStubActive().Get(); !v {
+		return nil, false
+	}
+	bs, err := resolvConfRead(resolvedUpstreamConf)
+	if err != nil {
+		return nil, false
+	}
+	return parseResolvConfServers(bs), true
+}
+
+// systemd-resolved's configuration: the main file and the directory
+// of drop-ins that override it. They're variables so tests can replace
+// them.
+var (
+	resolvedConf       = "/etc/systemd/resolved.conf"
+	resolvedConfDropin = "/etc/systemd/resolved.conf.d"
+)
+
+// dohProviders names the public resolvers that support DNS over HTTPS
+// and TLS, by their addresses.
+var dohProviders = map[netip.Addr]string{
+	netip.MustParseAddr("1.1.1.1"):              "Cloudflare",
+	netip.MustParseAddr("1.0.0.1"):              "Cloudflare",
+	netip.MustParseAddr("2606:4700:4700::1111"): "Cloudflare",
+	netip.MustParseAddr("2606:4700:4700::1001"): "Cloudflare",
+	netip.MustParseAddr("8.8.8.8"):              "Google",
+	netip.MustParseAddr("8.8.4.4"):              "Google",
+	netip.MustParseAddr("2001:4860:4860::8888"): "Google",
+	netip.MustParseAddr("2001:4860:4860::8844"): "Google",
+	netip.MustParseAddr("9.9.9.9"):              "Quad9",
+	netip.MustParseAddr("149.112.112.112"):      "Quad9",
+	netip.MustParseAddr("2620:fe::fe"):          "Quad9",
+	netip.MustParseAddr("2620:fe::9"):           "Quad9",
+	netip.MustParseAddr("94.140.14.14"):         "AdGuard",
+	netip.MustParseAddr("94.140.15.15"):         "AdGuard",
+}
+
+// dnsEncryptionInfo is whether the host's DNS queries are encrypted.
+type dnsEncryptionInfo struct {
+	DoT, DoH opt.Bool
+	Provider string // the public resolver in use, like "Cloudflare", if known
+}
+
+// dnsEncryption reports whether the host encrypts its DNS queries, with
+// DNS over TLS (DoT) or HTTPS (DoH). systemd-resolved can use DoT but
+// not DoH, and glibc's resolver neither, so queries are only encrypted
+// via resolved or a local proxy. A proxy's upstreams can't be seen, so
+// with an unknown resolver on a loopback address both are unset, as
+// is DoT when resolved only uses it opportunistically.
+func dnsEncryption() dnsEncryptionInfo {
+	var ret dnsEncryptionInfo
+	servers := dnsServers()
+	for _, ip := range servers {
+		if p, ok := dohProviders[ip]; ok {
+			ret.Provider = p
+			break
+		}
+	}
+	if v, _ := resolvedStubActive().Get(); v {
+		switch resolvedDNSOverTLS() {
+		case "yes", "true", "on", "1":
+			ret.DoT.Set(true)
+		case "opportunistic":
+			// resolved tries DoT but silently falls back to plain DNS
+			// if the server doesn't offer it, so there's no telling
+			// which the queries use.
+		default:
+			ret.DoT.Set(false)
+		}
+		ret.DoH.Set(false)
+		return ret
+	}
+	if len(servers) == 0 {
+		return ret
+	}
+	for _, ip := range servers {
+		if ip.IsLoopback() {
+			return ret
+		}
+	}
+	ret.DoT.Set(false)
+	ret.DoH.Set(false)
+	return ret
+}
+
+// resolvedDNSOverTLS returns systemd-resolved's DNSOverTLS setting,
+// lower cased, or the empty string if it's not set. As in resolved,
+// drop-ins override the main file in file name order.
+func resolvedDNSOverTLS() string {
+	files := []string{resolvedConf}
+	dropins, _ := filepath.Glob(filepath.Join(resolvedConfDropin, "*.conf"))
+	sort.Strings(dropins)
+	files = append(files, dropins...)
+	var ret string
+	for _, f := range files {
+		section := ""
+		lineread.File(f, func(line []byte) error {
+			s := strings.TrimSpace(string(line))
+			if strings.HasPrefix(s, "[") {
+				section = s
+				return nil
+			}
+			k, v, ok := strings.Cut(s, "=")
+			if ok && section == "[Resolve]" && strings.TrimSpace(k) == "DNSOverTLS" {
+				ret = strings.ToLower(strings.TrimSpace(v))
+			}
+			return nil
+		})
+	}
+	return ret
+}
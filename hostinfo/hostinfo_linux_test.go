@@ -8,7 +8,18 @@
 package hostinfo
 
 import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"tailscale.com/types/opt"
 )
 
 func TestQnap(t *testing.T) {
@@ -36,3 +47,2595 @@ func TestQnap(t *testing.T) {
 		t.Errorf("got %q; want %q", got, want)
 	}
 }
+
+func TestLinuxServiceStatus(t *testing.T) {
+	tests := []struct {
+		invocationID string
+		hasTTY       bool
+		want         opt.Bool
+	}{
+		{"3f9a8b2c", false, "true"},
+		{"3f9a8b2c", true, ""},
+		{"", true, "false"},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		if got := linuxServiceStatus(tt.invocationID, tt.hasTTY); got != tt.want {
+			t.Errorf("linuxServiceStatus(%q, %v) = %q; want %q", tt.invocationID, tt.hasTTY, got, tt.want)
+		}
+	}
+}
+
+func TestMachineIDLinux(t *testing.T) {
+	dir := t.TempDir()
+	etc := filepath.Join(dir, "etc-machine-id")
+	dbus := filepath.Join(dir, "dbus-machine-id")
+
+	old := machineIDFiles
+	defer func() { machineIDFiles = old }()
+	machineIDFiles = []string{etc, dbus}
+
+	if got := machineIDLinux(); got != "" {
+		t.Errorf("with no files, got %q; want empty", got)
+	}
+	if err := os.WriteFile(dbus, []byte("dbusid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := machineIDLinux(); got != "dbusid" {
+		t.Errorf("with dbus file only, got %q; want %q", got, "dbusid")
+	}
+	// An empty /etc/machine-id (as in some container images) is skipped.
+	if err := os.WriteFile(etc, []byte("\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := machineIDLinux(); got != "dbusid" {
+		t.Errorf("with empty etc file, got %q; want %q", got, "dbusid")
+	}
+	if err := os.WriteFile(etc, []byte("etcid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := machineIDLinux(); got != "etcid" {
+		t.Errorf("with both files, got %q; want %q", got, "etcid")
+	}
+}
+
+func TestBatteryStatusLinux(t *testing.T) {
+	type supply struct {
+		name, typ, scope string
+	}
+	tests := []struct {
+		name     string
+		supplies []supply
+		want     opt.Bool
+	}{
+		{
+			name: "laptop",
+			supplies: []supply{
+				{name: "AC", typ: "Mains"},
+				{name: "BAT0", typ: "Battery"},
+			},
+			want: "true",
+		},
+		{
+			name: "desktop_with_mouse",
+			supplies: []supply{
+				{name: "AC", typ: "Mains"},
+				{name: "hidpp_battery_0", typ: "Battery", scope: "Device"},
+			},
+			want: "false",
+		},
+		{
+			name:     "desktop_ups",
+			supplies: []supply{{name: "ups", typ: "UPS"}},
+			want:     "false",
+		},
+		{
+			name: "unknown",
+			want: "",
+		},
+	}
+	old := powerSupplyDir
+	defer func() { powerSupplyDir = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			powerSupplyDir = t.TempDir()
+			for _, s := range tt.supplies {
+				dir := filepath.Join(powerSupplyDir, s.name)
+				if err := os.Mkdir(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "type"), []byte(s.typ+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if s.scope != "" {
+					if err := os.WriteFile(filepath.Join(dir, "scope"), []byte(s.scope+"\n"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if got := batteryStatusLinux(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+	powerSupplyDir = "/nonexistent"
+	if got := batteryStatusLinux(); got != "" {
+		t.Errorf("with missing dir, got %q; want unset", got)
+	}
+}
+
+func TestFSTypeLinux(t *testing.T) {
+	tests := []struct {
+		magic uint32
+		want  string
+	}{
+		{0xef53, "ext4"},
+		{0x794c7630, "overlay"},
+		{0x6969, "nfs"},
+		{0x01021994, "tmpfs"},
+		{0x9123683e, "btrfs"},
+		{0xfe534d42, "smb2"},
+		{0x1badface, "0x1badface"},
+	}
+	old := statfsMagic
+	defer func() { statfsMagic = old }()
+	for _, tt := range tests {
+		statfsMagic = func(string) (uint32, error) { return tt.magic, nil }
+		if got := fsTypeLinux("/var/lib/tailscale"); got != tt.want {
+			t.Errorf("fsType for magic 0x%x = %q; want %q", tt.magic, got, tt.want)
+		}
+	}
+	statfsMagic = func(string) (uint32, error) { return 0, errors.New("ENOENT") }
+	if got := fsTypeLinux("/nonexistent"); got != "" {
+		t.Errorf("on statfs error, got %q; want empty", got)
+	}
+}
+
+func TestThermalState(t *testing.T) {
+	dir := t.TempDir()
+	oldThermal, oldThrottled := thermalClassDir, rpiThrottledFile
+	defer func() { thermalClassDir, rpiThrottledFile = oldThermal, oldThrottled }()
+	thermalClassDir = filepath.Join(dir, "thermal")
+	rpiThrottledFile = filepath.Join(dir, "get_throttled")
+
+	if temp, throttled := thermalState(); temp != 0 || throttled {
+		t.Errorf("with nothing present, got %v, %v; want zero values", temp, throttled)
+	}
+
+	for zone, temp := range map[string]string{
+		"thermal_zone0": "48312\n",
+		"thermal_zone1": "61000\n",
+		"thermal_zone2": "garbage\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(thermalClassDir, zone), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(thermalClassDir, zone, "temp"), []byte(temp), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// cooling devices have no temp and are ignored.
+	if err := os.MkdirAll(filepath.Join(thermalClassDir, "cooling_device0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if temp, throttled := thermalState(); temp != 61000 || throttled {
+		t.Errorf("with zones only, got %v, %v; want 61000, false", temp, throttled)
+	}
+
+	tests := []struct {
+		throttled string
+		want      bool
+	}{
+		{"0\n", false},
+		{"50000\n", false}, // occurred since boot, but not now
+		{"50005\n", true},  // under-voltage and throttled now
+		{"8\n", true},      // soft temperature limit
+		{"junk\n", false},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(rpiThrottledFile, []byte(tt.throttled), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, got := thermalState(); got != tt.want {
+			t.Errorf("get_throttled %q: throttled = %v; want %v", tt.throttled, got, tt.want)
+		}
+	}
+}
+
+func TestDNSMode(t *testing.T) {
+	const resolvedStub = `# This is /run/systemd/resolve/stub-resolv.conf managed by man:systemd-resolved(8).
+# Do not edit.
+nameserver 127.0.0.53
+options edns0 trust-ad
+`
+	tests := []struct {
+		name     string
+		target   string
+		contents string
+		readErr  error
+		want     string
+	}{
+		{
+			name:     "resolved_symlink",
+			target:   "../run/systemd/resolve/stub-resolv.conf",
+			contents: resolvedStub,
+			want:     "systemd-resolved",
+		},
+		{
+			name:     "resolved_uplink_symlink",
+			target:   "/run/systemd/resolve/resolv.conf",
+			contents: "nameserver 192.168.1.1\n",
+			want:     "systemd-resolved",
+		},
+		{
+			name:     "resolved_copied",
+			contents: resolvedStub,
+			want:     "systemd-resolved",
+		},
+		{
+			name:     "networkmanager_symlink",
+			target:   "/run/NetworkManager/resolv.conf",
+			contents: "nameserver 10.0.0.1\n",
+			want:     "NetworkManager",
+		},
+		{
+			name:     "networkmanager_file",
+			contents: "# Generated by NetworkManager\nsearch lan\nnameserver 10.0.0.1\n",
+			want:     "NetworkManager",
+		},
+		{
+			name:     "resolvconf_symlink",
+			target:   "/run/resolvconf/resolv.conf",
+			contents: "nameserver 10.0.0.1\n",
+			want:     "resolvconf",
+		},
+		{
+			name:     "stub_static",
+			contents: "nameserver 127.0.0.53\n",
+			want:     "systemd-resolved-stub",
+		},
+		{
+			name:     "static",
+			contents: "nameserver 8.8.8.8\n# NetworkManager is not involved\n",
+			want:     "static",
+		},
+		{
+			name:    "unreadable",
+			readErr: os.ErrNotExist,
+			want:    "",
+		},
+	}
+	oldReadlink, oldRead := resolvConfReadlink, resolvConfRead
+	defer func() { resolvConfReadlink, resolvConfRead = oldReadlink, oldRead }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvConfReadlink = func(string) (string, error) {
+				if tt.target == "" {
+					return "", errors.New("not a symlink")
+				}
+				return tt.target, nil
+			}
+			resolvConfRead = func(string) ([]byte, error) {
+				return []byte(tt.contents), tt.readErr
+			}
+			if got := dnsMode(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPv6StatusLinux(t *testing.T) {
+	tests := []struct {
+		name    string
+		noNet   bool   // no /proc/sys/net at all
+		disable string // contents of disable_ipv6; "" means no ipv6 dir
+		want    opt.Bool
+	}{
+		{name: "unknown", noNet: true, want: ""},
+		{name: "module_not_loaded", want: "false"},
+		{name: "enabled", disable: "0\n", want: "true"},
+		{name: "disabled", disable: "1\n", want: "false"},
+	}
+	old := procSysNet
+	defer func() { procSysNet = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procSysNet = filepath.Join(t.TempDir(), "net")
+			if !tt.noNet {
+				if err := os.MkdirAll(filepath.Join(procSysNet, "ipv4"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.disable != "" {
+				dir := filepath.Join(procSysNet, "ipv6", "conf", "all")
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "disable_ipv6"), []byte(tt.disable), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := ipv6StatusLinux(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPForwarding(t *testing.T) {
+	tests := []struct {
+		name   string
+		v4, v6 string // sysctl contents; "" means missing
+		want   ipForwardingState
+	}{
+		{"both", "1\n", "1\n", ipForwardingState{IPv4: "true", IPv6: "true"}},
+		{"neither", "0\n", "0\n", ipForwardingState{IPv4: "false", IPv6: "false"}},
+		{"v4_only", "1\n", "0\n", ipForwardingState{IPv4: "true", IPv6: "false"}},
+		{"no_ipv6", "1\n", "", ipForwardingState{IPv4: "true"}},
+		{"unreadable", "", "", ipForwardingState{}},
+		{"garbage", "yes\n", "2\n", ipForwardingState{IPv6: "true"}},
+	}
+	old := procSysNet
+	defer func() { procSysNet = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procSysNet = filepath.Join(t.TempDir(), "net")
+			files := map[string]string{
+				filepath.Join(procSysNet, "ipv4", "ip_forward"):                tt.v4,
+				filepath.Join(procSysNet, "ipv6", "conf", "all", "forwarding"): tt.v6,
+			}
+			for path, contents := range files {
+				if contents == "" {
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := ipForwarding(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUDPBufferLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		rmem, wmem string // sysctl contents; "" means missing
+		want       udpBufferInfo
+	}{
+		{"default", "212992\n", "212992\n", udpBufferInfo{RmemMax: 212992, WmemMax: 212992}},
+		{"tuned", "7340032\n", "7340032\n", udpBufferInfo{RmemMax: 7 << 20, WmemMax: 7 << 20}},
+		{"rmem_only", "2500000\n", "", udpBufferInfo{RmemMax: 2500000}},
+		{"unreadable", "", "", udpBufferInfo{}},
+		{"garbage", "lots\n", "-1\n", udpBufferInfo{}},
+	}
+	old := procSysNet
+	defer func() { procSysNet = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procSysNet = filepath.Join(t.TempDir(), "net")
+			if err := os.MkdirAll(filepath.Join(procSysNet, "core"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, contents := range map[string]string{"rmem_max": tt.rmem, "wmem_max": tt.wmem} {
+				if contents == "" {
+					continue
+				}
+				if err := os.WriteFile(filepath.Join(procSysNet, "core", name), []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := udpBufferLimits(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConntrack(t *testing.T) {
+	const entry = "ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=51234 dport=22 [ASSURED] mark=0 zone=0 use=2\n"
+	tests := []struct {
+		name       string
+		max, count string // sysctl contents; "" means missing
+		table      string // /proc/net/nf_conntrack contents; "" means missing
+		want       conntrackInfo
+	}{
+		{"sysctls", "262144\n", "1234\n", "", conntrackInfo{Max: 262144, Count: 1234}},
+		{"empty", "65536\n", "0\n", "", conntrackInfo{Max: 65536}},
+		{"count_from_table", "65536\n", "", entry + entry + entry, conntrackInfo{Max: 65536, Count: 3}},
+		{"not_loaded", "", "", "", conntrackInfo{}},
+	}
+	oldNet, oldTable := procSysNet, procNetConntrack
+	defer func() { procSysNet, procNetConntrack = oldNet, oldTable }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			procSysNet = filepath.Join(dir, "sys/net")
+			procNetConntrack = filepath.Join(dir, "nf_conntrack")
+			files := map[string]string{
+				filepath.Join(procSysNet, "netfilter/nf_conntrack_max"):   tt.max,
+				filepath.Join(procSysNet, "netfilter/nf_conntrack_count"): tt.count,
+				procNetConntrack: tt.table,
+			}
+			for path, contents := range files {
+				if contents == "" {
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := conntrack(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBootTimeLinux(t *testing.T) {
+	old := procUptimeFile
+	defer func() { procUptimeFile = old }()
+	procUptimeFile = filepath.Join(t.TempDir(), "uptime")
+
+	if got := bootTimeLinux(); !got.IsZero() {
+		t.Errorf("with no file, got %v; want zero", got)
+	}
+	for _, bad := range []string{"", "junk 1.0\n", "-5 0\n"} {
+		if err := os.WriteFile(procUptimeFile, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := bootTimeLinux(); !got.IsZero() {
+			t.Errorf("with %q, got %v; want zero", bad, got)
+		}
+	}
+	if err := os.WriteFile(procUptimeFile, []byte("3600.50 7000.00\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got := time.Since(bootTimeLinux())
+	if want := 3600500 * time.Millisecond; got < want || got > want+time.Second {
+		t.Errorf("time since boot = %v; want %v", got, want)
+	}
+}
+
+func TestClockSyncedLinux(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxError int64
+		err      error
+		want     opt.Bool
+	}{
+		{name: "synced", maxError: 4500, want: "true"},
+		{name: "unsynced", maxError: 16_000_000, want: "false"},
+		{name: "error", err: unix.EPERM, want: ""},
+	}
+	old := adjtimex
+	defer func() { adjtimex = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adjtimex = func(tx *unix.Timex) (int, error) {
+				if tx.Modes != 0 {
+					t.Fatalf("adjtimex called with modes %#x; want read-only", tx.Modes)
+				}
+				setInt(&tx.Maxerror, tt.maxError)
+				return 0, tt.err
+			}
+			if got := clockSyncedLinux(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// setInt sets *p to v. Timex field widths vary by GOARCH.
+func setInt[T int32 | int64](p *T, v int64) { *p = T(v) }
+
+func TestGPUsLinux(t *testing.T) {
+	writeFiles := func(t *testing.T, dir string, files map[string]string) {
+		t.Helper()
+		for name, contents := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	oldPCI, oldDRM := pciDevicesDir, drmClassDir
+	defer func() { pciDevicesDir, drmClassDir = oldPCI, oldDRM }()
+
+	tests := []struct {
+		name string
+		pci  map[string]string
+		drm  map[string]string
+		want []string
+	}{
+		{
+			name: "desktop",
+			pci: map[string]string{
+				"0000:00:02.0/class":  "0x030000\n",
+				"0000:00:02.0/vendor": "0x8086\n",
+				"0000:00:02.0/device": "0x9bc4\n",
+				"0000:01:00.0/class":  "0x030200\n",
+				"0000:01:00.0/vendor": "0x10de\n",
+				"0000:01:00.0/device": "0x2204\n",
+				"0000:00:1f.3/class":  "0x040300\n", // audio
+				"0000:00:1f.3/vendor": "0x8086\n",
+				"0000:00:1f.3/device": "0x06c8\n",
+			},
+			want: []string{"Intel [8086:9bc4]", "NVIDIA [10de:2204]"},
+		},
+		{
+			name: "unknown_vendor",
+			pci: map[string]string{
+				"0000:00:02.0/class":  "0x030000\n",
+				"0000:00:02.0/vendor": "0xabcd\n",
+				"0000:00:02.0/device": "0x0001\n",
+			},
+			want: []string{"unknown [abcd:0001]"},
+		},
+		{
+			name: "raspberry_pi",
+			drm: map[string]string{
+				"card0/device/uevent": "DRIVER=v3d\nOF_NAME=v3d\n",
+				"card1/device/uevent": "DRIVER=vc4-drm\nOF_NAME=gpu\n",
+				"card2/device/uevent": "DRIVER=vc4-drm\n",
+			},
+			want: []string{"v3d", "vc4-drm"},
+		},
+		{
+			name: "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pciDevicesDir = filepath.Join(t.TempDir(), "pci")
+			drmClassDir = filepath.Join(t.TempDir(), "drm")
+			writeFiles(t, pciDevicesDir, tt.pci)
+			writeFiles(t, drmClassDir, tt.drm)
+			if got := gpusLinux(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerRuntime(t *testing.T) {
+	tests := []struct {
+		name   string
+		cgroup string
+		env    map[string]string
+		want   containerInfo
+	}{
+		{
+			name:   "host_v2",
+			cgroup: "0::/user.slice/user-1000.slice/session-2.scope\n",
+			want:   containerInfo{},
+		},
+		{
+			name:   "host_v1",
+			cgroup: "12:pids:/system.slice/tailscaled.service\n1:name=systemd:/system.slice/tailscaled.service\n",
+			want:   containerInfo{},
+		},
+		{
+			name:   "docker_cgroupfs",
+			cgroup: "12:pids:/docker/3f8a2b9c1d4e\n1:name=systemd:/docker/3f8a2b9c1d4e\n",
+			want:   containerInfo{Runtime: "docker", Orchestrator: "none"},
+		},
+		{
+			name:   "docker_systemd",
+			cgroup: "0::/system.slice/docker-3f8a2b9c1d4e.scope\n",
+			want:   containerInfo{Runtime: "docker", Orchestrator: "none"},
+		},
+		{
+			name:   "podman",
+			cgroup: "0::/machine.slice/libpod-3f8a2b9c1d4e.scope/container\n",
+			want:   containerInfo{Runtime: "podman", Orchestrator: "none"},
+		},
+		{
+			name:   "podman_env",
+			cgroup: "0::/\n",
+			env:    map[string]string{"container": "podman"},
+			want:   containerInfo{Runtime: "podman", Orchestrator: "none"},
+		},
+		{
+			name:   "kubernetes_containerd",
+			cgroup: "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-3f8a2b9c1d4e.scope\n",
+			env:    map[string]string{"KUBERNETES_SERVICE_HOST": "10.96.0.1"},
+			want:   containerInfo{Runtime: "containerd", Orchestrator: "kubernetes"},
+		},
+		{
+			name:   "kubernetes_crio",
+			cgroup: "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234.slice/crio-3f8a2b9c1d4e.scope\n",
+			want:   containerInfo{Runtime: "cri-o", Orchestrator: "kubernetes"},
+		},
+		{
+			name:   "kubernetes_docker",
+			cgroup: "11:memory:/kubepods/burstable/pod1234/3f8a2b9c1d4e\n1:name=systemd:/kubepods/burstable/pod1234/3f8a2b9c1d4e\n",
+			want:   containerInfo{Orchestrator: "kubernetes"},
+		},
+		{
+			name:   "kubernetes_env_only",
+			cgroup: "0::/\n",
+			env:    map[string]string{"KUBERNETES_SERVICE_HOST": "10.96.0.1"},
+			want:   containerInfo{Orchestrator: "kubernetes"},
+		},
+		{
+			name:   "nomad_docker",
+			cgroup: "0::/system.slice/docker-3f8a2b9c1d4e.scope\n",
+			env:    map[string]string{"NOMAD_ALLOC_ID": "5e1c2c5a-0d7a-4c4b-9a3e-1c2d3e4f5a6b"},
+			want:   containerInfo{Runtime: "docker", Orchestrator: "nomad"},
+		},
+		{
+			name:   "nomad_exec",
+			cgroup: "0::/nomad.slice/5e1c2c5a.task.scope\n",
+			env:    map[string]string{"NOMAD_ALLOC_ID": "5e1c2c5a-0d7a-4c4b-9a3e-1c2d3e4f5a6b"},
+			want:   containerInfo{Orchestrator: "nomad"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			if got := linuxContainerRuntime([]byte(tt.cgroup), getenv); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		cgroup string            // /proc/self/cgroup contents
+		files  map[string]string // relative to sysFSCgroup
+		want   containerLimitInfo
+	}{
+		{
+			name:   "v2_limited",
+			cgroup: "0::/\n",
+			files:  map[string]string{"memory.max": "536870912\n", "cpu.max": "150000 100000\n"},
+			want:   containerLimitInfo{MemoryLimitBytes: 512 << 20, CPUQuotaCores: 1.5},
+		},
+		{
+			name:   "v2_unlimited",
+			cgroup: "0::/\n",
+			files:  map[string]string{"memory.max": "max\n", "cpu.max": "max 100000\n"},
+			want:   containerLimitInfo{},
+		},
+		{
+			name:   "v2_ancestor_limit",
+			cgroup: "0::/system.slice/tailscaled.service\n",
+			files: map[string]string{
+				"system.slice/memory.max":                    "1073741824\n",
+				"system.slice/cpu.max":                       "max 100000\n",
+				"system.slice/tailscaled.service/memory.max": "max\n",
+				"system.slice/tailscaled.service/cpu.max":    "50000 100000\n",
+			},
+			want: containerLimitInfo{MemoryLimitBytes: 1 << 30, CPUQuotaCores: 0.5},
+		},
+		{
+			name:   "v2_host_path_not_mounted",
+			cgroup: "0::/kubepods/pod1/abc\n",
+			files:  map[string]string{"memory.max": "268435456\n", "cpu.max": "200000 100000\n"},
+			want:   containerLimitInfo{MemoryLimitBytes: 256 << 20, CPUQuotaCores: 2},
+		},
+		{
+			name:   "v1_limited",
+			cgroup: "12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n0::/\n",
+			files: map[string]string{
+				"memory/docker/abc/memory.limit_in_bytes": "2147483648\n",
+				"cpu/docker/abc/cpu.cfs_quota_us":         "25000\n",
+				"cpu/docker/abc/cpu.cfs_period_us":        "100000\n",
+				"memory.max":                              "1024\n", // v2 ignored for v1 controllers
+			},
+			want: containerLimitInfo{MemoryLimitBytes: 2 << 30, CPUQuotaCores: 0.25},
+		},
+		{
+			name:   "v1_unlimited",
+			cgroup: "12:memory:/\n4:cpu,cpuacct:/\n",
+			files: map[string]string{
+				"memory/memory.limit_in_bytes": "9223372036854771712\n",
+				"cpu/cpu.cfs_quota_us":         "-1\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+			},
+			want: containerLimitInfo{},
+		},
+		{
+			name:   "no_cgroups",
+			cgroup: "",
+			want:   containerLimitInfo{},
+		},
+	}
+	oldCgroup, oldFS := procSelfCgroup, sysFSCgroup
+	defer func() { procSelfCgroup, sysFSCgroup = oldCgroup, oldFS }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			procSelfCgroup = filepath.Join(dir, "cgroup")
+			sysFSCgroup = filepath.Join(dir, "fs")
+			if err := os.WriteFile(procSelfCgroup, []byte(tt.cgroup), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				p := filepath.Join(sysFSCgroup, name)
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := containerLimits(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInNspawn(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		noSystemd bool   // no /run/systemd
+		marker    string // /run/systemd/container contents; "" means missing
+		want      opt.Bool
+		wantName  string
+	}{
+		{name: "nspawn_env", env: "systemd-nspawn", noSystemd: true, want: "true", wantName: "systemd-nspawn"},
+		{name: "nspawn_marker", marker: "systemd-nspawn\n", want: "true", wantName: "systemd-nspawn"},
+		{name: "docker_marker", marker: "docker\n", want: "false", wantName: "docker"},
+		{name: "podman_env", env: "podman", marker: "systemd-nspawn\n", want: "false", wantName: "podman"},
+		{name: "host_systemd", want: "false"},
+		{name: "no_systemd", noSystemd: true, want: ""},
+	}
+	old := systemdContainerFile
+	defer func() { systemdContainerFile = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("container", tt.env)
+			dir := filepath.Join(t.TempDir(), "systemd")
+			systemdContainerFile = filepath.Join(dir, "container")
+			if !tt.noSystemd {
+				if err := os.Mkdir(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.marker != "" {
+				if err := os.WriteFile(systemdContainerFile, []byte(tt.marker), 0444); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := inNspawn(); got != tt.want {
+				t.Errorf("inNspawn = %q; want %q", got, tt.want)
+			}
+			if name, _ := systemdContainerManager(); name != tt.wantName {
+				t.Errorf("systemdContainerManager = %q; want %q", name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestHasNetAdmin(t *testing.T) {
+	const header = "Name:\ttailscaled\nUmask:\t0022\nState:\tS (sleeping)\nCapInh:\t0000000000000000\nCapPrm:\t000001ffffffffff\n"
+	tests := []struct {
+		name   string
+		status string // "" means missing
+		want   opt.Bool
+	}{
+		{"root", header + "CapEff:\t000001ffffffffff\nCapBnd:\t000001ffffffffff\n", "true"},
+		{"net_admin_only", header + "CapEff:\t0000000000001000\n", "true"},
+		{"net_admin_and_raw", header + "CapEff:\t0000000000003000\n", "true"},
+		{"unprivileged", header + "CapEff:\t0000000000000000\n", "false"},
+		{"net_raw_only", header + "CapEff:\t0000000000002000\n", "false"},
+		{"no_capeff", header, ""},
+		{"garbage", header + "CapEff:\tzzzz\n", ""},
+		{"missing", "", ""},
+	}
+	old := procSelfStatus
+	defer func() { procSelfStatus = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procSelfStatus = filepath.Join(t.TempDir(), "status")
+			if tt.status != "" {
+				if err := os.WriteFile(procSelfStatus, []byte(tt.status), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := hasNetAdmin(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirewallBackend(t *testing.T) {
+	tests := []struct {
+		name      string
+		iptables  string // resolved path; "" means not installed
+		nftTables bool
+		want      string
+	}{
+		{"debian_nft", "/usr/sbin/xtables-nft-multi", false, "iptables-nft"},
+		{"debian_legacy", "/usr/sbin/xtables-legacy-multi", false, "iptables-legacy"},
+		{"fedora_nft", "/usr/sbin/iptables-nft", true, "iptables-nft"},
+		{"rhel7_legacy", "/usr/sbin/iptables-legacy", true, "iptables-legacy"},
+		{"pure_nftables", "", true, "nftables"},
+		{"no_firewall", "", false, ""},
+		{"unknown_iptables", "/bin/busybox", false, ""},
+	}
+	oldPath, oldNft := iptablesPath, nftHasTables
+	defer func() { iptablesPath, nftHasTables = oldPath, oldNft }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iptablesPath = func() (string, error) {
+				if tt.iptables == "" {
+					return "", errors.New("not found")
+				}
+				return tt.iptables, nil
+			}
+			nftHasTables = func() bool { return tt.nftTables }
+			if got := firewallBackend(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRouteLinux(t *testing.T) {
+	const (
+		route4Header = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+		route4LAN    = "eth0\t0002A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"
+		route4Def    = "wlan0\t00000000\t0102A8C0\t0003\t0\t0\t600\t00000000\t0\t0\t0\n"
+		route6Def    = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 eth1\n"
+		route6Reject = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200 lo\n"
+	)
+	tests := []struct {
+		name      string
+		v4, v6    string // "" means missing
+		want      string
+		wantError bool
+	}{
+		{name: "v4_default", v4: route4Header + route4LAN + route4Def, v6: route6Def, want: "wlan0"},
+		{name: "v6_only", v4: route4Header + route4LAN, v6: route6Reject + route6Def, want: "eth1"},
+		{name: "v6_table_only", v6: route6Def, want: "eth1"},
+		{name: "none", v4: route4Header + route4LAN, v6: route6Reject, want: ""},
+		{name: "v4_down", v4: route4Header + "wlan0\t00000000\t0102A8C0\t0002\t0\t0\t600\t00000000\t0\t0\t0\n", want: ""},
+		{name: "unreadable", wantError: true},
+	}
+	oldV4, oldV6 := procNetRoute, procNetIPv6Route
+	defer func() { procNetRoute, procNetIPv6Route = oldV4, oldV6 }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			procNetRoute = filepath.Join(dir, "route")
+			procNetIPv6Route = filepath.Join(dir, "ipv6_route")
+			for path, contents := range map[string]string{procNetRoute: tt.v4, procNetIPv6Route: tt.v6} {
+				if contents == "" {
+					continue
+				}
+				if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := defaultRouteLinux()
+			if (err != nil) != tt.wantError {
+				t.Fatalf("error = %v; want error: %v", err, tt.wantError)
+			}
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslatedLinux(t *testing.T) {
+	arch, ok := qemuArch[runtime.GOARCH]
+	if !ok {
+		t.Skipf("no QEMU name for GOARCH %q", runtime.GOARCH)
+	}
+	handler := "qemu-" + arch
+	tests := []struct {
+		name   string
+		env    []string
+		exe    string            // "" means unreadable
+		binfmt map[string]string // nil means not mounted
+		want   opt.Bool
+	}{
+		{"native", []string{"HOME=/root", "PATH=/usr/bin"}, "/usr/sbin/tailscaled", map[string]string{"status": "enabled\n"}, "false"},
+		{"native_binfmt_disabled", nil, "/usr/sbin/tailscaled", map[string]string{"status": "disabled\n", handler: "enabled\n"}, "false"},
+		{"native_handler_disabled", nil, "/usr/sbin/tailscaled", map[string]string{"status": "enabled\n", handler: "disabled\ninterpreter /usr/bin/" + handler + "\n"}, "false"},
+		{"native_other_handler", nil, "/usr/sbin/tailscaled", map[string]string{"status": "enabled\n", "qemu-not" + arch: "enabled\n"}, "false"},
+		{"maybe_binfmt", nil, "/usr/sbin/tailscaled", map[string]string{"status": "enabled\n", handler: "enabled\ninterpreter /usr/bin/" + handler + "\n"}, ""},
+		{"no_binfmt_misc", nil, "/usr/sbin/tailscaled", nil, ""},
+		{"qemu_env", []string{"HOME=/root", "QEMU_LD_PREFIX=/usr/aarch64-linux-gnu"}, "/usr/sbin/tailscaled", nil, "true"},
+		{"qemu_exe", nil, "/usr/bin/qemu-aarch64-static", nil, "true"},
+		{"qemu_env_no_proc", []string{"QEMU_CPU=max"}, "", nil, "true"},
+		{"no_proc", nil, "", map[string]string{"status": "enabled\n"}, ""},
+	}
+	oldEnv, oldExe, oldBinfmt := environ, selfExeReadlink, binfmtMiscDir
+	defer func() { environ, selfExeReadlink, binfmtMiscDir = oldEnv, oldExe, oldBinfmt }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			environ = func() []string { return tt.env }
+			selfExeReadlink = func() (string, error) {
+				if tt.exe == "" {
+					return "", os.ErrPermission
+				}
+				return tt.exe, nil
+			}
+			binfmtMiscDir = filepath.Join(t.TempDir(), "binfmt_misc")
+			if tt.binfmt != nil {
+				if err := os.Mkdir(binfmtMiscDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				for name, contents := range tt.binfmt {
+					if err := os.WriteFile(filepath.Join(binfmtMiscDir, name), []byte(contents), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if got := translatedLinux(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskSpaceLinux(t *testing.T) {
+	tests := []struct {
+		name      string
+		st        unix.Statfs_t
+		err       error
+		wantFree  uint64
+		wantTotal uint64
+	}{
+		{
+			name:      "ext4",
+			st:        unix.Statfs_t{Bsize: 4096, Frsize: 4096, Blocks: 1000, Bfree: 300, Bavail: 250},
+			wantFree:  250 * 4096,
+			wantTotal: 1000 * 4096,
+		},
+		{
+			name:      "frsize_differs",
+			st:        unix.Statfs_t{Bsize: 131072, Frsize: 512, Blocks: 1000, Bavail: 100},
+			wantFree:  100 * 512,
+			wantTotal: 1000 * 512,
+		},
+		{
+			name:      "no_frsize",
+			st:        unix.Statfs_t{Bsize: 1024, Blocks: 10, Bavail: 5},
+			wantFree:  5 * 1024,
+			wantTotal: 10 * 1024,
+		},
+		{
+			name: "error",
+			err:  unix.ENOENT,
+		},
+	}
+	old := statfs
+	defer func() { statfs = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statfs = func(path string, st *unix.Statfs_t) error {
+				*st = tt.st
+				return tt.err
+			}
+			free, total := diskSpaceLinux("/var/lib/tailscale")
+			if free != tt.wantFree || total != tt.wantTotal {
+				t.Errorf("got %v, %v; want %v, %v", free, total, tt.wantFree, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestDebuggedLinux(t *testing.T) {
+	const header = "Name:\ttailscaled\nState:\tS (sleeping)\nTgid:\t1234\nPid:\t1234\nPPid:\t1\n"
+	tests := []struct {
+		name   string
+		status string // "" means missing
+		want   opt.Bool
+	}{
+		{"not_traced", header + "TracerPid:\t0\nUid:\t0\t0\t0\t0\n", "false"},
+		{"gdb", header + "TracerPid:\t5678\nUid:\t0\t0\t0\t0\n", "true"},
+		{"no_field", header, ""},
+		{"garbage", header + "TracerPid:\tnope\n", ""},
+		{"missing", "", ""},
+	}
+	old := procSelfStatus
+	defer func() { procSelfStatus = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procSelfStatus = filepath.Join(t.TempDir(), "status")
+			if tt.status != "" {
+				if err := os.WriteFile(procSelfStatus, []byte(tt.status), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := debuggedLinux(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeSyncDaemon(t *testing.T) {
+	tests := []struct {
+		name    string
+		procs   []string // nil means /proc is unreadable
+		markers []string
+		want    string
+	}{
+		{"chrony", []string{"systemd", "sshd", "chronyd", "bash"}, nil, "chronyd"},
+		{"timesyncd", []string{"systemd", "systemd-timesyn", "systemd-resolve"}, nil, "systemd-timesyncd"},
+		{"ntpd", []string{"init", "ntpd"}, []string{"/run/ntpd.pid"}, "ntpd"},
+		{"openntpd", []string{"init", "openntpd"}, nil, "openntpd"},
+		{"none_running", []string{"init", "sshd"}, nil, ""},
+		{"hidepid_chrony", []string{}, []string{"/run/chrony/chronyd.pid"}, "chronyd"},
+		{"hidepid_timesyncd", []string{}, []string{"/run/systemd/timesync/synchronized"}, "systemd-timesyncd"},
+		{"no_proc_openntpd", nil, []string{"/var/run/ntpd.sock"}, "openntpd"},
+		{"no_proc_nothing", nil, nil, ""},
+	}
+	oldProcs, oldExists := procNames, fileExists
+	defer func() { procNames, fileExists = oldProcs, oldExists }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procNames = func() ([]string, error) {
+				if tt.procs == nil {
+					return nil, os.ErrNotExist
+				}
+				return tt.procs, nil
+			}
+			fileExists = func(path string) bool {
+				for _, m := range tt.markers {
+					if m == path {
+						return true
+					}
+				}
+				return false
+			}
+			if got := timeSyncDaemon(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWireguardKernel(t *testing.T) {
+	tests := []struct {
+		name        string
+		modules     map[string]string // module name => version ("" for none)
+		noSys       bool
+		want        opt.Bool
+		wantVersion string
+	}{
+		{name: "loaded", modules: map[string]string{"tun": "1.6", "wireguard": "1.0.0"}, want: "true", wantVersion: "1.0.0"},
+		{name: "backport", modules: map[string]string{"wireguard": "1.0.20210606\n"}, want: "true", wantVersion: "1.0.20210606"},
+		{name: "no_version", modules: map[string]string{"wireguard": ""}, want: "true"},
+		{name: "absent", modules: map[string]string{"tun": "1.6"}, want: "false"},
+		{name: "no_sysfs", noSys: true, want: ""},
+	}
+	old := sysModuleDir
+	defer func() { sysModuleDir = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysModuleDir = filepath.Join(t.TempDir(), "module")
+			if !tt.noSys {
+				if err := os.Mkdir(sysModuleDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, version := range tt.modules {
+				dir := filepath.Join(sysModuleDir, name)
+				if err := os.Mkdir(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if version != "" {
+					if err := os.WriteFile(filepath.Join(dir, "version"), []byte(version), 0444); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			got, version := wireguardKernel()
+			if got != tt.want || version != tt.wantVersion {
+				t.Errorf("got %q, %q; want %q, %q", got, version, tt.want, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSelfUpdateAllowed(t *testing.T) {
+	tests := []struct {
+		name   string
+		snap   bool
+		exeErr error
+		dbs    []string // installed package managers
+		owner  string   // package manager owning the binary, if any
+		want   opt.Bool
+	}{
+		{name: "dpkg_owned", dbs: []string{"dpkg"}, owner: "dpkg", want: "false"},
+		{name: "rpm_owned", dbs: []string{"rpm"}, owner: "rpm", want: "false"},
+		{name: "pacman_owned", dbs: []string{"pacman"}, owner: "pacman", want: "false"},
+		{name: "rpm_owned_dpkg_too", dbs: []string{"dpkg", "rpm"}, owner: "rpm", want: "false"},
+		{name: "tarball_on_debian", dbs: []string{"dpkg"}, want: "true"},
+		{name: "tarball_on_fedora", dbs: []string{"rpm"}, want: "true"},
+		{name: "no_package_db", want: ""},
+		{name: "snap", snap: true, dbs: []string{"dpkg"}, want: "false"},
+		{name: "no_exe", exeErr: errors.New("no /proc"), dbs: []string{"dpkg"}, want: ""},
+	}
+	oldExe, oldOwns := osExecutable, pkgOwns
+	defer func() { osExecutable, pkgOwns = oldExe, oldOwns }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.snap {
+				t.Setenv("SNAP_NAME", "tailscale")
+				t.Setenv("SNAP", "/snap/tailscale/42")
+			} else {
+				t.Setenv("SNAP_NAME", "")
+			}
+			osExecutable = func() (string, error) { return "/usr/sbin/tailscaled", tt.exeErr }
+			pkgOwns = func(mgr, path string) (bool, error) {
+				if path != "/usr/sbin/tailscaled" {
+					t.Errorf("queried %s for %q", mgr, path)
+				}
+				for _, db := range tt.dbs {
+					if db == mgr {
+						return mgr == tt.owner, nil
+					}
+				}
+				return false, errors.New("not installed")
+			}
+			if got := selfUpdateAllowed(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPkgNotOwned(t *testing.T) {
+	tests := []struct {
+		name string
+		mgr  string
+		code int
+		out  string
+		want bool
+	}{
+		{"dpkg", "dpkg", 1, "dpkg-query: no path found matching pattern /usr/local/bin/tailscaled\n", true},
+		{"dpkg_error", "dpkg", 2, "dpkg-query: error: --search needs at least one file name pattern argument\n", false},
+		{"dpkg_locked", "dpkg", 1, "dpkg-query: error: failed to open package info file '/var/lib/dpkg/status' for reading: Permission denied\n", false},
+		{"rpm", "rpm", 1, "file /usr/local/bin/tailscaled is not owned by any package\n", true},
+		{"rpm_missing", "rpm", 1, "error: file /usr/local/bin/tailscaled: No such file or directory\n", false},
+		{"pacman", "pacman", 1, "error: No package owns /usr/local/bin/tailscaled\n", true},
+		{"pacman_db_locked", "pacman", 1, "error: failed to initialize alpm library:\n(root: /, dbpath: /var/lib/pacman/)\ncould not find or read directory\n", false},
+		{"wrong_code", "pacman", 127, "error: No package owns /usr/local/bin/tailscaled\n", false},
+		{"unknown_mgr", "apk", 1, "is not owned by any package", false},
+	}
+	for _, tt := range tests {
+		if got := pkgNotOwned(tt.mgr, tt.code, []byte(tt.out)); got != tt.want {
+			t.Errorf("%s: got %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolvedStubActive(t *testing.T) {
+	const (
+		stub   = "# This is /run/systemd/resolve/stub-resolv.conf managed by man:systemd-resolved(8).\nnameserver 127.0.0.53\noptions edns0 trust-ad\nsearch .\n"
+		upward = "# This is /run/systemd/resolve/resolv.conf managed by man:systemd-resolved(8).\nnameserver 192.168.1.1\n"
+	)
+	tests := []struct {
+		name    string
+		resolv  string // "" means unreadable
+		running opt.Bool
+		want    opt.Bool
+	}{
+		{"stub_running", stub, "true", "true"},
+		{"stub_stopped", stub, "false", "false"},
+		{"stub_unknown", stub, "", ""},
+		{"upstream_running", upward, "true", "false"},
+		{"static", "nameserver 8.8.8.8\nnameserver 127.0.0.530\n", "", "false"},
+		{"unreadable", "", "true", ""},
+	}
+	oldRead, oldRunning := resolvConfRead, resolvedRunning
+	defer func() { resolvConfRead, resolvedRunning = oldRead, oldRunning }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvConfRead = func(string) ([]byte, error) {
+				if tt.resolv == "" {
+					return nil, os.ErrNotExist
+				}
+				return []byte(tt.resolv), nil
+			}
+			resolvedRunning = func() opt.Bool { return tt.running }
+			if got := resolvedStubActive(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+
+	// And the default running probe, from the process list.
+	oldProcs := procNames
+	defer func() { procNames = oldProcs }()
+	resolvedRunning = oldRunning
+	for _, tc := range []struct {
+		procs []string
+		err   error
+		want  opt.Bool
+	}{
+		{[]string{"systemd", "systemd-resolve", "sshd"}, nil, "true"},
+		{[]string{"systemd", "sshd"}, nil, "false"},
+		{nil, nil, ""}, // /proc not mounted
+		{nil, os.ErrNotExist, ""},
+	} {
+		procNames = func() ([]string, error) { return tc.procs, tc.err }
+		if got := resolvedRunning(); got != tc.want {
+			t.Errorf("resolvedRunning with %q = %q; want %q", tc.procs, got, tc.want)
+		}
+	}
+}
+
+func TestTPMLinux(t *testing.T) {
+	tests := []struct {
+		name    string
+		noClass bool
+		tpms    map[string]string // tpm class entry => tpm_version_major ("" for none)
+		tpmrm   bool              // whether tpmrm0 exists
+		want    tpmInfo
+	}{
+		{name: "tpm2", tpms: map[string]string{"tpm0": "2\n"}, tpmrm: true, want: tpmInfo{Present: "true", Version: "2.0"}},
+		{name: "tpm12", tpms: map[string]string{"tpm0": "1\n"}, want: tpmInfo{Present: "true", Version: "1.2"}},
+		{name: "old_kernel_tpm2", tpms: map[string]string{"tpm0": ""}, tpmrm: true, want: tpmInfo{Present: "true", Version: "2.0"}},
+		{name: "old_kernel_unknown", tpms: map[string]string{"tpm0": ""}, want: tpmInfo{Present: "true"}},
+		{name: "none", tpms: map[string]string{}, want: tpmInfo{Present: "false"}},
+		{name: "no_tpm_support", noClass: true, want: tpmInfo{}},
+	}
+	oldClass, oldRM := tpmClassDir, tpmrmClassDir
+	defer func() { tpmClassDir, tpmrmClassDir = oldClass, oldRM }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tpmClassDir = filepath.Join(dir, "tpm")
+			tpmrmClassDir = filepath.Join(dir, "tpmrm")
+			if !tt.noClass {
+				if err := os.Mkdir(tpmClassDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, major := range tt.tpms {
+				d := filepath.Join(tpmClassDir, name)
+				if err := os.Mkdir(d, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if major != "" {
+					if err := os.WriteFile(filepath.Join(d, "tpm_version_major"), []byte(major), 0444); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if tt.tpmrm {
+				if err := os.MkdirAll(filepath.Join(tpmrmClassDir, "tpmrm0"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := tpmLinux(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirmwareModeLinux(t *testing.T) {
+	sb := func(v byte) []byte { return []byte{0x06, 0, 0, 0, v} }
+	tests := []struct {
+		name       string
+		goarch     string
+		noSysfs    bool
+		efi        bool
+		secureBoot []byte // contents of the SecureBoot variable, if any
+		wantMode   string
+		wantSB     opt.Bool
+	}{
+		{name: "uefi_sb_on", goarch: "amd64", efi: true, secureBoot: sb(1), wantMode: "uefi", wantSB: "true"},
+		{name: "uefi_sb_off", goarch: "amd64", efi: true, secureBoot: sb(0), wantMode: "uefi", wantSB: "false"},
+		{name: "uefi_no_var", goarch: "arm64", efi: true, wantMode: "uefi", wantSB: ""},
+		{name: "uefi_short_var", goarch: "amd64", efi: true, secureBoot: []byte{1}, wantMode: "uefi", wantSB: ""},
+		{name: "bios", goarch: "amd64", wantMode: "bios", wantSB: "false"},
+		{name: "bios_386", goarch: "386", wantMode: "bios", wantSB: "false"},
+		{name: "arm_no_efi", goarch: "arm", wantMode: "", wantSB: ""},
+		{name: "no_sysfs", goarch: "amd64", noSysfs: true, wantMode: "", wantSB: ""},
+	}
+	old := efiDir
+	defer func() { efiDir = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw := filepath.Join(t.TempDir(), "firmware")
+			efiDir = filepath.Join(fw, "efi")
+			if !tt.noSysfs {
+				if err := os.Mkdir(fw, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.efi {
+				if err := os.MkdirAll(filepath.Join(efiDir, "efivars"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.secureBoot != nil {
+				if err := os.WriteFile(filepath.Join(efiDir, secureBootEFIVar), tt.secureBoot, 0444); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := linuxFirmwareMode(tt.goarch); got != tt.wantMode {
+				t.Errorf("linuxFirmwareMode = %q; want %q", got, tt.wantMode)
+			}
+			// secureBoot consults the real GOARCH's mode, so check
+			// it via the hooks with the mode pinned.
+			defer func(f func() string) { osFirmware = f }(osFirmware)
+			osFirmware = func() string { return tt.wantMode }
+			if got := secureBoot(); got != tt.wantSB {
+				t.Errorf("secureBoot = %q; want %q", got, tt.wantSB)
+			}
+		})
+	}
+}
+
+func TestMemPressure(t *testing.T) {
+	const meminfo = `MemTotal:        8039312 kB
+MemFree:          312944 kB
+MemAvailable:    2871196 kB
+SwapCached:        25644 kB
+SwapTotal:       2097148 kB
+SwapFree:        1048576 kB
+`
+	psi := func(avg10 string) string {
+		return "some avg10=" + avg10 + " avg60=1.20 avg300=0.40 total=123456\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n"
+	}
+	tests := []struct {
+		name    string
+		meminfo string // empty means missing
+		psi     string // empty means missing
+		want    memPressureInfo
+	}{
+		{
+			name:    "calm",
+			meminfo: meminfo,
+			psi:     psi("0.31"),
+			want:    memPressureInfo{SwapTotal: 2097148 << 10, SwapFree: 1048576 << 10, UnderPressure: "false"},
+		},
+		{
+			name:    "pressure",
+			meminfo: meminfo,
+			psi:     psi("42.50"),
+			want:    memPressureInfo{SwapTotal: 2097148 << 10, SwapFree: 1048576 << 10, UnderPressure: "true"},
+		},
+		{
+			name:    "no_swap_no_psi",
+			meminfo: "MemTotal: 1000 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n",
+			want:    memPressureInfo{},
+		},
+		{
+			name: "garbage_psi",
+			psi:  "some avg10=bogus\n",
+			want: memPressureInfo{},
+		},
+	}
+	oldMem, oldPSI := procMeminfo, procPressureMemory
+	defer func() { procMeminfo, procPressureMemory = oldMem, oldPSI }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			procMeminfo = filepath.Join(dir, "meminfo")
+			procPressureMemory = filepath.Join(dir, "pressure-memory")
+			for path, content := range map[string]string{procMeminfo: tt.meminfo, procPressureMemory: tt.psi} {
+				if content == "" {
+					continue
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := memPressure(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPUMHzLinux(t *testing.T) {
+	const x86CPUInfo = `processor	: 0
+vendor_id	: GenuineIntel
+model name	: Intel(R) Core(TM) i7-8550U CPU @ 1.80GHz
+cpu MHz		: 2893.202
+cache size	: 8192 KB
+`
+	const armCPUInfo = `processor	: 0
+BogoMIPS	: 108.00
+Features	: fp asimd evtstrm crc32 cpuid
+`
+	tests := []struct {
+		name    string
+		cpuinfo string
+		cpufreq map[string]string
+		want    cpuClock
+	}{
+		{
+			name:    "x86_pstate",
+			cpuinfo: x86CPUInfo,
+			cpufreq: map[string]string{"base_frequency": "1800000\n", "cpuinfo_max_freq": "4000000\n", "scaling_cur_freq": "800000\n"},
+			want:    cpuClock{Nominal: 1800, Current: 2893.202},
+		},
+		{
+			name:    "arm_cpufreq",
+			cpuinfo: armCPUInfo,
+			cpufreq: map[string]string{"cpuinfo_max_freq": "1500000\n", "scaling_cur_freq": "600000\n"},
+			want:    cpuClock{Nominal: 1500, Current: 600},
+		},
+		{
+			name:    "vm_no_cpufreq",
+			cpuinfo: x86CPUInfo,
+			want:    cpuClock{Current: 2893.202},
+		},
+		{
+			name: "nothing",
+			want: cpuClock{},
+		},
+	}
+	oldInfo, oldFreq := procCPUInfo, cpuFreqDir
+	defer func() { procCPUInfo, cpuFreqDir = oldInfo, oldFreq }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			procCPUInfo = filepath.Join(dir, "cpuinfo")
+			cpuFreqDir = filepath.Join(dir, "cpufreq")
+			if tt.cpuinfo != "" {
+				if err := os.WriteFile(procCPUInfo, []byte(tt.cpuinfo), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Mkdir(cpuFreqDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, v := range tt.cpufreq {
+				if err := os.WriteFile(filepath.Join(cpuFreqDir, name), []byte(v), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := cpuMHzLinux(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNUMATopology(t *testing.T) {
+	oldNode, oldOnline := sysNodeDir, sysCPUOnline
+	defer func() { sysNodeDir, sysCPUOnline = oldNode, oldOnline }()
+
+	tests := []struct {
+		name  string
+		files map[string]string // relative to the node dir; "cpu/online" is the online CPUs
+		want  numaInfo
+	}{
+		{
+			name:  "single_node",
+			files: map[string]string{"node0/cpulist": "0-3\n", "online": "0\n", "possible": "0\n"},
+			want:  numaInfo{Nodes: 1, CPUsPerNode: [][]int{{0, 1, 2, 3}}},
+		},
+		{
+			name: "dual_node",
+			files: map[string]string{
+				"node0/cpulist": "0-3,8-11\n",
+				"node1/cpulist": "4-7,12-15\n",
+				"has_cpu":       "0-1\n",
+			},
+			want: numaInfo{Nodes: 2, CPUsPerNode: [][]int{{0, 1, 2, 3, 8, 9, 10, 11}, {4, 5, 6, 7, 12, 13, 14, 15}}},
+		},
+		{
+			name: "memory_only_node",
+			files: map[string]string{
+				"node0/cpulist":  "0-1\n",
+				"node10/cpulist": "\n",
+				"node2/cpulist":  "2,3\n",
+			},
+			want: numaInfo{Nodes: 3, CPUsPerNode: [][]int{{0, 1}, {2, 3}, nil}},
+		},
+		{
+			name:  "no_numa",
+			files: map[string]string{"cpu/online": "0-5\n"},
+			want:  numaInfo{Nodes: 1, CPUsPerNode: [][]int{{0, 1, 2, 3, 4, 5}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sysNodeDir = filepath.Join(dir, "node")
+			sysCPUOnline = filepath.Join(dir, "cpu/online")
+			for name, content := range tt.files {
+				p := filepath.Join(sysNodeDir, name)
+				if name == "cpu/online" {
+					p = sysCPUOnline
+				}
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := numaTopology(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Without sysfs at all, all of Go's CPUs are on one node.
+	sysNodeDir, sysCPUOnline = "/nonexistent/node", "/nonexistent/online"
+	got := numaTopology()
+	if got.Nodes != 1 || len(got.CPUsPerNode) != 1 || len(got.CPUsPerNode[0]) != runtime.NumCPU() {
+		t.Errorf("without sysfs, got %+v", got)
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	for _, bad := range []string{"a", "0-", "3-1", "0,,1", "-1"} {
+		if got, err := parseCPUList(bad); err == nil {
+			t.Errorf("parseCPUList(%q) = %v; want error", bad, got)
+		}
+	}
+}
+
+func TestFormFactorLinux(t *testing.T) {
+	tests := []struct {
+		chassisType string // empty means missing
+		want        string
+	}{
+		{"3\n", "desktop"},
+		{"7\n", "desktop"},
+		{"35\n", "desktop"},
+		{"9\n", "laptop"},
+		{"10\n", "laptop"},
+		{"31\n", "laptop"},
+		{"30\n", "tablet"},
+		{"17\n", "server"},
+		{"23\n", "server"},
+		{"1\n", ""},
+		{"2\n", ""},
+		{"bogus\n", ""},
+		{"", ""},
+	}
+	old := dmiDir
+	defer func() { dmiDir = old }()
+	for _, tt := range tests {
+		dmiDir = t.TempDir()
+		if tt.chassisType != "" {
+			if err := os.WriteFile(filepath.Join(dmiDir, "chassis_type"), []byte(tt.chassisType), 0444); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := formFactorLinux(); got != tt.want {
+			t.Errorf("chassis_type %q: got %q; want %q", tt.chassisType, got, tt.want)
+		}
+	}
+}
+
+func TestDMILinux(t *testing.T) {
+	old := dmiDir
+	defer func() { dmiDir = old }()
+	dmiDir = t.TempDir()
+	for name, v := range map[string]string{
+		"bios_vendor":       "Microsoft Corporation\n",
+		"sys_vendor":        "Microsoft Corporation\n",
+		"product_name":      "Virtual Machine\n",
+		"chassis_asset_tag": azureAssetTag + "\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dmiDir, name), []byte(v), 0444); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := dmiInfo{
+		BIOSVendor:      "Microsoft Corporation",
+		SysVendor:       "Microsoft Corporation",
+		ProductName:     "Virtual Machine",
+		ChassisAssetTag: azureAssetTag,
+	}
+	if got := dmiLinux(); got != want {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestHypervisorLinux(t *testing.T) {
+	tests := []struct {
+		name    string
+		cpuinfo string
+		want    opt.Bool
+	}{
+		{"vm", "processor\t: 0\nflags\t\t: fpu vme de pse hypervisor lahf_lm\n", "true"},
+		{"bare_metal", "processor\t: 0\nflags\t\t: fpu vme de pse lahf_lm\n", "false"},
+		{"arm", "processor\t: 0\nFeatures\t: fp asimd\n", ""},
+	}
+	old := procCPUInfo
+	defer func() { procCPUInfo = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procCPUInfo = filepath.Join(t.TempDir(), "cpuinfo")
+			if err := os.WriteFile(procCPUInfo, []byte(tt.cpuinfo), 0444); err != nil {
+				t.Fatal(err)
+			}
+			if got := hypervisorLinux(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostnameSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		etcHostname string // empty means missing
+		machineInfo string // empty means missing
+		kernel      string
+		want        hostnames
+	}{
+		{
+			name:        "all",
+			etcHostname: "# managed by hostnamectl\nbox\n",
+			machineInfo: "CHASSIS=laptop\nPRETTY_HOSTNAME=\"Alice's Laptop\"\n",
+			kernel:      "box",
+			want:        hostnames{Static: "box", Transient: "box", Pretty: "Alice's Laptop"},
+		},
+		{
+			name:        "dhcp_transient",
+			etcHostname: "localhost\n",
+			kernel:      "ip-10-0-0-5",
+			want:        hostnames{Static: "localhost", Transient: "ip-10-0-0-5"},
+		},
+		{
+			name:   "no_files",
+			kernel: "box",
+			want:   hostnames{Transient: "box"},
+		},
+	}
+	oldHostname, oldInfo, oldOS := etcHostname, etcMachineInfo, osHostname
+	defer func() { etcHostname, etcMachineInfo, osHostname = oldHostname, oldInfo, oldOS }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			etcHostname = filepath.Join(dir, "hostname")
+			etcMachineInfo = filepath.Join(dir, "machine-info")
+			for path, content := range map[string]string{etcHostname: tt.etcHostname, etcMachineInfo: tt.machineInfo} {
+				if content == "" {
+					continue
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			osHostname = func() (string, error) { return tt.kernel, nil }
+			if got := hostnameSource(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResumeTimeLinux(t *testing.T) {
+	old := suspendedTotal
+	defer func() {
+		suspendedTotal = old
+		resumeState.sampled, resumeState.suspended, resumeState.lastResume = false, 0, time.Time{}
+	}()
+	var total time.Duration
+	var err error
+	suspendedTotal = func() (time.Duration, error) { return total, err }
+
+	total = 5 * time.Minute // slept before we started; can't tell when
+	if got := resumeTimeLinux(); !got.IsZero() {
+		t.Fatalf("first call = %v; want zero", got)
+	}
+	total += 10 * time.Millisecond // clock read skew
+	if got := resumeTimeLinux(); !got.IsZero() {
+		t.Fatalf("after jitter = %v; want zero", got)
+	}
+	before := time.Now()
+	total += time.Hour // suspended for an hour
+	got := resumeTimeLinux()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Fatalf("after suspend = %v; want between %v and now", got, before)
+	}
+	if again := resumeTimeLinux(); !again.Equal(got) {
+		t.Errorf("next call = %v; want unchanged %v", again, got)
+	}
+	err = errors.New("no clock")
+	if got := resumeTimeLinux(); !got.IsZero() {
+		t.Errorf("on error = %v; want zero", got)
+	}
+
+	// The real clocks work.
+	suspendedTotal = old
+	if _, err := suspendedTotal(); err != nil {
+		t.Errorf("suspendedTotal: %v", err)
+	}
+}
+
+func TestNetAdapters(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(p string) string {
+		t.Helper()
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write := func(p, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, p), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, p string) {
+		t.Helper()
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range []string{"bus/pci", "bus/usb", "bus/virtio", "drivers/e1000e", "drivers/r8152", "drivers/virtio_net"} {
+		mkdir(d)
+	}
+
+	// A PCI NIC.
+	mkdir("devices/pci0000:00/0000:00:1f.6")
+	write("devices/pci0000:00/0000:00:1f.6/vendor", "0x8086\n")
+	write("devices/pci0000:00/0000:00:1f.6/device", "0x15bb\n")
+	link("bus/pci", "devices/pci0000:00/0000:00:1f.6/subsystem")
+	link("drivers/e1000e", "devices/pci0000:00/0000:00:1f.6/driver")
+
+	// A USB NIC, whose device is a USB interface.
+	mkdir("devices/usb1/1-1/1-1:1.0")
+	write("devices/usb1/1-1/idVendor", "0bda\n")
+	write("devices/usb1/1-1/idProduct", "8153\n")
+	link("bus/usb", "devices/usb1/1-1/1-1:1.0/subsystem")
+	link("drivers/r8152", "devices/usb1/1-1/1-1:1.0/driver")
+
+	// A virtio NIC.
+	mkdir("devices/pci0000:00/0000:00:03.0/virtio0")
+	write("devices/pci0000:00/0000:00:03.0/virtio0/vendor", "0x1af4\n")
+	write("devices/pci0000:00/0000:00:03.0/virtio0/device", "0x0001\n")
+	link("bus/virtio", "devices/pci0000:00/0000:00:03.0/virtio0/subsystem")
+	link("drivers/virtio_net", "devices/pci0000:00/0000:00:03.0/virtio0/driver")
+
+	mkdir("class/net/lo")
+	mkdir("class/net/tailscale0")
+	for iface, dev := range map[string]string{
+		"eth0": "devices/pci0000:00/0000:00:1f.6",
+		"enx0": "devices/usb1/1-1/1-1:1.0",
+		"ens3": "devices/pci0000:00/0000:00:03.0/virtio0",
+	} {
+		mkdir("class/net/" + iface)
+		link(dev, "class/net/"+iface+"/device")
+	}
+
+	old := sysClassNet
+	defer func() { sysClassNet = old }()
+	sysClassNet = filepath.Join(root, "class/net")
+	want := []string{
+		"ens3: virtio_net (virtio 1af4:0001)",
+		"enx0: r8152 (usb 0bda:8153)",
+		"eth0: e1000e (pci 8086:15bb)",
+	}
+	if got := netAdapters(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	sysClassNet = filepath.Join(root, "class/none")
+	if got := netAdapters(); len(got) != 0 {
+		t.Errorf("without sysfs, got %q; want none", got)
+	}
+}
+
+func TestSRIOV(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(p string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(root, p), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, p string) {
+		t.Helper()
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A PF with two VFs, all with interfaces.
+	const pf0 = "devices/pci0000:00/0000:3b:00.0"
+	mkdir(pf0 + "/net/ens1f0")
+	for _, vf := range []string{"0000:3b:02.0", "0000:3b:02.1"} {
+		mkdir("devices/pci0000:00/" + vf)
+		link(pf0, "devices/pci0000:00/"+vf+"/physfn")
+	}
+	// A PF passed through to a VM, so without an interface, and its VF.
+	const pf1 = "devices/pci0000:00/0000:5e:00.0"
+	mkdir(pf1)
+	mkdir("devices/pci0000:00/0000:5e:02.0")
+	link(pf1, "devices/pci0000:00/0000:5e:02.0/physfn")
+
+	mkdir("class/net/lo")
+	for iface, dev := range map[string]string{
+		"ens1f0":   pf0,
+		"ens1f0v0": "devices/pci0000:00/0000:3b:02.0",
+		"ens1f0v1": "devices/pci0000:00/0000:3b:02.1",
+		"ens2v0":   "devices/pci0000:00/0000:5e:02.0",
+	} {
+		mkdir("class/net/" + iface)
+		link(dev, "class/net/"+iface+"/device")
+	}
+
+	old := sysClassNet
+	defer func() { sysClassNet = old }()
+	sysClassNet = filepath.Join(root, "class/net")
+	want := []sriovVF{
+		{"ens1f0v0", "ens1f0"},
+		{"ens1f0v1", "ens1f0"},
+		{"ens2v0", "0000:5e:00.0"},
+	}
+	if got := sriov(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+
+	sysClassNet = filepath.Join(root, "class/none")
+	if got := sriov(); len(got) != 0 {
+		t.Errorf("without sysfs, got %+v; want none", got)
+	}
+}
+
+func TestNICOffloads(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"eth0/device", "eth1/device", "tailscale0"} {
+		if err := os.MkdirAll(filepath.Join(root, p), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldNet, oldGet := sysClassNet, ethtoolGetValue
+	defer func() { sysClassNet, ethtoolGetValue = oldNet, oldGet }()
+	sysClassNet = root
+	ethtoolGetValue = func(iface string, cmd uint32) (uint32, error) {
+		switch iface {
+		case "eth0":
+			switch cmd {
+			case unix.ETHTOOL_GGRO, unix.ETHTOOL_GRXCSUM, unix.ETHTOOL_GTXCSUM:
+				return 1, nil
+			case unix.ETHTOOL_GTSO:
+				return 0, unix.EOPNOTSUPP
+			}
+			return 0, nil
+		case "tailscale0":
+			t.Error("queried a virtual interface")
+		}
+		return 0, unix.EOPNOTSUPP
+	}
+
+	tests := []struct {
+		iface string
+		want  map[string]bool
+	}{
+		{"eth0", map[string]bool{"gro": true, "gso": false, "rx-checksum": true, "tx-checksum": true}},
+		{"eth1", map[string]bool{}}, // no ethtool support
+		{"tailscale0", map[string]bool{}},
+		{"missing0", map[string]bool{}},
+	}
+	for _, tt := range tests {
+		if got := nicOffloads(tt.iface); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("nicOffloads(%q) = %v; want %v", tt.iface, got, tt.want)
+		}
+	}
+}
+
+func TestLinkSpeed(t *testing.T) {
+	root := t.TempDir()
+	for iface, speed := range map[string]string{
+		"eth0":       "1000\n",
+		"eth1":       "-1\n", // link down
+		"enp5s0":     "25000\n",
+		"tailscale0": "-1\n",
+		"bogus0":     "fast\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, iface), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, iface, "speed"), []byte(speed), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldNet, oldV4, oldV6 := sysClassNet, procNetRoute, procNetIPv6Route
+	defer func() { sysClassNet, procNetRoute, procNetIPv6Route = oldNet, oldV4, oldV6 }()
+	sysClassNet = root
+
+	for iface, want := range map[string]int{
+		"eth0":       1000,
+		"eth1":       0,
+		"enp5s0":     25000,
+		"tailscale0": 0,
+		"bogus0":     0,
+		"missing0":   0,
+	} {
+		if got := linkSpeed(iface); got != want {
+			t.Errorf("linkSpeed(%q) = %d; want %d", iface, got, want)
+		}
+	}
+
+	procNetRoute = filepath.Join(root, "route")
+	procNetIPv6Route = filepath.Join(root, "ipv6_route")
+	const header = "Iface\tDestination\tGateway\tFlags\tRefCnt\tUse\tMetric\tMask\tMTU\tWindow\tIRTT\n"
+	const route = header + "enp5s0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"
+	if err := os.WriteFile(procNetRoute, []byte(route), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := defaultLinkSpeed(); got != 25000 {
+		t.Errorf("defaultLinkSpeed() = %d; want 25000", got)
+	}
+	if err := os.WriteFile(procNetRoute, []byte(header), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := defaultLinkSpeed(); got != 0 {
+		t.Errorf("without a default route, defaultLinkSpeed() = %d; want 0", got)
+	}
+}
+
+func TestHypervisorName(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string // relative to a fake /sys
+		want  string
+	}{
+		{
+			name: "dmi_vmware",
+			files: map[string]string{
+				"class/dmi/id/sys_vendor":   "VMware, Inc.\n",
+				"class/dmi/id/product_name": "VMware Virtual Platform\n",
+			},
+			want: "vmware",
+		},
+		{
+			name: "virtio",
+			files: map[string]string{
+				"class/dmi/id/sys_vendor":             "Google\n",
+				"class/dmi/id/product_name":           "Google Compute Engine\n",
+				"bus/virtio/devices/virtio0/vendor":   "0x1af4\n",
+				"bus/pci/devices/0000:00:04.0/vendor": "0x1af4\n",
+			},
+			want: "kvm",
+		},
+		{
+			name: "vmxnet",
+			files: map[string]string{
+				"class/dmi/id/sys_vendor":             "Some OEM\n",
+				"bus/pci/devices/0000:00:00.0/vendor": "0x8086\n",
+				"bus/pci/devices/0000:0b:00.0/vendor": "0x15ad\n", // vmxnet3
+			},
+			want: "vmware",
+		},
+		{
+			name: "hyperv_vmbus",
+			files: map[string]string{
+				"bus/vmbus/devices/f8b3781a-1e82-4818-a1c3-63d806ec15bb/class_id": "{f8615163-df3e-46c5-913f-f2d2f965ed0e}\n",
+			},
+			want: "hyperv",
+		},
+		{
+			name: "bare_metal",
+			files: map[string]string{
+				"class/dmi/id/sys_vendor":             "LENOVO\n",
+				"bus/pci/devices/0000:00:00.0/vendor": "0x8086\n",
+				"bus/pci/devices/0000:01:00.0/vendor": "0x10de\n",
+			},
+			want: "",
+		},
+	}
+	oldDMI, oldBus, oldPCI := dmiDir, sysBusDir, pciDevicesDir
+	defer func() { dmiDir, sysBusDir, pciDevicesDir = oldDMI, oldBus, oldPCI }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := t.TempDir()
+			dmiDir = filepath.Join(sys, "class/dmi/id")
+			sysBusDir = filepath.Join(sys, "bus")
+			pciDevicesDir = filepath.Join(sys, "bus/pci/devices")
+			for name, content := range tt.files {
+				path := filepath.Join(sys, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := hypervisorName(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootStorage(t *testing.T) {
+	const mountinfo = "22 1 0:21 / /proc rw - proc proc rw\n" +
+		"25 1 %s / / rw,relatime - ext4 /dev/root rw\n"
+	tests := []struct {
+		name  string
+		devno string
+		files map[string]string
+		want  storageInfo
+	}{
+		{
+			name:  "sd_card",
+			devno: "179:2",
+			files: map[string]string{
+				"mmcblk0/dev":              "179:0\n",
+				"mmcblk0/device/type":      "SD\n",
+				"mmcblk0/queue/rotational": "0\n",
+				"mmcblk0/mmcblk0p1/dev":    "179:1\n",
+				"mmcblk0/mmcblk0p2/dev":    "179:2\n",
+			},
+			want: storageInfo{Device: "mmcblk0", Type: "sd"},
+		},
+		{
+			name:  "emmc",
+			devno: "179:1",
+			files: map[string]string{
+				"mmcblk1/dev":           "179:0\n",
+				"mmcblk1/device/type":   "MMC\n",
+				"mmcblk1/mmcblk1p1/dev": "179:1\n",
+			},
+			want: storageInfo{Device: "mmcblk1", Type: "emmc"},
+		},
+		{
+			name:  "nvme",
+			devno: "259:2",
+			files: map[string]string{
+				"nvme0n1/dev":              "259:0\n",
+				"nvme0n1/queue/rotational": "0\n",
+				"nvme0n1/nvme0n1p1/dev":    "259:1\n",
+				"nvme0n1/nvme0n1p2/dev":    "259:2\n",
+			},
+			want: storageInfo{Device: "nvme0n1", Type: "nvme"},
+		},
+		{
+			name:  "spinning_disk",
+			devno: "8:17",
+			files: map[string]string{
+				"sda/dev":              "8:0\n",
+				"sda/queue/rotational": "0\n",
+				"sda/sda1/dev":         "8:1\n",
+				"sdb/dev":              "8:16\n",
+				"sdb/queue/rotational": "1\n",
+				"sdb/sdb1/dev":         "8:17\n",
+			},
+			want: storageInfo{Device: "sdb", Type: "hdd"},
+		},
+		{
+			name:  "whole_disk",
+			devno: "8:0",
+			files: map[string]string{
+				"sda/dev":              "8:0\n",
+				"sda/queue/rotational": "0\n",
+			},
+			want: storageInfo{Device: "sda", Type: "ssd"},
+		},
+		{
+			name:  "no_block_device",
+			devno: "0:25",
+			files: map[string]string{
+				"sda/dev":      "8:0\n",
+				"sda/sda1/dev": "8:1\n",
+			},
+			want: storageInfo{},
+		},
+	}
+	oldMountinfo, oldBlock := procSelfMountinfo, sysBlockDir
+	defer func() { procSelfMountinfo, sysBlockDir = oldMountinfo, oldBlock }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			procSelfMountinfo = filepath.Join(dir, "mountinfo")
+			if err := os.WriteFile(procSelfMountinfo, []byte(fmt.Sprintf(mountinfo, tt.devno)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			sysBlockDir = filepath.Join(dir, "block")
+			for name, content := range tt.files {
+				path := filepath.Join(sysBlockDir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := rootStorage(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+
+	procSelfMountinfo = filepath.Join(t.TempDir(), "missing")
+	if got := rootStorage(); got != (storageInfo{}) {
+		t.Errorf("without mountinfo: got %+v; want zero", got)
+	}
+}
+
+func TestClockSource(t *testing.T) {
+	old := clockSourceDir
+	defer func() { clockSourceDir = old }()
+
+	for _, tt := range []struct {
+		name    string
+		content string // of current_clocksource; empty for none
+		want    string
+	}{
+		{"tsc", "tsc\n", "tsc"},
+		{"kvm", "kvm-clock\n", "kvm-clock"},
+		{"xen", "xen\n", "xen"},
+		{"hpet", "hpet\n", "hpet"},
+		{"missing", "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clockSourceDir = t.TempDir()
+			if tt.content != "" {
+				if err := os.WriteFile(filepath.Join(clockSourceDir, "current_clocksource"), []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := clockSource(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntropyAvailable(t *testing.T) {
+	tests := []struct {
+		name        string
+		entropy     string // or "" for no file
+		rngCurrent  string // or "" for no hw_random device
+		wantEntropy int
+		wantRNG     string
+	}{
+		{"hwrng", "3782\n", "virtio_rng.0\n", 3782, "virtio_rng.0"},
+		{"low_no_hwrng", "41\n", "", 41, ""},
+		{"hwrng_none", "256\n", "none\n", 256, ""},
+		{"no_proc", "", "", -1, ""},
+		{"garbage", "lots\n", "", -1, ""},
+	}
+	oldEntropy, oldRNG := procEntropyAvail, hwRandomDir
+	defer func() { procEntropyAvail, hwRandomDir = oldEntropy, oldRNG }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			procEntropyAvail = filepath.Join(dir, "entropy_avail")
+			hwRandomDir = filepath.Join(dir, "hw_random")
+			if tt.entropy != "" {
+				if err := os.WriteFile(procEntropyAvail, []byte(tt.entropy), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.rngCurrent != "" {
+				if err := os.Mkdir(hwRandomDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(hwRandomDir, "rng_current"), []byte(tt.rngCurrent), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := entropyAvailable(); got != tt.wantEntropy {
+				t.Errorf("entropyAvailable = %d; want %d", got, tt.wantEntropy)
+			}
+			if got := hardwareRNG(); got != tt.wantRNG {
+				t.Errorf("hardwareRNG = %q; want %q", got, tt.wantRNG)
+			}
+		})
+	}
+}
+
+func TestIOMMU(t *testing.T) {
+	tests := []struct {
+		name    string
+		iommus  []string // or nil for no /sys/class/iommu
+		cmdline string
+		want    opt.Bool
+	}{
+		{"intel", []string{"dmar0", "dmar1"}, "BOOT_IMAGE=/vmlinuz intel_iommu=on", "true"},
+		{"amd", []string{"ivhd0"}, "BOOT_IMAGE=/vmlinuz quiet", "true"},
+		{"none", []string{}, "BOOT_IMAGE=/vmlinuz quiet", "false"},
+		{"cmdline_off", nil, "BOOT_IMAGE=/vmlinuz intel_iommu=off", "false"},
+		{"cmdline_iommu_off", nil, "iommu=off", "false"},
+		{"cmdline_on_no_sysfs", nil, "amd_iommu=on", ""},
+		{"unknown", nil, "", ""},
+	}
+	oldIOMMU, oldCmdline := sysClassIOMMU, procCmdline
+	defer func() { sysClassIOMMU, procCmdline = oldIOMMU, oldCmdline }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sysClassIOMMU = filepath.Join(dir, "iommu")
+			procCmdline = filepath.Join(dir, "cmdline")
+			if tt.iommus != nil {
+				for _, name := range append([]string{""}, tt.iommus...) {
+					if err := os.MkdirAll(filepath.Join(sysClassIOMMU, name), 0755); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if tt.cmdline != "" {
+				if err := os.WriteFile(procCmdline, []byte(tt.cmdline+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := iommu(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNTPStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string // by base name of the config path
+		offset   int64
+		status   int64
+		maxError int64
+		err      error
+		want     ntpInfo
+	}{
+		{
+			name: "chrony",
+			files: map[string]string{
+				"chrony.conf": "# Use public servers\npool 2.debian.pool.ntp.org iburst\nserver time.example.com iburst prefer\nmakestep 1 3\n",
+			},
+			offset:   -1500,
+			maxError: 4500,
+			want: ntpInfo{
+				Servers:    []string{"2.debian.pool.ntp.org", "time.example.com"},
+				LastOffset: -1500 * time.Microsecond,
+				Synced:     "true",
+			},
+		},
+		{
+			name: "timesyncd",
+			files: map[string]string{
+				"timesyncd.conf": "[Time]\nNTP=ntp1.example.com ntp2.example.com\n#FallbackNTP=time.example.org\n",
+			},
+			offset:   250_000,
+			status:   staNano,
+			maxError: 16_000_000,
+			want: ntpInfo{
+				Servers:    []string{"ntp1.example.com", "ntp2.example.com"},
+				LastOffset: 250 * time.Microsecond,
+				Synced:     "false",
+			},
+		},
+		{
+			name: "duplicates",
+			files: map[string]string{
+				"chrony.conf":    "server a.example.com\n",
+				"timesyncd.conf": "[Time]\nNTP=a.example.com b.example.com\n",
+			},
+			maxError: 4500,
+			want: ntpInfo{
+				Servers: []string{"a.example.com", "b.example.com"},
+				Synced:  "true",
+			},
+		},
+		{
+			name: "unavailable",
+			err:  unix.EPERM,
+			want: ntpInfo{},
+		},
+	}
+	oldFiles, oldAdjtimex := ntpConfigFiles, adjtimex
+	defer func() { ntpConfigFiles, adjtimex = oldFiles, oldAdjtimex }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ntpConfigFiles = nil
+			for _, f := range oldFiles {
+				ntpConfigFiles = append(ntpConfigFiles, struct {
+					path    string
+					systemd bool
+				}{filepath.Join(dir, filepath.Base(f.path)), f.systemd})
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			adjtimex = func(tx *unix.Timex) (int, error) {
+				setInt(&tx.Offset, tt.offset)
+				tx.Status = int32(tt.status)
+				setInt(&tx.Maxerror, tt.maxError)
+				return 0, tt.err
+			}
+			if got := ntpStatus(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetStackReason(t *testing.T) {
+	const (
+		netAdmin = "CapEff:\t0000000000001000\n"
+		noCaps   = "CapEff:\t0000000000000000\n"
+	)
+	tests := []struct {
+		name   string
+		tun    string // "chardev", "file" or "missing"
+		status string // "" means missing
+		want   string
+	}{
+		{"kernel_ok", "chardev", netAdmin, ""},
+		{"no_tun", "missing", netAdmin, "no-tun-device"},
+		{"tun_not_device", "file", netAdmin, "no-tun-device"},
+		{"no_net_admin", "chardev", noCaps, "no-net-admin"},
+		{"neither", "missing", noCaps, "no-tun-device"},
+		{"caps_unknown", "chardev", "", ""},
+	}
+	oldTun, oldStatus := tunDevice, procSelfStatus
+	defer func() { tunDevice, procSelfStatus = oldTun, oldStatus }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			switch tt.tun {
+			case "chardev":
+				if _, err := os.Stat("/dev/null"); err != nil {
+					t.Skip("no /dev/null")
+				}
+				tunDevice = "/dev/null"
+			case "file":
+				tunDevice = filepath.Join(dir, "tun")
+				if err := os.WriteFile(tunDevice, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			case "missing":
+				tunDevice = filepath.Join(dir, "net/tun")
+			}
+			procSelfStatus = filepath.Join(dir, "status")
+			if tt.status != "" {
+				if err := os.WriteFile(procSelfStatus, []byte(tt.status), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := netStackReason(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKernelCmdline(t *testing.T) {
+	const cmdline = `BOOT_IMAGE=/boot/vmlinuz-5.15.0-46-generic root=UUID=0a1b2c3d ro quiet splash ` +
+		`ipv6.disable=1 net.ifnames=0 console=tty0 console=ttyS0,115200n8 ` +
+		`"dyndbg=file drivers/net/tun.c +p" acpi_osi="!Windows 2012" ip=dhcp -- single init_arg=1` + "\n"
+	old := procCmdline
+	defer func() { procCmdline = old }()
+	procCmdline = filepath.Join(t.TempDir(), "cmdline")
+	if err := os.WriteFile(procCmdline, []byte(cmdline), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"BOOT_IMAGE":   "/boot/vmlinuz-5.15.0-46-generic",
+		"root":         "UUID=0a1b2c3d",
+		"ro":           "",
+		"quiet":        "",
+		"splash":       "",
+		"ipv6.disable": "1",
+		"net.ifnames":  "0",
+		"console":      "ttyS0,115200n8", // the last one wins
+		"dyndbg":       "file drivers/net/tun.c +p",
+		"acpi_osi":     "!Windows 2012",
+		"ip":           "dhcp",
+	}
+	if got := kernelCmdline(); !reflect.DeepEqual(got, want) {
+		t.Errorf("kernelCmdline = %q; want %q", got, want)
+	}
+
+	wantNet := map[string]string{
+		"ipv6.disable": "1",
+		"net.ifnames":  "0",
+		"ip":           "dhcp",
+	}
+	if got := netCmdline(); !reflect.DeepEqual(got, wantNet) {
+		t.Errorf("netCmdline = %q; want %q", got, wantNet)
+	}
+
+	procCmdline = filepath.Join(t.TempDir(), "missing")
+	if got := kernelCmdline(); got != nil {
+		t.Errorf("missing cmdline = %q; want nil", got)
+	}
+	if got := netCmdline(); len(got) != 0 {
+		t.Errorf("missing netCmdline = %q; want empty", got)
+	}
+}
+
+func TestDNSServersLinux(t *testing.T) {
+	const (
+		stub     = "# This is /run/systemd/resolve/stub-resolv.conf managed by man:systemd-resolved(8).\nnameserver 127.0.0.53\noptions edns0 trust-ad\nsearch .\n"
+		upstream = "# This is /run/systemd/resolve/resolv.conf managed by man:systemd-resolved(8).\nnameserver 192.168.1.1\nnameserver 2001:db8::1\nsearch lan\n"
+		static   = "nameserver 8.8.8.8\n"
+	)
+	tests := []struct {
+		name     string
+		files    map[string]string
+		running  opt.Bool
+		want     []netip.Addr
+		wantOK   bool
+		wantAddr []netip.Addr // from dnsServers
+	}{
+		{
+			name:     "resolved_stub",
+			files:    map[string]string{"/etc/resolv.conf": stub, resolvedUpstreamConf: upstream},
+			running:  "true",
+			want:     []netip.Addr{netip.MustParseAddr("192.168.1.1"), netip.MustParseAddr("2001:db8::1")},
+			wantOK:   true,
+			wantAddr: []netip.Addr{netip.MustParseAddr("192.168.1.1"), netip.MustParseAddr("2001:db8::1")},
+		},
+		{
+			name:     "resolved_stopped",
+			files:    map[string]string{"/etc/resolv.conf": stub, resolvedUpstreamConf: upstream},
+			running:  "false",
+			wantAddr: []netip.Addr{netip.MustParseAddr("127.0.0.53")},
+		},
+		{
+			name:     "no_upstream_file",
+			files:    map[string]string{"/etc/resolv.conf": stub},
+			running:  "true",
+			wantAddr: []netip.Addr{netip.MustParseAddr("127.0.0.53")},
+		},
+		{
+			name:     "static",
+			files:    map[string]string{"/etc/resolv.conf": static},
+			running:  "true",
+			wantAddr: []netip.Addr{netip.MustParseAddr("8.8.8.8")},
+		},
+	}
+	oldRead, oldRunning := resolvConfRead, resolvedRunning
+	defer func() { resolvConfRead, resolvedRunning = oldRead, oldRunning }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvConfRead = func(path string) ([]byte, error) {
+				if s, ok := tt.files[path]; ok {
+					return []byte(s), nil
+				}
+				return nil, os.ErrNotExist
+			}
+			resolvedRunning = func() opt.Bool { return tt.running }
+			got, ok := dnsServersLinux()
+			if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOK {
+				t.Errorf("dnsServersLinux = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if got := dnsServers(); !reflect.DeepEqual(got, tt.wantAddr) {
+				t.Errorf("dnsServers = %v; want %v", got, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestDNSEncryption(t *testing.T) {
+	const (
+		stub = "nameserver 127.0.0.53\noptions edns0 trust-ad\n"
+		dot  = "[Resolve]\nDNS=1.1.1.1#cloudflare-dns.com\nDNSOverTLS=yes\n"
+	)
+	tests := []struct {
+		name     string
+		resolv   string // /etc/resolv.conf
+		upstream string // resolved's upstream resolv.conf
+		running  opt.Bool
+		conf     string            // resolved.conf
+		dropins  map[string]string // resolved.conf.d
+		want     dnsEncryptionInfo
+	}{
+		{
+			name:     "resolved_dot",
+			resolv:   stub,
+			upstream: "nameserver 1.1.1.1\n",
+			running:  "true",
+			conf:     dot,
+			want:     dnsEncryptionInfo{DoT: "true", DoH: "false", Provider: "Cloudflare"},
+		},
+		{
+			name:     "resolved_opportunistic_dropin",
+			resolv:   stub,
+			upstream: "nameserver 192.168.1.1\nnameserver 9.9.9.9\n",
+			running:  "true",
+			conf:     "[Resolve]\n#DNSOverTLS=no\n",
+			dropins:  map[string]string{"10-dot.conf": "[Resolve]\nDNSOverTLS=no\n", "20-dot.conf": "[Resolve]\nDNSOverTLS=opportunistic\n"},
+			want:     dnsEncryptionInfo{DoH: "false", Provider: "Quad9"},
+		},
+		{
+			name:     "resolved_dot_dropin",
+			resolv:   stub,
+			upstream: "nameserver 9.9.9.9\n",
+			running:  "true",
+			conf:     "[Resolve]\nDNSOverTLS=opportunistic\n",
+			dropins:  map[string]string{"10-dot.conf": "[Resolve]\nDNSOverTLS=yes\n"},
+			want:     dnsEncryptionInfo{DoT: "true", DoH: "false", Provider: "Quad9"},
+		},
+		{
+			name:     "resolved_plain",
+			resolv:   stub,
+			upstream: "nameserver 192.168.1.1\n",
+			running:  "true",
+			conf:     "[Resolve]\nDNS=192.168.1.1\n",
+			want:     dnsEncryptionInfo{DoT: "false", DoH: "false"},
+		},
+		{
+			name:     "resolved_wrong_section",
+			resolv:   stub,
+			upstream: "nameserver 8.8.8.8\n",
+			running:  "true",
+			conf:     "[Other]\nDNSOverTLS=yes\n",
+			want:     dnsEncryptionInfo{DoT: "false", DoH: "false", Provider: "Google"},
+		},
+		{
+			name:   "plain_public",
+			resolv: "nameserver 8.8.8.8\nnameserver 8.8.4.4\n",
+			conf:   dot, // resolved isn't in use
+			want:   dnsEncryptionInfo{DoT: "false", DoH: "false", Provider: "Google"},
+		},
+		{
+			name:   "local_proxy",
+			resolv: "nameserver 127.0.0.1\n",
+			want:   dnsEncryptionInfo{},
+		},
+		{
+			name: "no_resolv_conf",
+			want: dnsEncryptionInfo{},
+		},
+	}
+	oldRead, oldRunning := resolvConfRead, resolvedRunning
+	oldConf, oldDropin := resolvedConf, resolvedConfDropin
+	defer func() {
+		resolvConfRead, resolvedRunning = oldRead, oldRunning
+		resolvedConf, resolvedConfDropin = oldConf, oldDropin
+	}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"/etc/resolv.conf": tt.resolv, resolvedUpstreamConf: tt.upstream}
+			resolvConfRead = func(path string) ([]byte, error) {
+				if s := files[path]; s != "" {
+					return []byte(s), nil
+				}
+				return nil, os.ErrNotExist
+			}
+			resolvedRunning = func() opt.Bool { return tt.running }
+			dir := t.TempDir()
+			resolvedConf = filepath.Join(dir, "resolved.conf")
+			resolvedConfDropin = filepath.Join(dir, "resolved.conf.d")
+			if tt.conf != "" {
+				if err := os.WriteFile(resolvedConf, []byte(tt.conf), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Mkdir(resolvedConfDropin, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.dropins {
+				if err := os.WriteFile(filepath.Join(resolvedConfDropin, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := dnsEncryption(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,8 +6,14 @@
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -50,3 +56,591 @@ func TestEtcAptSourceFileIsDisabled(t *testing.T) {
 		})
 	}
 }
+
+func TestHashMachineID(t *testing.T) {
+	const raw = "c43b2a7c0b7e4bfd9a7d36e8ee1f2a61"
+	got := hashMachineID(raw)
+	if len(got) != 32 {
+		t.Errorf("hashMachineID = %q; want 32 hex digits", got)
+	}
+	if strings.Contains(got, raw) {
+		t.Errorf("hashMachineID = %q; contains raw ID", got)
+	}
+	if again := hashMachineID(raw + "\n"); again != got {
+		t.Errorf("hashMachineID not stable across whitespace: %q vs %q", again, got)
+	}
+	if other := hashMachineID("0" + raw[1:]); other == got {
+		t.Errorf("different IDs hashed to same value %q", got)
+	}
+	for _, empty := range []string{"", " \n"} {
+		if got := hashMachineID(empty); got != "" {
+			t.Errorf("hashMachineID(%q) = %q; want empty", empty, got)
+		}
+	}
+}
+
+func TestBootTime(t *testing.T) {
+	bt := bootTime()
+	if bt.IsZero() {
+		if getBootTime != nil {
+			t.Log("boot time not available")
+		}
+		if up := uptime(); up != 0 {
+			t.Errorf("uptime = %v with unknown boot time; want 0", up)
+		}
+		return
+	}
+	up := uptime()
+	if !bt.Before(time.Now()) {
+		t.Errorf("boot time %v is not in the past", bt)
+	}
+	if up <= 0 {
+		t.Errorf("uptime = %v; want positive", up)
+	}
+	// The boot time is recomputed for each call on some platforms
+	// (from a coarse uptime counter on Linux), so allow some slop
+	// either way.
+	if d := time.Since(bt) - up; d < -time.Second || d > time.Second {
+		t.Errorf("uptime %v inconsistent with boot time %v (off by %v)", up, bt, d)
+	}
+	t.Logf("booted %v, up %v", bt, up)
+}
+
+func TestTimeZone(t *testing.T) {
+	oldReadlink, oldOSTimeZone := localtimeReadlink, osTimeZone
+	defer func() { localtimeReadlink, osTimeZone = oldReadlink, oldOSTimeZone }()
+
+	link := ""
+	localtimeReadlink = func(string) (string, error) {
+		if link == "" {
+			return "", errors.New("not a symlink")
+		}
+		return link, nil
+	}
+	osTimeZone = nil
+
+	tests := []struct {
+		name   string
+		env    string
+		osZone string
+		link   string
+		want   string
+	}{
+		{name: "linux_link", link: "/usr/share/zoneinfo/Europe/Paris", want: "Europe/Paris"},
+		{name: "relative_link", link: "../usr/share/zoneinfo/UTC", want: "UTC"},
+		{name: "macos_link", link: "/var/db/timezone/zoneinfo/Asia/Tokyo", want: "Asia/Tokyo"},
+		{name: "odd_link", link: "/etc/mytime", want: ""},
+		{name: "no_link", want: ""},
+		{name: "env", env: ":America/Toronto", link: "/usr/share/zoneinfo/UTC", want: "America/Toronto"},
+		{name: "os", osZone: "Pacific Standard Time", link: "/usr/share/zoneinfo/UTC", want: "Pacific Standard Time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TZ", tt.env)
+			link = tt.link
+			osTimeZone = nil
+			if tt.osZone != "" {
+				osTimeZone = func() string { return tt.osZone }
+			}
+			if got := timeZone(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultMTU(t *testing.T) {
+	oldIfs, oldRoute := netInterfaces, defaultRoute
+	defer func() { netInterfaces, defaultRoute = oldIfs, oldRoute }()
+
+	ifs := func() ([]net.Interface, error) {
+		return []net.Interface{
+			{Index: 1, Name: "lo", MTU: 65536},
+			{Index: 2, Name: "eth0", MTU: 1500},
+			{Index: 3, Name: "wg0", MTU: 1420},
+		}, nil
+	}
+	tests := []struct {
+		name  string
+		ifs   func() ([]net.Interface, error)
+		route func() (string, error)
+		want  int
+	}{
+		{"eth0", ifs, func() (string, error) { return "eth0", nil }, 1500},
+		{"wg0", ifs, func() (string, error) { return "wg0", nil }, 1420},
+		{"no_default_route", ifs, func() (string, error) { return "", nil }, 0},
+		{"route_error", ifs, func() (string, error) { return "", errors.New("boom") }, 0},
+		{"no_route_source", ifs, nil, 0},
+		{"unknown_iface", ifs, func() (string, error) { return "ppp0", nil }, 0},
+		{"interfaces_error", func() ([]net.Interface, error) { return nil, errors.New("boom") }, func() (string, error) { return "eth0", nil }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netInterfaces, defaultRoute = tt.ifs, tt.route
+			if got := defaultMTU(); got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDNSNames(t *testing.T) {
+	oldHostname, oldResolv, oldHosts, oldOS := osHostname, resolvConfRead, etcHostsRead, osDNSDomains
+	defer func() {
+		osHostname, resolvConfRead, etcHostsRead, osDNSDomains = oldHostname, oldResolv, oldHosts, oldOS
+	}()
+	osDNSDomains = nil
+
+	tests := []struct {
+		name     string
+		hostname string
+		resolv   string
+		hosts    string
+		want     dnsNames
+	}{
+		{
+			name:     "already_fqdn",
+			hostname: "web1.corp.example.com",
+			resolv:   "search corp.example.com example.com\nnameserver 10.0.0.1\n",
+			want:     dnsNames{FQDN: "web1.corp.example.com", SearchDomains: []string{"corp.example.com", "example.com"}},
+		},
+		{
+			name:     "short_from_hosts",
+			hostname: "web1",
+			resolv:   "search example.net\n",
+			hosts:    "127.0.0.1 localhost\n127.0.1.1 web1.corp.example.com web1 # set by installer\n",
+			want:     dnsNames{FQDN: "web1.corp.example.com", SearchDomains: []string{"example.net"}},
+		},
+		{
+			name:     "short_from_domain",
+			hostname: "web1",
+			resolv:   "domain corp.example.com.\nnameserver 10.0.0.1\n",
+			hosts:    "127.0.0.1 localhost\n",
+			want:     dnsNames{FQDN: "web1.corp.example.com", SearchDomains: []string{"corp.example.com"}},
+		},
+		{
+			name:     "short_from_first_search",
+			hostname: "web1",
+			resolv:   "domain old.example\nsearch lan home.arpa\n",
+			want:     dnsNames{FQDN: "web1.lan", SearchDomains: []string{"lan", "home.arpa"}},
+		},
+		{
+			name:     "short_unknown",
+			hostname: "web1",
+			resolv:   "nameserver 1.1.1.1\n",
+			want:     dnsNames{FQDN: "web1"},
+		},
+		{
+			name:     "hosts_other_host",
+			hostname: "web1",
+			hosts:    "10.0.0.2 web2.corp.example.com web2 web1-old\n",
+			want:     dnsNames{FQDN: "web1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			osHostname = func() (string, error) { return tt.hostname, nil }
+			resolvConfRead = func(string) ([]byte, error) {
+				if tt.resolv == "" {
+					return nil, os.ErrNotExist
+				}
+				return []byte(tt.resolv), nil
+			}
+			etcHostsRead = func(string) ([]byte, error) {
+				if tt.hosts == "" {
+					return nil, os.ErrNotExist
+				}
+				return []byte(tt.hosts), nil
+			}
+			if got := getDNSNames(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("os_override", func(t *testing.T) {
+		osHostname = func() (string, error) { return "DESKTOP-1", nil }
+		resolvConfRead = func(string) ([]byte, error) { return []byte("search ignored.example\n"), nil }
+		etcHostsRead = func(string) ([]byte, error) { return nil, os.ErrNotExist }
+		osDNSDomains = func() (string, []string, bool) {
+			return "ad.example.com", []string{"ad.example.com", "example.com"}, true
+		}
+		want := dnsNames{FQDN: "DESKTOP-1.ad.example.com", SearchDomains: []string{"ad.example.com", "example.com"}}
+		if got := getDNSNames(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v; want %+v", got, want)
+		}
+	})
+}
+
+func TestSanitizedHostname(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want hostnameInfo
+	}{
+		{"myhost", hostnameInfo{"myhost", "myhost", false}},
+		{"", hostnameInfo{"", "", false}},
+		{"DESKTOP-AB12", hostnameInfo{"DESKTOP-AB12", "desktop-ab12", true}},
+		{"host.example.com", hostnameInfo{"host.example.com", "host", true}},
+		{"Brads MacBook Pro.local", hostnameInfo{"Brads MacBook Pro.local", "brads-macbook-pro", true}},
+		{"  spaced out  ", hostnameInfo{"  spaced out  ", "spaced-out", true}},
+		{"café-ü", hostnameInfo{"café-ü", "caf", true}},
+		{"日本語", hostnameInfo{"日本語", "", true}},
+		{"under_score", hostnameInfo{"under_score", "under-score", true}},
+		{strings.Repeat("a", 70), hostnameInfo{strings.Repeat("a", 70), strings.Repeat("a", 63), true}},
+	}
+	for _, tt := range tests {
+		if got := sanitizeHostname(tt.raw); got != tt.want {
+			t.Errorf("sanitizeHostname(%q) = %+v; want %+v", tt.raw, got, tt.want)
+		}
+	}
+
+	old := osHostname
+	defer func() { osHostname = old }()
+	osHostname = func() (string, error) { return "Work Laptop", nil }
+	want := hostnameInfo{"Work Laptop", "work-laptop", true}
+	if got := sanitizedHostname(); got != want {
+		t.Errorf("sanitizedHostname() = %+v; want %+v", got, want)
+	}
+}
+
+func TestNetSummary(t *testing.T) {
+	oldIfs, oldRoute := netInterfaces, defaultRoute
+	defer func() { netInterfaces, defaultRoute = oldIfs, oldRoute }()
+
+	twoIfs := func() ([]net.Interface, error) {
+		return []net.Interface{{Index: 1, Name: "lo"}, {Index: 2, Name: "eth0"}}, nil
+	}
+	tests := []struct {
+		name  string
+		ifs   func() ([]net.Interface, error)
+		route func() (string, error)
+		want  networkSummary
+	}{
+		{
+			name:  "default_route",
+			ifs:   twoIfs,
+			route: func() (string, error) { return "eth0", nil },
+			want:  networkSummary{InterfaceCount: 2, HasDefaultRoute: "true", DefaultIface: "eth0"},
+		},
+		{
+			name:  "no_default_route",
+			ifs:   twoIfs,
+			route: func() (string, error) { return "", nil },
+			want:  networkSummary{InterfaceCount: 2, HasDefaultRoute: "false"},
+		},
+		{
+			name:  "route_error",
+			ifs:   twoIfs,
+			route: func() (string, error) { return "", errors.New("permission denied") },
+			want:  networkSummary{InterfaceCount: 2},
+		},
+		{
+			name: "no_route_source",
+			ifs:  twoIfs,
+			want: networkSummary{InterfaceCount: 2},
+		},
+		{
+			name:  "interfaces_error",
+			ifs:   func() ([]net.Interface, error) { return nil, errors.New("no netlink") },
+			route: func() (string, error) { return "wlan0", nil },
+			want:  networkSummary{HasDefaultRoute: "true", DefaultIface: "wlan0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netInterfaces, defaultRoute = tt.ifs, tt.route
+			if got := netSummary(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		in   string
+		want localeInfo
+	}{
+		{"en_US.UTF-8", localeInfo{"en", "US", "UTF-8"}},
+		{"en_GB.utf8", localeInfo{"en", "GB", "UTF-8"}},
+		{"de_DE@euro", localeInfo{"de", "DE", ""}},
+		{"de_DE.ISO-8859-15@euro", localeInfo{"de", "DE", "ISO-8859-15"}},
+		{"fr", localeInfo{"fr", "", ""}},
+		{"pt-BR", localeInfo{"pt", "BR", ""}},
+		{"zh-Hans-CN", localeInfo{"zh", "CN", ""}},
+		{"es-419", localeInfo{"es", "419", ""}},
+		{"-", localeInfo{}},
+		{"C", localeInfo{}},
+		{"C.UTF-8", localeInfo{}},
+		{"POSIX", localeInfo{}},
+		{"", localeInfo{}},
+	}
+	for _, tt := range tests {
+		if got := parseLocale(tt.in); got != tt.want {
+			t.Errorf("parseLocale(%q) = %+v; want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	old := osLocale
+	defer func() { osLocale = old }()
+	osLocale = func() localeInfo { return localeInfo{"nl", "NL", "UTF-8"} }
+
+	tests := []struct {
+		name        string
+		lcAll, lang string
+		want        localeInfo
+	}{
+		{"lc_all_wins", "fr_CA.UTF-8", "en_US.UTF-8", localeInfo{"fr", "CA", "UTF-8"}},
+		{"lang", "", "en_US.UTF-8", localeInfo{"en", "US", "UTF-8"}},
+		{"posix_env", "", "C", localeInfo{}},
+		{"os_fallback", "", "", localeInfo{"nl", "NL", "UTF-8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LC_ALL", tt.lcAll)
+			t.Setenv("LANG", tt.lang)
+			if got := locale(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrimaryIP(t *testing.T) {
+	oldIfs, oldRoute, oldAddrs := netInterfaces, defaultRoute, interfaceAddrs
+	defer func() { netInterfaces, defaultRoute, interfaceAddrs = oldIfs, oldRoute, oldAddrs }()
+	netInterfaces = func() ([]net.Interface, error) {
+		return []net.Interface{{Index: 1, Name: "lo"}, {Index: 2, Name: "eth0"}}, nil
+	}
+
+	cidrs := func(ss ...string) []net.Addr {
+		var ret []net.Addr
+		for _, s := range ss {
+			ip, ipn, err := net.ParseCIDR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ipn.IP = ip
+			ret = append(ret, ipn)
+		}
+		return ret
+	}
+	tests := []struct {
+		name  string
+		route string
+		addrs []net.Addr
+		want  primaryAddr
+	}{
+		{
+			name:  "rfc1918",
+			route: "eth0",
+			addrs: cidrs("fe80::1/64", "192.168.1.23/24"),
+			want:  primaryAddr{Addr: netip.MustParseAddr("192.168.1.23"), Private: "true", CGNAT: "false"},
+		},
+		{
+			name:  "public",
+			route: "eth0",
+			addrs: cidrs("203.0.113.7/24"),
+			want:  primaryAddr{Addr: netip.MustParseAddr("203.0.113.7"), Private: "false", CGNAT: "false"},
+		},
+		{
+			name:  "cgnat",
+			route: "eth0",
+			addrs: cidrs("100.72.1.2/10"),
+			want:  primaryAddr{Addr: netip.MustParseAddr("100.72.1.2"), Private: "false", CGNAT: "true"},
+		},
+		{
+			name:  "prefers_ipv4",
+			route: "eth0",
+			addrs: cidrs("2001:db8::5/64", "10.0.0.5/8"),
+			want:  primaryAddr{Addr: netip.MustParseAddr("10.0.0.5"), Private: "true", CGNAT: "false"},
+		},
+		{
+			name:  "ula_only",
+			route: "eth0",
+			addrs: cidrs("fe80::1/64", "fd7a:115c:a1e0::1/48"),
+			want:  primaryAddr{Addr: netip.MustParseAddr("fd7a:115c:a1e0::1"), Private: "true", CGNAT: "false"},
+		},
+		{
+			name:  "link_local_only",
+			route: "eth0",
+			addrs: cidrs("fe80::1/64", "169.254.3.4/16"),
+		},
+		{name: "no_default_route", route: ""},
+		{name: "unknown_iface", route: "ppp0", addrs: cidrs("10.0.0.5/8")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultRoute = func() (string, error) { return tt.route, nil }
+			interfaceAddrs = func(ifc *net.Interface) ([]net.Addr, error) {
+				if ifc.Name != tt.route {
+					t.Errorf("addrs of %q; want %q", ifc.Name, tt.route)
+				}
+				return tt.addrs, nil
+			}
+			if got := primaryIP(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExistingVPNInterfaces(t *testing.T) {
+	oldIfs, oldAddrs := netInterfaces, interfaceAddrs
+	defer func() { netInterfaces, interfaceAddrs = oldIfs, oldAddrs }()
+
+	up := net.FlagUp | net.FlagMulticast
+	p2p := net.FlagUp | net.FlagPointToPoint
+	netInterfaces = func() ([]net.Interface, error) {
+		return []net.Interface{
+			{Index: 1, Name: "lo0", Flags: net.FlagUp | net.FlagLoopback},
+			{Index: 2, Name: "en0", Flags: up},
+			{Index: 3, Name: "utun0", Flags: p2p},
+			{Index: 4, Name: "utun3", Flags: p2p}, // Tailscale on macOS
+			{Index: 5, Name: "tailscale0", Flags: p2p},
+			{Index: 6, Name: "tun0", Flags: p2p},
+			{Index: 7, Name: "tap1", Flags: up},
+			{Index: 8, Name: "ppp0", Flags: p2p},
+			{Index: 9, Name: "wg-work", Flags: p2p},
+			{Index: 10, Name: "gpd0", Flags: p2p},                  // unknown name, but point-to-point
+			{Index: 11, Name: "tun9", Flags: net.FlagPointToPoint}, // down
+			{Index: 12, Name: "docker0", Flags: up},
+		}, nil
+	}
+	interfaceAddrs = func(ifc *net.Interface) ([]net.Addr, error) {
+		if ifc.Name == "utun3" {
+			_, ipn, _ := net.ParseCIDR("fd7a:115c:a1e0::1/128")
+			return []net.Addr{ipn}, nil
+		}
+		return nil, nil
+	}
+	want := []string{"utun0", "tun0", "tap1", "ppp0", "wg-work", "gpd0"}
+	if got := existingVPNInterfaces(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	netInterfaces = func() ([]net.Interface, error) { return nil, errors.New("boom") }
+	if got := existingVPNInterfaces(); got != nil {
+		t.Errorf("on error, got %q; want nil", got)
+	}
+}
+
+func TestSystemProxy(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		os   *proxyInfo // or nil for no OS proxy settings
+		want proxyInfo
+	}{
+		{name: "none"},
+		{
+			name: "upper",
+			env:  map[string]string{"HTTP_PROXY": "http://p:3128", "HTTPS_PROXY": "http://p:3129", "NO_PROXY": "localhost,.corp"},
+			want: proxyInfo{HTTPProxy: "http://p:3128", HTTPSProxy: "http://p:3129", NoProxy: "localhost,.corp"},
+		},
+		{
+			name: "lower",
+			env:  map[string]string{"https_proxy": "http://p:8080", "no_proxy": "*"},
+			want: proxyInfo{HTTPSProxy: "http://p:8080", NoProxy: "*"},
+		},
+		{
+			name: "upper_wins",
+			env:  map[string]string{"HTTP_PROXY": "http://upper:1", "http_proxy": "http://lower:1"},
+			want: proxyInfo{HTTPProxy: "http://upper:1"},
+		},
+		{
+			name: "os",
+			os:   &proxyInfo{HTTPProxy: "sys:80", HTTPSProxy: "sys:80"},
+			want: proxyInfo{HTTPProxy: "sys:80", HTTPSProxy: "sys:80"},
+		},
+		{
+			name: "env_over_os",
+			env:  map[string]string{"HTTPS_PROXY": "http://env:1"},
+			os:   &proxyInfo{HTTPProxy: "sys:80"},
+			want: proxyInfo{HTTPSProxy: "http://env:1"},
+		},
+	}
+	oldGetenv, oldProxy := getenv, osProxy
+	defer func() { getenv, osProxy = oldGetenv, oldProxy }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv = func(name string) string { return tt.env[name] }
+			osProxy = nil
+			if tt.os != nil {
+				osProxy = func() proxyInfo { return *tt.os }
+			}
+			if got := systemProxy(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSServers(t *testing.T) {
+	const resolvConf = `# Generated by NetworkManager
+search corp.example.com
+nameserver 192.168.1.1
+nameserver fe80::1%eth0
+nameserver not-an-ip
+nameserver
+options edns0
+nameserver 2001:db8::53
+`
+	tests := []struct {
+		name string
+		conf string // or "" for none
+		os   []netip.Addr
+		osOK bool
+		want []netip.Addr
+	}{
+		{
+			name: "resolv_conf",
+			conf: resolvConf,
+			want: []netip.Addr{
+				netip.MustParseAddr("192.168.1.1"),
+				netip.MustParseAddr("fe80::1%eth0"),
+				netip.MustParseAddr("2001:db8::53"),
+			},
+		},
+		{
+			name: "os_settings",
+			conf: resolvConf,
+			os:   []netip.Addr{netip.MustParseAddr("10.0.0.2")},
+			osOK: true,
+			want: []netip.Addr{netip.MustParseAddr("10.0.0.2")},
+		},
+		{
+			name: "os_settings_none",
+			conf: resolvConf,
+			osOK: true,
+			want: nil,
+		},
+		{
+			name: "no_nameservers",
+			conf: "search example.com\n",
+			want: nil,
+		},
+		{
+			name: "no_resolv_conf",
+			want: nil,
+		},
+	}
+	oldRead, oldOS := resolvConfRead, osDNSServers
+	defer func() { resolvConfRead, osDNSServers = oldRead, oldOS }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvConfRead = func(string) ([]byte, error) {
+				if tt.conf == "" {
+					return nil, os.ErrNotExist
+				}
+				return []byte(tt.conf), nil
+			}
+			osDNSServers = func() ([]netip.Addr, bool) { return tt.os, tt.osOK }
+			if got := dnsServers(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
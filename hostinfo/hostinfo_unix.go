@@ -0,0 +1,47 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd || openbsd || netbsd
+// +build linux darwin freebsd openbsd netbsd
+
+package hostinfo
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+	"tailscale.com/types/opt"
+)
+
+func init() {
+	fdLimits = fdLimitUnix
+	elevated = elevatedUnix
+}
+
+// getrlimit is unix.Getrlimit, but can be replaced by tests.
+var getrlimit = unix.Getrlimit
+
+// fdLimitUnix returns RLIMIT_NOFILE. An unlimited limit is reported as
+// the OS's RLIM_INFINITY. A soft limit above the hard one can't be in
+// effect, so it's reported as the hard limit.
+func fdLimitUnix() fdLimitInfo {
+	var rl unix.Rlimit
+	if err := getrlimit(unix.RLIMIT_NOFILE, &rl); err != nil {
+		return fdLimitInfo{}
+	}
+	ret := fdLimitInfo{Soft: uint64(rl.Cur), Hard: uint64(rl.Max)}
+	if ret.Soft > ret.Hard {
+		ret.Soft = ret.Hard
+	}
+	return ret
+}
+
+// geteuid is os.Geteuid, but can be replaced by tests.
+var geteuid = os.Geteuid
+
+// elevatedUnix reports whether the effective user is root.
+func elevatedUnix() (ret opt.Bool) {
+	ret.Set(geteuid() == 0)
+	return ret
+}
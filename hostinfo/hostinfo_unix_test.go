@@ -0,0 +1,89 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd || openbsd || netbsd
+// +build linux darwin freebsd openbsd netbsd
+
+package hostinfo
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"tailscale.com/types/opt"
+)
+
+func TestFDLimitUnix(t *testing.T) {
+	tests := []struct {
+		name     string
+		cur, max uint64
+		err      error
+		want     fdLimitInfo
+	}{
+		{"typical", 1024, 524288, nil, fdLimitInfo{Soft: 1024, Hard: 524288}},
+		{"raised", 65536, 65536, nil, fdLimitInfo{Soft: 65536, Hard: 65536}},
+		{"unlimited_hard", 256, math.MaxInt64, nil, fdLimitInfo{Soft: 256, Hard: math.MaxInt64}},
+		{"soft_above_hard", 4096, 1024, nil, fdLimitInfo{Soft: 1024, Hard: 1024}},
+		{"error", 0, 0, errors.New("EPERM"), fdLimitInfo{}},
+	}
+	old := getrlimit
+	defer func() { getrlimit = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getrlimit = func(resource int, rl *unix.Rlimit) error {
+				if resource != unix.RLIMIT_NOFILE {
+					t.Errorf("resource = %d; want RLIMIT_NOFILE", resource)
+				}
+				setRlimit(rl, tt.cur, tt.max)
+				return tt.err
+			}
+			if got := fdLimitUnix(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The real one works, with soft <= hard.
+	getrlimit = old
+	if got := fdLimitUnix(); got.Soft == 0 || got.Soft > got.Hard {
+		t.Errorf("real limit = %+v", got)
+	}
+}
+
+// setRlimit sets rl's fields, which are int64 on FreeBSD and uint64
+// elsewhere.
+func setRlimit(rl *unix.Rlimit, cur, max uint64) {
+	v := reflect.ValueOf(rl).Elem()
+	for _, f := range []struct {
+		name string
+		val  uint64
+	}{{"Cur", cur}, {"Max", max}} {
+		if fv := v.FieldByName(f.name); fv.Kind() == reflect.Int64 {
+			fv.SetInt(int64(f.val))
+		} else {
+			fv.SetUint(f.val)
+		}
+	}
+}
+
+func TestElevatedUnix(t *testing.T) {
+	old := geteuid
+	defer func() { geteuid = old }()
+	for _, tt := range []struct {
+		euid int
+		want opt.Bool
+	}{
+		{0, "true"},
+		{1000, "false"},
+		{65534, "false"},
+	} {
+		geteuid = func() int { return tt.euid }
+		if got := elevatedUnix(); got != tt.want {
+			t.Errorf("euid %d: got %q; want %q", tt.euid, got, tt.want)
+		}
+	}
+}
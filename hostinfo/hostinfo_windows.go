@@ -5,19 +5,50 @@
 package hostinfo
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
 
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
 	"tailscale.com/syncs"
+	"tailscale.com/types/opt"
 	"tailscale.com/util/winutil"
 )
 
 func init() {
 	osVersion = osVersionWindows
 	packageType = packageTypeWindows
+	serviceStatus = serviceStatusWindows
+	rawMachineID = machineIDWindows
+	batteryStatus = batteryStatusWindows
+	fsTypeOf = fsTypeWindows
+	ipv6Status = ipv6StatusWindows
+	getBootTime = bootTimeWindows
+	osTimeZone = timeZoneWindows
+	listGPUs = gpusWindows
+	osDNSDomains = dnsDomainsWindows
+	diskSpace = diskSpaceWindows
+	debugged = debuggedWindows
+	tpmStatus = tpmWindows
+	osLocale = localeWindows
+	osFirmware = firmwareModeWindows
+	secureBootOn = secureBootWindows
+	cpuSpeed = cpuMHzWindows
+	osFormFactor = formFactorWindows
+	osDMI = dmiWindows
+	resumeTime = resumeTimeWindows
+	fdLimits = fdLimitWindows
+	elevated = elevatedWindows
+	osProxy = proxyWindows
+	osDNSServers = dnsServersWindows
 }
 
 var winVerCache syncs.AtomicValue[string]
@@ -82,3 +113,959 @@ func packageTypeWindows() string {
 	// source tailscaled or a developer running by hand.
 	return ""
 }
+
+// rebootSignal is a registry location whose presence indicates that
+// Windows has a reboot pending.
+type rebootSignal struct {
+	key   string // under HKEY_LOCAL_MACHINE
+	value string // if non-empty, a REG_MULTI_SZ value under key that must be non-empty
+}
+
+var rebootSignals = []rebootSignal{
+	{key: `SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending`},
+	{key: `SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired`},
+	{key: `SYSTEM\CurrentControlSet\Control\Session Manager`, value: "PendingFileRenameOperations"},
+}
+
+// probeRebootSignal reports whether sig is present in the registry.
+// It's a variable so tests can replace it.
+var probeRebootSignal = func(sig rebootSignal) (bool, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, sig.key, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if errors.Is(err, registry.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer key.Close()
+	if sig.value == "" {
+		return true, nil
+	}
+	vals, _, err := key.GetStringsValue(sig.value)
+	if errors.Is(err, registry.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(vals) > 0, nil
+}
+
+// pendingReboot reports whether Windows is waiting for a reboot to
+// finish installing updates or drivers, which can leave the TUN adapter
+// in a half-configured state.
+//
+// It reports true if any known signal is present, false if every signal
+// was checked and found absent, and unset if the registry couldn't be
+// read well enough to say.
+func pendingReboot() (ret opt.Bool) {
+	unknown := false
+	for _, sig := range rebootSignals {
+		present, err := probeRebootSignal(sig)
+		if err != nil {
+			unknown = true
+			continue
+		}
+		if present {
+			ret.Set(true)
+			return ret
+		}
+	}
+	if !unknown {
+		ret.Set(false)
+	}
+	return ret
+}
+
+func serviceStatusWindows() opt.Bool {
+	var session uint32
+	sessErr := windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &session)
+	isSvc, tokErr := tokenHasServiceSID()
+	if sessErr != nil || tokErr != nil {
+		return ""
+	}
+	return windowsServiceStatus(session, isSvc)
+}
+
+// tokenHasServiceSID reports whether the current process token is a
+// member of the well-known SERVICE group (S-1-5-6), which the SCM adds
+// to the tokens of all services it starts.
+func tokenHasServiceSID() (bool, error) {
+	sid, err := windows.CreateWellKnownSid(windows.WinServiceSid)
+	if err != nil {
+		return false, err
+	}
+	return windows.Token(0).IsMember(sid)
+}
+
+// windowsServiceStatus reports whether a process in the given session,
+// whose token is or isn't in the SERVICE group, is running as a
+// service.
+//
+// Services run in session 0 and interactive logons never do. A
+// session 0 process without the SERVICE group (e.g. started by psexec
+// or a scheduled task) is ambiguous.
+func windowsServiceStatus(session uint32, serviceSID bool) (ret opt.Bool) {
+	switch {
+	case session != 0:
+		ret.Set(false)
+	case serviceSID:
+		ret.Set(true)
+	}
+	return ret
+}
+
+// readMachineGuid returns the MachineGuid that Windows generates at
+// install time. It's a variable so tests can replace it.
+var readMachineGuid = func() (string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", err
+	}
+	defer key.Close()
+	v, _, err := key.GetStringValue("MachineGuid")
+	return v, err
+}
+
+func machineIDWindows() string {
+	v, err := readMachineGuid()
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+var (
+	kernel32                 = windows.NewLazySystemDLL("kernel32.dll")
+	procGetSystemPowerStatus = kernel32.NewProc("GetSystemPowerStatus")
+	procGetTickCount64       = kernel32.NewProc("GetTickCount64")
+
+	procIsDebuggerPresent          = kernel32.NewProc("IsDebuggerPresent")
+	procCheckRemoteDebuggerPresent = kernel32.NewProc("CheckRemoteDebuggerPresent")
+
+	procGetUserDefaultLocaleName = kernel32.NewProc("GetUserDefaultLocaleName")
+	procGetFirmwareType          = kernel32.NewProc("GetFirmwareType")
+	procGetSystemFirmwareTable   = kernel32.NewProc("GetSystemFirmwareTable")
+)
+
+// systemPowerStatus is the Win32 SYSTEM_POWER_STATUS struct.
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+const (
+	batteryFlagNoBattery = 128
+	batteryFlagUnknown   = 255
+)
+
+// getSystemPowerStatus calls GetSystemPowerStatus. It's a variable so
+// tests can replace it.
+var getSystemPowerStatus = func() (systemPowerStatus, error) {
+	var st systemPowerStatus
+	if r, _, err := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&st))); r == 0 {
+		return st, err
+	}
+	return st, nil
+}
+
+func batteryStatusWindows() (ret opt.Bool) {
+	st, err := getSystemPowerStatus()
+	if err != nil {
+		return ""
+	}
+	switch st.BatteryFlag {
+	case batteryFlagUnknown:
+		return ""
+	case batteryFlagNoBattery:
+		ret.Set(false)
+	default:
+		ret.Set(true)
+	}
+	return ret
+}
+
+// fsTypeWindows returns the lowercased file system name ("ntfs",
+// "refs", "fat32") of the volume containing path.
+func fsTypeWindows(path string) string {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return ""
+	}
+	root := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &root[0], uint32(len(root))); err != nil {
+		return ""
+	}
+	name := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumeInformation(&root[0], nil, 0, nil, nil, nil, &name[0], uint32(len(name))); err != nil {
+		return ""
+	}
+	return strings.ToLower(windows.UTF16ToString(name))
+}
+
+// readIPv6DisabledComponents returns the Tcpip6 DisabledComponents
+// registry value. It's a variable so tests can replace it.
+var readIPv6DisabledComponents = func() (uint64, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\Tcpip6\Parameters`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return 0, err
+	}
+	defer key.Close()
+	v, _, err := key.GetIntegerValue("DisabledComponents")
+	return v, err
+}
+
+// ipv6DisableAll is the DisabledComponents value that disables IPv6 on
+// all interfaces other than loopback.
+//
+// See https://learn.microsoft.com/en-us/troubleshoot/windows-server/networking/configure-ipv6-in-windows
+const ipv6DisableAll = 0xff
+
+// ipv6StatusWindows reports whether IPv6 is enabled. It's enabled by
+// default, unless disabled via the DisabledComponents registry value.
+func ipv6StatusWindows() (ret opt.Bool) {
+	v, err := readIPv6DisabledComponents()
+	switch {
+	case errors.Is(err, registry.ErrNotExist):
+		ret.Set(true)
+	case err == nil:
+		ret.Set(v&ipv6DisableAll != ipv6DisableAll)
+	}
+	return ret
+}
+
+// tickCount64 returns the number of milliseconds since boot, including
+// time spent asleep. It's a variable so tests can replace it.
+var tickCount64 = func() (uint64, error) {
+	if err := procGetTickCount64.Find(); err != nil {
+		return 0, err
+	}
+	r1, r2, _ := procGetTickCount64.Call()
+	ms := uint64(r1)
+	if unsafe.Sizeof(r1) == 4 {
+		// On 386 the high 32 bits come back in EDX.
+		ms |= uint64(r2) << 32
+	}
+	return ms, nil
+}
+
+func bootTimeWindows() time.Time {
+	ms, err := tickCount64()
+	if err != nil || ms == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-time.Duration(ms) * time.Millisecond)
+}
+
+// timeZoneWindows returns the Windows name of the local time zone, such
+// as "Pacific Standard Time". Windows has no IANA names of its own.
+func timeZoneWindows() string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control\TimeZoneInformation`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	v, _, err := key.GetStringValue("TimeZoneKeyName")
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// displayAdapterClassKey is the registry key of the display adapter
+// device setup class. Each adapter has a numbered subkey ("0000").
+const displayAdapterClassKey = `SYSTEM\CurrentControlSet\Control\Class\{4d36e968-e325-11ce-bfc1-08002be10318}`
+
+// readDisplayAdapters returns the DriverDesc of each display adapter in
+// the registry. It's a variable so tests can replace it.
+var readDisplayAdapters = func() ([]string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, displayAdapterClassKey, registry.ENUMERATE_SUB_KEYS|registry.WOW64_64KEY)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	subkeys, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, name := range subkeys {
+		sk, err := registry.OpenKey(key, name, registry.QUERY_VALUE)
+		if err != nil {
+			continue // "Properties" and friends aren't readable
+		}
+		desc, _, err := sk.GetStringValue("DriverDesc")
+		sk.Close()
+		if err == nil {
+			ret = append(ret, desc)
+		}
+	}
+	return ret, nil
+}
+
+// gpusWindows returns the display adapters known to Windows, such as
+// "NVIDIA GeForce RTX 3080", without duplicates.
+func gpusWindows() []string {
+	descs, err := readDisplayAdapters()
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	seen := map[string]bool{}
+	for _, d := range descs {
+		d = strings.TrimSpace(d)
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// readTcpipParams returns the named string values of the Tcpip
+// service's parameters, omitting those that are missing. It's a
+// variable so tests can replace it.
+var readTcpipParams = func(names ...string) (map[string]string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\Tcpip\Parameters`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	ret := map[string]string{}
+	for _, name := range names {
+		if v, _, err := key.GetStringValue(name); err == nil {
+			ret[name] = v
+		}
+	}
+	return ret, nil
+}
+
+// dnsDomainsWindows returns the machine's primary DNS suffix and its
+// DNS suffix search list. Without an explicit search list, Windows
+// searches the primary suffix.
+func dnsDomainsWindows() (domain string, search []string, ok bool) {
+	params, err := readTcpipParams("Domain", "NV Domain", "SearchList")
+	if err != nil {
+		return "", nil, false
+	}
+	domain = params["Domain"]
+	if domain == "" {
+		domain = params["NV Domain"]
+	}
+	for _, d := range strings.Split(params["SearchList"], ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			search = append(search, d)
+		}
+	}
+	if len(search) == 0 && domain != "" {
+		search = []string{domain}
+	}
+	return domain, search, true
+}
+
+// getDiskFreeSpaceEx is windows.GetDiskFreeSpaceEx, but can be
+// replaced by tests.
+var getDiskFreeSpaceEx = windows.GetDiskFreeSpaceEx
+
+// diskSpaceWindows returns the free space available to the calling
+// user, which respects disk quotas, and the total size of the volume
+// containing path.
+func diskSpaceWindows(path string) (free, total uint64) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0
+	}
+	var totalFree uint64
+	if err := getDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return 0, 0
+	}
+	return free, total
+}
+
+// debuggerPresent reports whether a local debugger (IsDebuggerPresent)
+// or a debugger in another process (CheckRemoteDebuggerPresent) is
+// attached. It's a variable so tests can replace it.
+var debuggerPresent = func() (local, remote bool, err error) {
+	if err := procIsDebuggerPresent.Find(); err != nil {
+		return false, false, err
+	}
+	l, _, _ := procIsDebuggerPresent.Call()
+	var present int32 // BOOL
+	if r, _, err := procCheckRemoteDebuggerPresent.Call(uintptr(windows.CurrentProcess()), uintptr(unsafe.Pointer(&present))); r == 0 {
+		return false, false, err
+	}
+	return l != 0, present != 0, nil
+}
+
+func debuggedWindows() (ret opt.Bool) {
+	local, remote, err := debuggerPresent()
+	if err != nil {
+		return ""
+	}
+	ret.Set(local || remote)
+	return ret
+}
+
+var procTbsiGetDeviceInfo = windows.NewLazySystemDLL("tbs.dll").NewProc("Tbsi_GetDeviceInfo")
+
+// tpmDeviceInfo is the TBS TPM_DEVICE_INFO struct.
+type tpmDeviceInfo struct {
+	StructVersion    uint32
+	TPMVersion       uint32 // 1 for TPM 1.2, 2 for TPM 2.0
+	TPMInterfaceType uint32
+	TPMImpRevision   uint32
+}
+
+// tbsETPMNotFound is the TBS_RESULT for no TPM.
+const tbsETPMNotFound = 0x8028400F
+
+// getTPMDeviceInfo calls Tbsi_GetDeviceInfo, returning its TBS_RESULT.
+// The error is non-nil if the TBS API isn't available. It's a variable
+// so tests can replace it.
+var getTPMDeviceInfo = func() (info tpmDeviceInfo, result uint32, err error) {
+	if err := procTbsiGetDeviceInfo.Find(); err != nil {
+		return info, 0, err
+	}
+	r, _, _ := procTbsiGetDeviceInfo.Call(unsafe.Sizeof(info), uintptr(unsafe.Pointer(&info)))
+	return info, uint32(r), nil
+}
+
+func tpmWindows() (ret tpmInfo) {
+	info, result, err := getTPMDeviceInfo()
+	switch {
+	case err != nil:
+		return tpmInfo{}
+	case result == tbsETPMNotFound:
+		ret.Present.Set(false)
+		return ret
+	case result != 0:
+		return tpmInfo{}
+	}
+	ret.Present.Set(true)
+	switch info.TPMVersion {
+	case 1:
+		ret.Version = "1.2"
+	case 2:
+		ret.Version = "2.0"
+	}
+	return ret
+}
+
+// userLocale returns the user's default locale name, such as "en-US",
+// and the ANSI code page. It's a variable so tests can replace it.
+var userLocale = func() (name string, acp uint32, err error) {
+	const localeNameMaxLength = 85 // LOCALE_NAME_MAX_LENGTH
+	buf := make([]uint16, localeNameMaxLength)
+	if r, _, err := procGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))); r == 0 {
+		return "", 0, err
+	}
+	return windows.UTF16ToString(buf), windows.GetACP(), nil
+}
+
+// cpUTF8 is the UTF-8 code page, CP_UTF8.
+const cpUTF8 = 65001
+
+// localeWindows returns the user's default locale, with the code
+// page it uses for non-Unicode programs as the charset ("CP1252", or
+// "UTF-8" if the beta UTF-8 option is on).
+func localeWindows() localeInfo {
+	name, acp, err := userLocale()
+	if err != nil {
+		return localeInfo{}
+	}
+	li := parseLocale(name)
+	if li.Language == "" {
+		return localeInfo{}
+	}
+	switch acp {
+	case 0:
+	case cpUTF8:
+		li.Charset = "UTF-8"
+	default:
+		li.Charset = fmt.Sprintf("CP%d", acp)
+	}
+	return li
+}
+
+// FIRMWARE_TYPE values.
+const (
+	firmwareTypeBios = 1
+	firmwareTypeUefi = 2
+)
+
+// getFirmwareType returns the FIRMWARE_TYPE from GetFirmwareType. It's
+// a variable so tests can replace it.
+var getFirmwareType = func() (uint32, error) {
+	if err := procGetFirmwareType.Find(); err != nil {
+		return 0, err // before Windows 8
+	}
+	var ft uint32
+	if r, _, err := procGetFirmwareType.Call(uintptr(unsafe.Pointer(&ft))); r == 0 {
+		return 0, err
+	}
+	return ft, nil
+}
+
+func firmwareModeWindows() string {
+	ft, err := getFirmwareType()
+	if err != nil {
+		return ""
+	}
+	switch ft {
+	case firmwareTypeBios:
+		return "bios"
+	case firmwareTypeUefi:
+		return "uefi"
+	}
+	return ""
+}
+
+// secureBootEnabledValue returns the UEFISecureBootEnabled registry
+// value maintained by Windows. It's a variable so tests can replace
+// it.
+var secureBootEnabledValue = func() (uint64, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		`SYSTEM\CurrentControlSet\Control\SecureBoot\State`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return 0, err
+	}
+	defer key.Close()
+	val, _, err := key.GetIntegerValue("UEFISecureBootEnabled")
+	return val, err
+}
+
+func secureBootWindows() (ret opt.Bool) {
+	val, err := secureBootEnabledValue()
+	if err != nil {
+		return ""
+	}
+	ret.Set(val != 0)
+	return ret
+}
+
+// regKey is the subset of registry.Key used to probe for runtimes.
+type regKey interface {
+	GetIntegerValue(name string) (val uint64, valtype uint32, err error)
+	GetStringValue(name string) (val string, valtype uint32, err error)
+	ReadValueNames(n int) ([]string, error)
+	Close() error
+}
+
+// openRegKey opens the HKEY_LOCAL_MACHINE key at path for reading, in
+// the 32-bit registry view if wow32 is set and the 64-bit one
+// otherwise. It's a variable so tests can replace it.
+var openRegKey = func(path string, wow32 bool) (regKey, error) {
+	access := uint32(registry.QUERY_VALUE | registry.WOW64_64KEY)
+	if wow32 {
+		access = registry.QUERY_VALUE | registry.WOW64_32KEY
+	}
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, path, access)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// runtimeArches are the architectures for which the Visual C++ and
+// .NET installers register runtimes.
+var runtimeArches = []string{"x86", "x64", "arm64"}
+
+// installedRuntimes returns the Visual C++ redistributables and .NET
+// runtimes installed, as strings like "VC++ 14.32.31332 x64",
+// ".NET Framework 4.8.04084" or ".NET 6.0.10 x64", for installer
+// diagnostics. Runtimes whose registry keys are missing or unreadable
+// are skipped, so the result is empty if none are detected.
+func installedRuntimes() []string {
+	var ret []string
+	for _, arch := range runtimeArches {
+		// The x86 redistributable registers itself in the 32-bit view.
+		k, err := openRegKey(`SOFTWARE\Microsoft\VisualStudio\14.0\VC\Runtimes\`+arch, arch == "x86")
+		if err != nil {
+			continue
+		}
+		installed, _, err := k.GetIntegerValue("Installed")
+		ver, _, verErr := k.GetStringValue("Version") // "v14.32.31332.00"
+		k.Close()
+		if err == nil && verErr == nil && installed == 1 {
+			ver = strings.TrimSuffix(strings.TrimPrefix(ver, "v"), ".00")
+			ret = append(ret, fmt.Sprintf("VC++ %s %s", ver, arch))
+		}
+	}
+	if k, err := openRegKey(`SOFTWARE\Microsoft\NET Framework Setup\NDP\v4\Full`, false); err == nil {
+		if ver, _, err := k.GetStringValue("Version"); err == nil && ver != "" {
+			ret = append(ret, ".NET Framework "+ver)
+		}
+		k.Close()
+	}
+	for _, arch := range runtimeArches {
+		// The .NET (Core) installers all register in the 32-bit view,
+		// with one value named for each installed version.
+		k, err := openRegKey(`SOFTWARE\dotnet\Setup\InstalledVersions\`+arch+`\sharedfx\Microsoft.NETCore.App`, true)
+		if err != nil {
+			continue
+		}
+		vers, err := k.ReadValueNames(-1)
+		k.Close()
+		if err != nil {
+			continue
+		}
+		sort.Strings(vers)
+		for _, ver := range vers {
+			ret = append(ret, fmt.Sprintf(".NET %s %s", ver, arch))
+		}
+	}
+	return ret
+}
+
+// cpuMHzWindows returns the first processor's nominal speed as
+// recorded in the registry at boot. Windows doesn't publish the
+// current speed there.
+func cpuMHzWindows() cpuClock {
+	k, err := openRegKey(`HARDWARE\DESCRIPTION\System\CentralProcessor\0`, false)
+	if err != nil {
+		return cpuClock{}
+	}
+	defer k.Close()
+	mhz, _, err := k.GetIntegerValue("~MHz")
+	if err != nil {
+		return cpuClock{}
+	}
+	return cpuClock{Nominal: float64(mhz)}
+}
+
+// rsmbProvider is the 'RSMB' firmware table provider signature, for
+// the raw SMBIOS tables.
+const rsmbProvider = 'R'<<24 | 'S'<<16 | 'M'<<8 | 'B'
+
+// rawSMBIOS returns the raw SMBIOS data from GetSystemFirmwareTable,
+// which is what WMI's Win32_SystemEnclosure is built on. It's a
+// variable so tests can replace it.
+var rawSMBIOS = func() ([]byte, error) {
+	n, _, err := procGetSystemFirmwareTable.Call(rsmbProvider, 0, 0, 0)
+	if n == 0 {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if n, _, err = procGetSystemFirmwareTable.Call(rsmbProvider, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))); n == 0 {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func formFactorWindows() string {
+	raw, err := rawSMBIOS()
+	if err != nil {
+		return ""
+	}
+	ct, ok := smbiosChassisType(raw)
+	if !ok {
+		return ""
+	}
+	return chassisFormFactor(ct)
+}
+
+// smbiosChassisType returns the chassis type from the first System
+// Enclosure (type 3) structure in raw, a RawSMBIOSData: an 8 byte
+// header followed by the SMBIOS structure table.
+func smbiosChassisType(raw []byte) (chassisType int, ok bool) {
+	const headerLen = 8
+	if len(raw) < headerLen {
+		return 0, false
+	}
+	tab := raw[headerLen:]
+	for len(tab) >= 4 {
+		typ, fmtLen := tab[0], int(tab[1])
+		if fmtLen < 4 || fmtLen > len(tab) {
+			return 0, false
+		}
+		if typ == 3 && fmtLen > 5 {
+			return int(tab[5] & 0x7f), true // top bit is "chassis lock present"
+		}
+		if typ == 127 { // end of table
+			return 0, false
+		}
+		// The formatted area is followed by a set of strings ending in
+		// a double NUL.
+		rest := tab[fmtLen:]
+		end := bytes.Index(rest, []byte{0, 0})
+		if end < 0 {
+			return 0, false
+		}
+		tab = rest[end+2:]
+	}
+	return 0, false
+}
+
+// dmiWindows returns the SMBIOS strings Windows copies into the
+// registry at boot. The chassis asset tag isn't among them.
+func dmiWindows() (ret dmiInfo) {
+	k, err := openRegKey(`HARDWARE\DESCRIPTION\System\BIOS`, false)
+	if err != nil {
+		return ret
+	}
+	defer k.Close()
+	ret.BIOSVendor, _, _ = k.GetStringValue("BIOSVendor")
+	ret.SysVendor, _, _ = k.GetStringValue("SystemManufacturer")
+	ret.ProductName, _, _ = k.GetStringValue("SystemProductName")
+	return ret
+}
+
+var procCallNtPowerInformation = windows.NewLazySystemDLL("powrprof.dll").NewProc("CallNtPowerInformation")
+
+// lastWakeTime is the LastWakeTime POWER_INFORMATION_LEVEL.
+const lastWakeTime = 14
+
+// lastWakeInterruptTime returns the interrupt time, in 100ns units
+// since boot, at which the system last woke, or 0 if it hasn't slept.
+// It's a variable so tests can replace it.
+var lastWakeInterruptTime = func() (uint64, error) {
+	var t uint64
+	r, _, _ := procCallNtPowerInformation.Call(lastWakeTime, 0, 0, uintptr(unsafe.Pointer(&t)), unsafe.Sizeof(t))
+	if r != 0 { // NTSTATUS
+		return 0, fmt.Errorf("CallNtPowerInformation: NTSTATUS %#x", r)
+	}
+	return t, nil
+}
+
+// resumeTimeWindows returns when the system last woke, using the
+// uptime (which like interrupt time includes sleep) to convert the
+// wake time to wall time.
+func resumeTimeWindows() time.Time {
+	wake, err := lastWakeInterruptTime()
+	if err != nil || wake == 0 {
+		return time.Time{}
+	}
+	ms, err := tickCount64()
+	if err != nil {
+		return time.Time{}
+	}
+	sinceBoot := time.Duration(ms) * time.Millisecond
+	wokeAfter := time.Duration(wake) * 100
+	if wokeAfter > sinceBoot {
+		return time.Time{}
+	}
+	return time.Now().Add(wokeAfter - sinceBoot)
+}
+
+// maxHandlesPerProcess is the most handles a Windows process can open,
+// 2^24. Windows has no configurable per-process limit like
+// RLIMIT_NOFILE; in practice kernel memory runs out first.
+const maxHandlesPerProcess = 1 << 24
+
+// fdLimitWindows returns the fixed per-process handle limit as both the
+// soft and hard limits.
+func fdLimitWindows() fdLimitInfo {
+	return fdLimitInfo{Soft: maxHandlesPerProcess, Hard: maxHandlesPerProcess}
+}
+
+// tokenElevated reports whether the process token is elevated
+// (TOKEN_ELEVATION). It's a variable so tests can replace it.
+var tokenElevated = func() (bool, error) {
+	token, err := windows.OpenCurrentProcessToken()
+	if err != nil {
+		return false, err
+	}
+	defer token.Close()
+	return token.IsElevated(), nil
+}
+
+// elevatedWindows reports whether the process has an elevated token.
+// With UAC, an Administrator's processes aren't elevated unless run as
+// Administrator.
+func elevatedWindows() (ret opt.Bool) {
+	v, err := tokenElevated()
+	if err != nil {
+		return ""
+	}
+	ret.Set(v)
+	return ret
+}
+
+// openUserRegKey opens the HKEY_CURRENT_USER key at path for reading.
+// It's a variable so tests can replace it.
+var openUserRegKey = func(path string) (regKey, error) {
+	k, err := registry.OpenKey(registry.CURRENT_USER, path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// proxyWindows returns the WinINET proxy settings (those in the
+// Internet Options control panel) of the user we're running as. The
+// GUI runs as the logged in user; tailscaled runs as LocalSystem, whose
+// settings are usually empty.
+func proxyWindows() proxyInfo {
+	k, err := openUserRegKey(`Software\Microsoft\Windows\CurrentVersion\Internet Settings`)
+	if err != nil {
+		return proxyInfo{}
+	}
+	defer k.Close()
+	if enabled, _, err := k.GetIntegerValue("ProxyEnable"); err != nil || enabled == 0 {
+		return proxyInfo{}
+	}
+	server, _, _ := k.GetStringValue("ProxyServer")
+	override, _, _ := k.GetStringValue("ProxyOverride")
+	ret := parseProxyServer(server)
+	if ret != (proxyInfo{}) && override != "" {
+		ret.NoProxy = strings.ReplaceAll(override, ";", ",")
+	}
+	return ret
+}
+
+// parseProxyServer parses a WinINET ProxyServer value, which is
+// either one proxy for all schemes, like "proxy:3128", or a list of
+// them by scheme, like "http=proxy:3128;https=proxy:3129;ftp=proxy:21".
+func parseProxyServer(v string) proxyInfo {
+	if !strings.Contains(v, "=") {
+		return proxyInfo{HTTPProxy: v, HTTPSProxy: v}
+	}
+	var ret proxyInfo
+	for _, f := range strings.Split(v, ";") {
+		scheme, proxy, _ := strings.Cut(f, "=")
+		switch strings.ToLower(strings.TrimSpace(scheme)) {
+		case "http":
+			ret.HTTPProxy = proxy
+		case "https":
+			ret.HTTPSProxy = proxy
+		}
+	}
+	return ret
+}
+
+var (
+	user32                        = windows.NewLazySystemDLL("user32.dll")
+	procGetProcessWindowStation   = user32.NewProc("GetProcessWindowStation")
+	procGetUserObjectInformationW = user32.NewProc("GetUserObjectInformationW")
+)
+
+// uoiName is GetUserObjectInformation's UOI_NAME.
+const uoiName = 2
+
+// interactiveWindowStation is the name of the only window station that
+// can have a visible desktop.
+const interactiveWindowStation = "WinSta0"
+
+// Session and window station probes. They're variables so tests can
+// replace them.
+var (
+	processSessionID = func() (uint32, error) {
+		var id uint32
+		err := windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &id)
+		return id, err
+	}
+
+	// windowStationName returns the name of the process's window
+	// station, like "WinSta0" or "Service-0x0-3e7$".
+	windowStationName = func() (string, error) {
+		h, _, err := procGetProcessWindowStation.Call()
+		if h == 0 {
+			return "", err
+		}
+		var name [256]uint16
+		var n uint32
+		if r, _, err := procGetUserObjectInformationW.Call(h, uoiName,
+			uintptr(unsafe.Pointer(&name[0])), unsafe.Sizeof(name), uintptr(unsafe.Pointer(&n))); r == 0 {
+			return "", err
+		}
+		return windows.UTF16ToString(name[:]), nil
+	}
+)
+
+// hasInteractiveDesktop reports whether this process can show windows
+// to a user. Services, including tailscaled, run in session 0, which
+// has had no desktop since Windows Vista, and processes started over
+// SSH or as scheduled tasks get a non-interactive window station. A
+// Remote Desktop session is interactive, so unlike
+// WTSGetActiveConsoleSessionId this doesn't only accept the physical
+// console.
+func hasInteractiveDesktop() (ret opt.Bool) {
+	id, err := processSessionID()
+	if err != nil {
+		return ""
+	}
+	if id == 0 {
+		ret.Set(false)
+		return ret
+	}
+	name, err := windowStationName()
+	if err != nil {
+		return ""
+	}
+	ret.Set(strings.EqualFold(name, interactiveWindowStation))
+	return ret
+}
+
+// adapterDNS is a network adapter's DNS configuration.
+type adapterDNS struct {
+	Up      bool
+	Servers []netip.Addr
+}
+
+// adapterDNSConfig returns the DNS configuration of each network
+// adapter, from GetAdaptersAddresses, in the adapters' binding order.
+// It's a variable so tests can replace it.
+var adapterDNSConfig = func() ([]adapterDNS, error) {
+	const flags = 0x1 | 0x2 | 0x4 // GAA_FLAG_SKIP_{UNICAST,ANYCAST,MULTICAST}
+	var buf []byte
+	size := uint32(15000) // the size MSDN recommends
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, flags, 0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW || size <= uint32(len(buf)) {
+			return nil, err
+		}
+	}
+	var ret []adapterDNS
+	for a := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); a != nil; a = a.Next {
+		ad := adapterDNS{Up: a.OperStatus == windows.IfOperStatusUp}
+		for s := a.FirstDnsServerAddress; s != nil; s = s.Next {
+			if ip, ok := netip.AddrFromSlice(s.Address.IP()); ok {
+				ad.Servers = append(ad.Servers, ip.Unmap())
+			}
+		}
+		ret = append(ret, ad)
+	}
+	return ret, nil
+}
+
+// deprecatedSiteLocalDNS are the site-local DNS servers Windows lists
+// on adapters without IPv6 DNS servers, which don't usually exist.
+var deprecatedSiteLocalDNS = []netip.Addr{
+	netip.MustParseAddr("fec0:0:0:ffff::1"),
+	netip.MustParseAddr("fec0:0:0:ffff::2"),
+	netip.MustParseAddr("fec0:0:0:ffff::3"),
+}
+
+// dnsServersWindows returns the DNS servers of the adapters that are
+// up, without duplicates.
+func dnsServersWindows() (servers []netip.Addr, ok bool) {
+	adapters, err := adapterDNSConfig()
+	if err != nil {
+		return nil, false
+	}
+	seen := map[netip.Addr]bool{}
+	for _, ip := range deprecatedSiteLocalDNS {
+		seen[ip] = true
+	}
+	for _, a := range adapters {
+		if !a.Up {
+			continue
+		}
+		for _, ip := range a.Servers {
+			if !seen[ip] {
+				seen[ip] = true
+				servers = append(servers, ip)
+			}
+		}
+	}
+	return servers, true
+}
@@ -0,0 +1,735 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hostinfo
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"tailscale.com/types/opt"
+)
+
+func TestPendingReboot(t *testing.T) {
+	errAccess := errors.New("access denied")
+	tests := []struct {
+		name    string
+		present map[int]bool  // index into rebootSignals
+		failing map[int]error // index into rebootSignals
+		want    opt.Bool
+	}{
+		{name: "none", want: "false"},
+		{name: "cbs", present: map[int]bool{0: true}, want: "true"},
+		{name: "windows_update", present: map[int]bool{1: true}, want: "true"},
+		{name: "file_rename", present: map[int]bool{2: true}, want: "true"},
+		{name: "unreadable", failing: map[int]error{1: errAccess}, want: ""},
+		{
+			name:    "unreadable_but_present",
+			present: map[int]bool{2: true},
+			failing: map[int]error{0: errAccess},
+			want:    "true",
+		},
+	}
+	old := probeRebootSignal
+	defer func() { probeRebootSignal = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probeRebootSignal = func(sig rebootSignal) (bool, error) {
+				for i, s := range rebootSignals {
+					if s == sig {
+						return tt.present[i], tt.failing[i]
+					}
+				}
+				t.Fatalf("unknown signal %+v", sig)
+				return false, nil
+			}
+			if got := pendingReboot(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowsServiceStatus(t *testing.T) {
+	tests := []struct {
+		session    uint32
+		serviceSID bool
+		want       opt.Bool
+	}{
+		{0, true, "true"},
+		{0, false, ""},
+		{1, false, "false"},
+		{2, true, "false"},
+	}
+	for _, tt := range tests {
+		if got := windowsServiceStatus(tt.session, tt.serviceSID); got != tt.want {
+			t.Errorf("windowsServiceStatus(%d, %v) = %q; want %q", tt.session, tt.serviceSID, got, tt.want)
+		}
+	}
+}
+
+func TestMachineIDWindows(t *testing.T) {
+	old := readMachineGuid
+	defer func() { readMachineGuid = old }()
+
+	readMachineGuid = func() (string, error) { return "6f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0", nil }
+	if got, want := machineIDWindows(), "6f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	readMachineGuid = func() (string, error) { return "", registry.ErrNotExist }
+	if got := machineIDWindows(); got != "" {
+		t.Errorf("with unreadable registry, got %q; want empty", got)
+	}
+}
+
+func TestBatteryStatusWindows(t *testing.T) {
+	tests := []struct {
+		name string
+		flag byte
+		err  error
+		want opt.Bool
+	}{
+		{name: "laptop_charging", flag: 8, want: "true"},
+		{name: "laptop_high", flag: 1, want: "true"},
+		{name: "laptop_flags_zero", flag: 0, want: "true"},
+		{name: "desktop", flag: batteryFlagNoBattery, want: "false"},
+		{name: "unknown", flag: batteryFlagUnknown, want: ""},
+		{name: "error", err: errors.New("boom"), want: ""},
+	}
+	old := getSystemPowerStatus
+	defer func() { getSystemPowerStatus = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getSystemPowerStatus = func() (systemPowerStatus, error) {
+				return systemPowerStatus{BatteryFlag: tt.flag}, tt.err
+			}
+			if got := batteryStatusWindows(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPv6StatusWindows(t *testing.T) {
+	tests := []struct {
+		name string
+		v    uint64
+		err  error
+		want opt.Bool
+	}{
+		{name: "default", err: registry.ErrNotExist, want: "true"},
+		{name: "prefer_ipv4", v: 0x20, want: "true"},
+		{name: "disabled", v: 0xff, want: "false"},
+		{name: "disabled_extra_bits", v: 0xffffffff, want: "false"},
+		{name: "unreadable", err: errors.New("access denied"), want: ""},
+	}
+	old := readIPv6DisabledComponents
+	defer func() { readIPv6DisabledComponents = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readIPv6DisabledComponents = func() (uint64, error) { return tt.v, tt.err }
+			if got := ipv6StatusWindows(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBootTimeWindows(t *testing.T) {
+	old := tickCount64
+	defer func() { tickCount64 = old }()
+
+	tickCount64 = func() (uint64, error) { return 90 * 60 * 1000, nil }
+	got := time.Since(bootTimeWindows())
+	if want := 90 * time.Minute; got < want || got > want+time.Second {
+		t.Errorf("time since boot = %v; want %v", got, want)
+	}
+
+	tickCount64 = func() (uint64, error) { return 0, errors.New("no such proc") }
+	if got := bootTimeWindows(); !got.IsZero() {
+		t.Errorf("on error, got %v; want zero", got)
+	}
+}
+
+func TestGPUsWindows(t *testing.T) {
+	tests := []struct {
+		name  string
+		descs []string
+		err   error
+		want  []string
+	}{
+		{
+			name:  "hybrid",
+			descs: []string{"Intel(R) UHD Graphics 620", "NVIDIA GeForce MX150"},
+			want:  []string{"Intel(R) UHD Graphics 620", "NVIDIA GeForce MX150"},
+		},
+		{
+			name:  "duplicate_and_blank",
+			descs: []string{"Microsoft Basic Display Adapter", " ", "Microsoft Basic Display Adapter"},
+			want:  []string{"Microsoft Basic Display Adapter"},
+		},
+		{
+			name: "unreadable",
+			err:  registry.ErrNotExist,
+		},
+	}
+	old := readDisplayAdapters
+	defer func() { readDisplayAdapters = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readDisplayAdapters = func() ([]string, error) { return tt.descs, tt.err }
+			if got := gpusWindows(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSDomainsWindows(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     map[string]string
+		err        error
+		wantDomain string
+		wantSearch []string
+		wantOK     bool
+	}{
+		{
+			name:       "domain_joined",
+			params:     map[string]string{"Domain": "ad.example.com", "SearchList": "ad.example.com, example.com"},
+			wantDomain: "ad.example.com",
+			wantSearch: []string{"ad.example.com", "example.com"},
+			wantOK:     true,
+		},
+		{
+			name:       "nv_domain_no_list",
+			params:     map[string]string{"NV Domain": "corp.example"},
+			wantDomain: "corp.example",
+			wantSearch: []string{"corp.example"},
+			wantOK:     true,
+		},
+		{
+			name:   "workgroup",
+			params: map[string]string{"Domain": "", "SearchList": ""},
+			wantOK: true,
+		},
+		{
+			name: "unreadable",
+			err:  errors.New("access denied"),
+		},
+	}
+	old := readTcpipParams
+	defer func() { readTcpipParams = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readTcpipParams = func(...string) (map[string]string, error) { return tt.params, tt.err }
+			domain, search, ok := dnsDomainsWindows()
+			if domain != tt.wantDomain || !reflect.DeepEqual(search, tt.wantSearch) || ok != tt.wantOK {
+				t.Errorf("got %q, %q, %v; want %q, %q, %v", domain, search, ok, tt.wantDomain, tt.wantSearch, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDiskSpaceWindows(t *testing.T) {
+	old := getDiskFreeSpaceEx
+	defer func() { getDiskFreeSpaceEx = old }()
+
+	const wantFree, wantTotal uint64 = 1 << 30, 1 << 40
+	var gotPath string
+	getDiskFreeSpaceEx = func(dir *uint16, freeAvail, total, totalFree *uint64) error {
+		gotPath = windows.UTF16PtrToString(dir)
+		*freeAvail, *total, *totalFree = wantFree, wantTotal, 2*wantFree
+		return nil
+	}
+	free, total := diskSpaceWindows(`C:\ProgramData\Tailscale`)
+	if free != wantFree || total != wantTotal {
+		t.Errorf("got %v, %v; want %v, %v", free, total, wantFree, wantTotal)
+	}
+	if gotPath != `C:\ProgramData\Tailscale` {
+		t.Errorf("queried %q", gotPath)
+	}
+
+	getDiskFreeSpaceEx = func(*uint16, *uint64, *uint64, *uint64) error { return windows.ERROR_PATH_NOT_FOUND }
+	if free, total := diskSpaceWindows(`Z:\`); free != 0 || total != 0 {
+		t.Errorf("on error, got %v, %v; want zeros", free, total)
+	}
+}
+
+func TestDebuggedWindows(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, remote bool
+		err           error
+		want          opt.Bool
+	}{
+		{"none", false, false, nil, "false"},
+		{"local", true, false, nil, "true"},
+		{"remote", false, true, nil, "true"},
+		{"both", true, true, nil, "true"},
+		{"error", false, false, errors.New("access denied"), ""},
+	}
+	old := debuggerPresent
+	defer func() { debuggerPresent = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debuggerPresent = func() (bool, bool, error) { return tt.local, tt.remote, tt.err }
+			if got := debuggedWindows(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTPMWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint32
+		result  uint32
+		err     error
+		want    tpmInfo
+	}{
+		{name: "tpm2", version: 2, want: tpmInfo{Present: "true", Version: "2.0"}},
+		{name: "tpm12", version: 1, want: tpmInfo{Present: "true", Version: "1.2"}},
+		{name: "unknown_version", version: 7, want: tpmInfo{Present: "true"}},
+		{name: "not_found", result: tbsETPMNotFound, want: tpmInfo{Present: "false"}},
+		{name: "other_failure", result: 0x80284001, want: tpmInfo{}},
+		{name: "no_tbs", err: errors.New("tbs.dll not found"), want: tpmInfo{}},
+	}
+	old := getTPMDeviceInfo
+	defer func() { getTPMDeviceInfo = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getTPMDeviceInfo = func() (tpmDeviceInfo, uint32, error) {
+				return tpmDeviceInfo{StructVersion: 1, TPMVersion: tt.version}, tt.result, tt.err
+			}
+			if got := tpmWindows(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocaleWindows(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		acp    uint32
+		err    error
+		want   localeInfo
+	}{
+		{"en_us", "en-US", 1252, nil, localeInfo{"en", "US", "CP1252"}},
+		{"ja_jp", "ja-JP", 932, nil, localeInfo{"ja", "JP", "CP932"}},
+		{"utf8", "de-CH", cpUTF8, nil, localeInfo{"de", "CH", "UTF-8"}},
+		{"script_subtag", "zh-Hans-CN", 936, nil, localeInfo{"zh", "CN", "CP936"}},
+		{"error", "", 0, errors.New("boom"), localeInfo{}},
+	}
+	old := userLocale
+	defer func() { userLocale = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userLocale = func() (string, uint32, error) { return tt.locale, tt.acp, tt.err }
+			if got := localeWindows(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirmwareModeWindows(t *testing.T) {
+	tests := []struct {
+		name     string
+		ft       uint32
+		ftErr    error
+		sbVal    uint64
+		sbErr    error
+		wantMode string
+		wantSB   opt.Bool
+	}{
+		{name: "uefi_sb_on", ft: firmwareTypeUefi, sbVal: 1, wantMode: "uefi", wantSB: "true"},
+		{name: "uefi_sb_off", ft: firmwareTypeUefi, sbVal: 0, wantMode: "uefi", wantSB: "false"},
+		{name: "uefi_no_key", ft: firmwareTypeUefi, sbErr: registry.ErrNotExist, wantMode: "uefi", wantSB: ""},
+		{name: "bios", ft: firmwareTypeBios, sbErr: registry.ErrNotExist, wantMode: "bios", wantSB: "false"},
+		{name: "unknown_type", ft: 0, sbErr: registry.ErrNotExist, wantMode: "", wantSB: ""},
+		{name: "no_api", ftErr: errors.New("proc not found"), sbVal: 1, wantMode: "", wantSB: "true"},
+	}
+	oldFT, oldSB := getFirmwareType, secureBootEnabledValue
+	defer func() { getFirmwareType, secureBootEnabledValue = oldFT, oldSB }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getFirmwareType = func() (uint32, error) { return tt.ft, tt.ftErr }
+			secureBootEnabledValue = func() (uint64, error) { return tt.sbVal, tt.sbErr }
+			if got := firmwareModeWindows(); got != tt.wantMode {
+				t.Errorf("firmwareModeWindows = %q; want %q", got, tt.wantMode)
+			}
+			if got := secureBoot(); got != tt.wantSB {
+				t.Errorf("secureBoot = %q; want %q", got, tt.wantSB)
+			}
+		})
+	}
+}
+
+// fakeRegKey is a regKey whose values are held in a map.
+type fakeRegKey map[string]any // string or uint64
+
+func (k fakeRegKey) GetIntegerValue(name string) (uint64, uint32, error) {
+	v, ok := k[name].(uint64)
+	if !ok {
+		return 0, 0, registry.ErrNotExist
+	}
+	return v, registry.DWORD, nil
+}
+
+func (k fakeRegKey) GetStringValue(name string) (string, uint32, error) {
+	v, ok := k[name].(string)
+	if !ok {
+		return "", 0, registry.ErrNotExist
+	}
+	return v, registry.SZ, nil
+}
+
+func (k fakeRegKey) ReadValueNames(int) ([]string, error) {
+	var names []string
+	for name := range k {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (fakeRegKey) Close() error { return nil }
+
+func TestInstalledRuntimes(t *testing.T) {
+	const (
+		vc   = `SOFTWARE\Microsoft\VisualStudio\14.0\VC\Runtimes\`
+		ndp  = `SOFTWARE\Microsoft\NET Framework Setup\NDP\v4\Full`
+		core = `SOFTWARE\dotnet\Setup\InstalledVersions\x64\sharedfx\Microsoft.NETCore.App`
+	)
+	tests := []struct {
+		name string
+		keys map[string]fakeRegKey // keyed by "32:" or "64:" and path
+		want []string
+	}{
+		{name: "none"},
+		{
+			name: "all",
+			keys: map[string]fakeRegKey{
+				"64:" + vc + "x64": {"Installed": uint64(1), "Version": "v14.32.31332.00"},
+				"32:" + vc + "x86": {"Installed": uint64(1), "Version": "v14.29.30139.00"},
+				"64:" + ndp:        {"Version": "4.8.04084", "Release": uint64(528040)},
+				"32:" + core:       {"6.0.10": uint64(1), "3.1.30": uint64(1)},
+			},
+			want: []string{
+				"VC++ 14.29.30139 x86",
+				"VC++ 14.32.31332 x64",
+				".NET Framework 4.8.04084",
+				".NET 3.1.30 x64",
+				".NET 6.0.10 x64",
+			},
+		},
+		{
+			name: "vc_not_installed",
+			keys: map[string]fakeRegKey{
+				"64:" + vc + "x64":   {"Installed": uint64(0), "Version": "v14.32.31332.00"},
+				"64:" + vc + "arm64": {"Installed": uint64(1)}, // no version
+			},
+		},
+		{
+			name: "wrong_view",
+			keys: map[string]fakeRegKey{
+				"64:" + core: {"6.0.10": uint64(1)},
+			},
+		},
+	}
+	old := openRegKey
+	defer func() { openRegKey = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openRegKey = func(path string, wow32 bool) (regKey, error) {
+				view := "64:"
+				if wow32 {
+					view = "32:"
+				}
+				k, ok := tt.keys[view+path]
+				if !ok {
+					return nil, registry.ErrNotExist
+				}
+				return k, nil
+			}
+			if got := installedRuntimes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPUMHzWindows(t *testing.T) {
+	const path = `HARDWARE\DESCRIPTION\System\CentralProcessor\0`
+	tests := []struct {
+		name string
+		key  fakeRegKey // nil means missing
+		want cpuClock
+	}{
+		{"present", fakeRegKey{"~MHz": uint64(3401), "ProcessorNameString": "Intel(R) Core(TM) i7"}, cpuClock{Nominal: 3401}},
+		{"no_value", fakeRegKey{}, cpuClock{}},
+		{"no_key", nil, cpuClock{}},
+	}
+	old := openRegKey
+	defer func() { openRegKey = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openRegKey = func(p string, wow32 bool) (regKey, error) {
+				if p != path || wow32 || tt.key == nil {
+					return nil, registry.ErrNotExist
+				}
+				return tt.key, nil
+			}
+			if got := cpuMHzWindows(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormFactorWindows(t *testing.T) {
+	// smbios returns a RawSMBIOSData with a BIOS Information structure
+	// followed by a System Enclosure of the given chassis type.
+	smbios := func(chassisType byte) []byte {
+		b := []byte{0, 3, 4, 0, 0, 0, 0, 0} // header; length is ignored
+		b = append(b, 0, 4, 0, 0)           // type 0, no strings
+		b = append(b, 0, 0)
+		b = append(b, 3, 6, 1, 0, 1, chassisType) // type 3
+		b = append(b, 'A', 'c', 'm', 'e', 0, 0)
+		b = append(b, 127, 4, 2, 0, 0, 0)
+		return b
+	}
+	tests := []struct {
+		name string
+		raw  []byte
+		err  error
+		want string
+	}{
+		{"laptop", smbios(10), nil, "laptop"},
+		{"lock_bit", smbios(0x80 | 3), nil, "desktop"},
+		{"rack", smbios(23), nil, "server"},
+		{"tablet", smbios(30), nil, "tablet"},
+		{"unknown", smbios(2), nil, ""},
+		{"no_enclosure", []byte{0, 3, 4, 0, 0, 0, 0, 0, 127, 4, 0, 0, 0, 0}, nil, ""},
+		{"truncated", smbios(9)[:14], nil, ""},
+		{"error", nil, errors.New("not supported"), ""},
+	}
+	old := rawSMBIOS
+	defer func() { rawSMBIOS = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawSMBIOS = func() ([]byte, error) { return tt.raw, tt.err }
+			if got := formFactorWindows(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDMIWindows(t *testing.T) {
+	old := openRegKey
+	defer func() { openRegKey = old }()
+	openRegKey = func(path string, wow32 bool) (regKey, error) {
+		if path != `HARDWARE\DESCRIPTION\System\BIOS` {
+			return nil, registry.ErrNotExist
+		}
+		return fakeRegKey{
+			"BIOSVendor":         "Google",
+			"SystemManufacturer": "Google",
+			"SystemProductName":  "Google Compute Engine",
+		}, nil
+	}
+	want := dmiInfo{BIOSVendor: "Google", SysVendor: "Google", ProductName: "Google Compute Engine"}
+	if got := dmiWindows(); got != want {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestResumeTimeWindows(t *testing.T) {
+	const hour = uint64(time.Hour / 100) // in 100ns units
+	tests := []struct {
+		name    string
+		wake    uint64
+		wakeErr error
+		wantAgo time.Duration // 0 means zero time
+	}{
+		{"woke", 2 * hour, nil, time.Hour},
+		{"never_slept", 0, nil, 0},
+		{"error", 0, errors.New("boom"), 0},
+		{"after_now", 4 * hour, nil, 0},
+	}
+	oldWake, oldTick := lastWakeInterruptTime, tickCount64
+	defer func() { lastWakeInterruptTime, tickCount64 = oldWake, oldTick }()
+	tickCount64 = func() (uint64, error) { return uint64(3 * time.Hour / time.Millisecond), nil }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lastWakeInterruptTime = func() (uint64, error) { return tt.wake, tt.wakeErr }
+			got := resumeTimeWindows()
+			if tt.wantAgo == 0 {
+				if !got.IsZero() {
+					t.Errorf("got %v; want zero", got)
+				}
+				return
+			}
+			if ago := time.Since(got); ago < tt.wantAgo || ago > tt.wantAgo+time.Minute {
+				t.Errorf("resumed %v ago; want %v", ago, tt.wantAgo)
+			}
+		})
+	}
+}
+
+func TestElevatedWindows(t *testing.T) {
+	tests := []struct {
+		name     string
+		elevated bool
+		err      error
+		want     opt.Bool
+	}{
+		{"elevated", true, nil, "true"},
+		{"not_elevated", false, nil, "false"},
+		{"error", false, errors.New("access denied"), ""},
+	}
+	old := tokenElevated
+	defer func() { tokenElevated = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenElevated = func() (bool, error) { return tt.elevated, tt.err }
+			if got := elevatedWindows(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyWindows(t *testing.T) {
+	tests := []struct {
+		name string
+		key  fakeRegKey // or nil for no key
+		want proxyInfo
+	}{
+		{name: "no_key"},
+		{
+			name: "disabled",
+			key:  fakeRegKey{"ProxyEnable": uint64(0), "ProxyServer": "proxy:3128"},
+		},
+		{
+			name: "all_schemes",
+			key:  fakeRegKey{"ProxyEnable": uint64(1), "ProxyServer": "proxy:3128", "ProxyOverride": "*.corp.example.com;<local>"},
+			want: proxyInfo{HTTPProxy: "proxy:3128", HTTPSProxy: "proxy:3128", NoProxy: "*.corp.example.com,<local>"},
+		},
+		{
+			name: "per_scheme",
+			key:  fakeRegKey{"ProxyEnable": uint64(1), "ProxyServer": "http=web:80;https=secure:443;ftp=files:21"},
+			want: proxyInfo{HTTPProxy: "web:80", HTTPSProxy: "secure:443"},
+		},
+		{
+			name: "ftp_only",
+			key:  fakeRegKey{"ProxyEnable": uint64(1), "ProxyServer": "ftp=files:21", "ProxyOverride": "<local>"},
+			want: proxyInfo{},
+		},
+		{
+			name: "enabled_no_server",
+			key:  fakeRegKey{"ProxyEnable": uint64(1)},
+			want: proxyInfo{},
+		},
+	}
+	old := openUserRegKey
+	defer func() { openUserRegKey = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openUserRegKey = func(path string) (regKey, error) {
+				if tt.key == nil {
+					return nil, registry.ErrNotExist
+				}
+				return tt.key, nil
+			}
+			if got := proxyWindows(); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasInteractiveDesktop(t *testing.T) {
+	errFail := errors.New("fail")
+	tests := []struct {
+		name       string
+		session    uint32
+		sessionErr error
+		station    string
+		stationErr error
+		want       opt.Bool
+	}{
+		{name: "console", session: 1, station: "WinSta0", want: "true"},
+		{name: "rdp", session: 3, station: "WinSta0", want: "true"},
+		{name: "service", session: 0, station: "Service-0x0-3e7$", want: "false"},
+		{name: "session0_winsta0", session: 0, station: "WinSta0", want: "false"},
+		{name: "ssh", session: 2, station: "Service-0x0-1a2b3$", want: "false"},
+		{name: "session_error", sessionErr: errFail, want: ""},
+		{name: "station_error", session: 1, stationErr: errFail, want: ""},
+	}
+	oldSession, oldStation := processSessionID, windowStationName
+	defer func() { processSessionID, windowStationName = oldSession, oldStation }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processSessionID = func() (uint32, error) { return tt.session, tt.sessionErr }
+			windowStationName = func() (string, error) { return tt.station, tt.stationErr }
+			if got := hasInteractiveDesktop(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSServersWindows(t *testing.T) {
+	ip := netip.MustParseAddr
+	tests := []struct {
+		name   string
+		config []adapterDNS
+		err    error
+		want   []netip.Addr
+		wantOK bool
+	}{
+		{
+			name: "up_adapters",
+			config: []adapterDNS{
+				{Up: true, Servers: []netip.Addr{ip("192.168.1.1"), ip("fec0:0:0:ffff::1"), ip("fec0:0:0:ffff::2")}},
+				{Up: false, Servers: []netip.Addr{ip("10.9.9.9")}},
+				{Up: true, Servers: []netip.Addr{ip("2001:db8::53"), ip("192.168.1.1")}},
+				{Up: true},
+			},
+			want:   []netip.Addr{ip("192.168.1.1"), ip("2001:db8::53")},
+			wantOK: true,
+		},
+		{
+			name:   "none_up",
+			config: []adapterDNS{{Up: false, Servers: []netip.Addr{ip("10.9.9.9")}}},
+			wantOK: true,
+		},
+		{
+			name: "error",
+			err:  windows.ERROR_NOT_ENOUGH_MEMORY,
+		},
+	}
+	old := adapterDNSConfig
+	defer func() { adapterDNSConfig = old }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapterDNSConfig = func() ([]adapterDNS, error) { return tt.config, tt.err }
+			got, ok := dnsServersWindows()
+			if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOK {
+				t.Errorf("got %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
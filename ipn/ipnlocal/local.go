@@ -250,6 +250,8 @@ func NewLocalBackend(logf logger.Logf, logid string, store ipn.StateStore, diale
 
 	b.unregisterHealthWatch = health.RegisterWatcher(b.onHealthChange)
 
+	go b.refreshCloud()
+
 	wiredPeerAPIPort := false
 	if ig, ok := e.(wgengine.InternalsGetter); ok {
 		if tunWrap, _, _, ok := ig.GetInternals(); ok {
@@ -2193,6 +2195,31 @@ func (b *LocalBackend) peerAPIServicesLocked() (ret []tailcfg.Service) {
 	return ret
 }
 
+// refreshCloud checks the cloud metadata server, if the offline guess
+// that hostinfo.New used for Hostinfo.Cloud was inconclusive (as on
+// Azure and older GCP VMs), and sends control an updated Hostinfo if
+// that changes the cloud.
+func (b *LocalBackend) refreshCloud() {
+	ctx, cancel := context.WithTimeout(b.ctx, 5*time.Second)
+	defer cancel()
+	c := string(hostinfo.RefreshCloudMetadata(ctx))
+
+	b.mu.Lock()
+	if b.hostinfo == nil || b.hostinfo.Cloud == c {
+		// Not started yet, in which case Start's hostinfo.New
+		// will see the refreshed value, or nothing changed.
+		b.mu.Unlock()
+		return
+	}
+	hi := b.hostinfo.Clone()
+	hi.Cloud = c
+	b.hostinfo = hi
+	b.mu.Unlock()
+
+	b.logf("hostinfo: cloud is %q", c)
+	b.doSetHostinfoFilterServices(hi.Clone())
+}
+
 // doSetHostinfoFilterServices calls SetHostinfo on the controlclient,
 // possibly after mangling the given hostinfo.
 //
@@ -0,0 +1,54 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"reflect"
+	"sort"
+)
+
+// BoolBaseline is a snapshot of the Bool fields of a struct, such as
+// shipped defaults, to later find which of them were changed. Create
+// one with SnapshotBools.
+type BoolBaseline struct {
+	vals map[string]Bool // path => logical value
+}
+
+// SnapshotBools records the values of the Bool fields of v, a struct
+// or pointer to one. Fields are found as in ValidateBools, and
+// identified by the same paths, like "Prefs.RouteAll" or
+// "Peers[2].Exit".
+func SnapshotBools(v any) BoolBaseline {
+	b := BoolBaseline{vals: map[string]Bool{}}
+	if rv := reflect.ValueOf(v); reflect.Indirect(rv).Kind() == reflect.Struct {
+		walkBools(rv, func(path []boolStep, x reflect.Value) {
+			b.vals[boolPath(path)] = logical(x.Interface().(Bool))
+		})
+	}
+	return b
+}
+
+// Changed returns the sorted paths of the Bool fields of v, a struct
+// or pointer to one of the same type as was snapshotted, whose values
+// logically differ from the baseline. Changing a field between ""
+// and "unset" isn't a change. Fields that are only in one of the
+// baseline and v, as when a pointer to a nested struct became nil or
+// non-nil, are changed unless they're unset.
+func (b BoolBaseline) Changed(v any) []string {
+	cur := SnapshotBools(v).vals
+	var ret []string
+	for path, x := range cur {
+		if b.vals[path] != x {
+			ret = append(ret, path)
+		}
+	}
+	for path, x := range b.vals {
+		if _, ok := cur[path]; !ok && x.IsSet() {
+			ret = append(ret, path)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBoolBaseline(t *testing.T) {
+	type netPrefs struct {
+		RouteAll Bool
+	}
+	type prefs struct {
+		ShieldsUp Bool
+		Exit      Bool
+		Corp      Bool
+		Hostname  string
+		Net       netPrefs
+		Advertise *netPrefs
+	}
+	defaults := prefs{
+		ShieldsUp: "false",
+		Exit:      "",
+		Corp:      "true",
+		Net:       netPrefs{RouteAll: "true"},
+		Advertise: &netPrefs{RouteAll: "false"},
+	}
+	base := SnapshotBools(&defaults)
+
+	p := defaults
+	p.Advertise = &netPrefs{RouteAll: "false"}
+	if got := base.Changed(&p); got != nil {
+		t.Errorf("unchanged: got %q; want none", got)
+	}
+
+	p.Exit = "unset" // same as ""
+	p.Hostname = "foo"
+	if got := base.Changed(p); got != nil {
+		t.Errorf("respelled unset: got %q; want none", got)
+	}
+
+	p.ShieldsUp = "true"
+	p.Corp = ""
+	p.Net.RouteAll = "false"
+	want := []string{"Corp", "Net.RouteAll", "ShieldsUp"}
+	if got := base.Changed(&p); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// The baseline is a snapshot, unaffected by later changes to the
+	// struct it was taken of.
+	defaults.ShieldsUp = "true"
+	if got := base.Changed(&p); !reflect.DeepEqual(got, want) {
+		t.Errorf("after changing original, got %q; want %q", got, want)
+	}
+
+	p = prefs{ShieldsUp: "false", Corp: "true", Net: netPrefs{RouteAll: "true"}}
+	if got, want := base.Changed(&p), []string{"Advertise.RouteAll"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with nil pointer, got %q; want %q", got, want)
+	}
+}
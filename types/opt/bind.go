@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+// BoolSource is a layer of configuration, such as built-in defaults, a
+// config file, the environment or command-line flags, that may or may
+// not set each boolean setting.
+type BoolSource interface {
+	// LookupBool returns the source's value for key, or unset
+	// (in either spelling) if the source doesn't set it.
+	LookupBool(key string) Bool
+}
+
+// BoolSourceFunc adapts a function to a BoolSource.
+type BoolSourceFunc func(key string) Bool
+
+func (f BoolSourceFunc) LookupBool(key string) Bool { return f(key) }
+
+// BindBool resolves the setting key from sources, which are ordered
+// from lowest to highest precedence (defaults first, flags last). It
+// returns the value from the highest-precedence source that sets key,
+// or unset if none does, so an unset value in one layer falls through
+// to the layers below it.
+func BindBool(key string, sources ...BoolSource) Bool {
+	for i := len(sources) - 1; i >= 0; i-- {
+		v := sources[i].LookupBool(key)
+		if _, ok := v.Get(); ok {
+			return v
+		}
+	}
+	return "unset"
+}
+
+// TrackedBool is a Bool along with the name of the source that set
+// it, such as "flag" or "env", to help debug why a setting has the
+// value it does.
+type TrackedBool struct {
+	Value  Bool
+	Source string
+}
+
+// String returns the value and its source, like "true (from env)".
+func (t TrackedBool) String() string {
+	if _, ok := t.Value.Get(); !ok {
+		return "unset"
+	}
+	return string(t.Value) + " (from " + t.Source + ")"
+}
+
+// Resolve returns the first of sources whose value is set, along with
+// its source name. Unlike BindBool, sources are ordered from highest
+// to lowest precedence (flags first, defaults last). If no source sets
+// a value, it returns an unset TrackedBool with no source.
+func Resolve(sources ...TrackedBool) TrackedBool {
+	for _, s := range sources {
+		if _, ok := s.Value.Get(); ok {
+			return s
+		}
+	}
+	return TrackedBool{Value: "unset"}
+}
+
+// Resolve3 returns the effective value of a setting with a built-in
+// default that an admin policy overrides and that a user preference
+// overrides in turn, along with which of them won: "user", "policy" or
+// "default".
+func Resolve3(def bool, policy, user Bool) (value bool, source string) {
+	if v, ok := user.Get(); ok {
+		return v, "user"
+	}
+	if v, ok := policy.Get(); ok {
+		return v, "policy"
+	}
+	return def, "default"
+}
+
+// ResolveDefault returns the value of b, the setting name, or def if
+// b is unset. When it falls back to def, it calls onDefault (if
+// non-nil) with name and def, so callers can count which defaults are
+// relied on. onDefault isn't called when b is set, to either value.
+func ResolveDefault(name string, b Bool, def bool, onDefault func(name string, def bool)) bool {
+	if v, ok := b.Get(); ok {
+		return v
+	}
+	if onDefault != nil {
+		onDefault(name, def)
+	}
+	return def
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// mapSource is a BoolSource backed by a map.
+type mapSource map[string]Bool
+
+func (m mapSource) LookupBool(key string) Bool { return m[key] }
+
+func TestBindBool(t *testing.T) {
+	defaults := mapSource{"route_all": "false", "shields_up": "false", "ssh": "false"}
+	file := mapSource{"route_all": "true", "ssh": "unset", "accept_dns": "false"}
+	env := BoolSourceFunc(func(key string) Bool {
+		if key == "shields_up" {
+			return "true"
+		}
+		return ""
+	})
+	flags := mapSource{"route_all": "false", "accept_dns": ""}
+	layers := []BoolSource{defaults, file, env, flags}
+
+	tests := []struct {
+		key  string
+		want Bool
+	}{
+		{"route_all", "false"},  // flags beat file beat defaults
+		{"shields_up", "true"},  // env beats defaults
+		{"ssh", "false"},        // file's "unset" falls through to defaults
+		{"accept_dns", "false"}, // flags' "" falls through to file
+		{"missing", "unset"},    // no layer sets it
+	}
+	for _, tt := range tests {
+		if got := BindBool(tt.key, layers...); got != tt.want {
+			t.Errorf("BindBool(%q) = %q; want %q", tt.key, string(got), string(tt.want))
+		}
+	}
+
+	if got := BindBool("route_all"); got != "unset" {
+		t.Errorf("with no sources, got %q; want unset", string(got))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []TrackedBool
+		want    TrackedBool
+		wantStr string
+	}{
+		{
+			name:    "none",
+			want:    TrackedBool{Value: "unset"},
+			wantStr: "unset",
+		},
+		{
+			name: "first_set_wins",
+			sources: []TrackedBool{
+				{"", "flag"},
+				{"false", "env"},
+				{"true", "file"},
+				{"true", "default"},
+			},
+			want:    TrackedBool{"false", "env"},
+			wantStr: "false (from env)",
+		},
+		{
+			name: "explicit_unset_falls_through",
+			sources: []TrackedBool{
+				{"unset", "flag"},
+				{"", "env"},
+				{"true", "default"},
+			},
+			want:    TrackedBool{"true", "default"},
+			wantStr: "true (from default)",
+		},
+		{
+			name: "all_unset",
+			sources: []TrackedBool{
+				{"", "flag"},
+				{"unset", "env"},
+			},
+			want:    TrackedBool{Value: "unset"},
+			wantStr: "unset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(tt.sources...)
+			if got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+			if s := got.String(); s != tt.wantStr {
+				t.Errorf("String = %q; want %q", s, tt.wantStr)
+			}
+		})
+	}
+}
+
+func TestResolve3(t *testing.T) {
+	tests := []struct {
+		policy, user Bool
+		want         bool
+		wantSource   string
+	}{
+		{"", "", true, "default"},
+		{"", "true", true, "user"},
+		{"", "false", false, "user"},
+		{"true", "", true, "policy"},
+		{"true", "true", true, "user"},
+		{"true", "false", false, "user"},
+		{"false", "", false, "policy"},
+		{"false", "true", true, "user"},
+		{"false", "false", false, "user"},
+		{"unset", "unset", true, "default"},
+		{"false", "unset", false, "policy"},
+	}
+	for _, tt := range tests {
+		got, source := Resolve3(true, tt.policy, tt.user)
+		if got != tt.want || source != tt.wantSource {
+			t.Errorf("Resolve3(true, %q, %q) = %v, %q; want %v, %q", tt.policy, tt.user, got, source, tt.want, tt.wantSource)
+		}
+	}
+	if got, source := Resolve3(false, "", ""); got || source != "default" {
+		t.Errorf("Resolve3(false, unset, unset) = %v, %q; want false, default", got, source)
+	}
+}
+
+func TestResolveDefault(t *testing.T) {
+	tests := []struct {
+		b        Bool
+		def      bool
+		want     bool
+		wantCall bool
+	}{
+		{"true", false, true, false},
+		{"false", true, false, false},
+		{"true", true, true, false},
+		{"false", false, false, false},
+		{"", true, true, true},
+		{"unset", false, false, true},
+		{"bogus", true, true, true},
+	}
+	for _, tt := range tests {
+		var calls []string
+		onDefault := func(name string, def bool) {
+			calls = append(calls, fmt.Sprintf("%s=%v", name, def))
+		}
+		got := ResolveDefault("x", tt.b, tt.def, onDefault)
+		if got != tt.want {
+			t.Errorf("ResolveDefault(%q, %v) = %v; want %v", tt.b, tt.def, got, tt.want)
+		}
+		var wantCalls []string
+		if tt.wantCall {
+			wantCalls = []string{fmt.Sprintf("x=%v", tt.def)}
+		}
+		if !reflect.DeepEqual(calls, wantCalls) {
+			t.Errorf("ResolveDefault(%q, %v) callbacks = %q; want %q", tt.b, tt.def, calls, wantCalls)
+		}
+
+		// A nil callback is fine.
+		if got := ResolveDefault("x", tt.b, tt.def, nil); got != tt.want {
+			t.Errorf("ResolveDefault(%q, %v, nil) = %v; want %v", tt.b, tt.def, got, tt.want)
+		}
+	}
+}
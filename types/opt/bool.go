@@ -3,11 +3,29 @@
 // license that can be found in the LICENSE file.
 
 // Package opt defines optional types.
+//
+// The canonical spelling of an unset Bool is "unset". The zero value,
+// the empty string, means the same thing, and every function in this
+// package and its subpackages that produces a Bool returns "unset"
+// rather than "" when the result is unset: the decoders (UnmarshalJSON,
+// UnmarshalText, UnmarshalBinary, Scan and BoolFlag's Set) as well as
+// parsers, lookups and mergers such as ParseBoolEnv, BoolFromQuery,
+// Coalesce, BindBool, Resolve, Merge3 and BoolAt.ClearAt, including
+// alongside an error. So a zero value and an explicitly cleared one
+// read back identically. The exception is Clear, which sets the zero
+// value.
+// Use Normalize to convert a Bool from elsewhere to the canonical form,
+// or IsSet to test for unset without caring about the spelling.
 package opt
 
 import (
+	"bytes"
+	"database/sql/driver"
 	"fmt"
+	"hash"
+	"os"
 	"strconv"
+	"strings"
 )
 
 // Bool represents an optional boolean to be JSON-encoded.  The string
@@ -17,14 +35,26 @@
 // "unset" as as a synonym for the empty string. This lets the
 // explicit unset value be exchanged over an encoding/json "omitempty"
 // field without it being dropped.
+//
+// "unset" is the canonical spelling of a decoded unset value (see the
+// package documentation), so a value read from one encoding and
+// written to another keeps its explicit unset marker. The empty string
+// remains the zero value and what Clear sets.
 type Bool string
 
 func (b *Bool) Set(v bool) {
 	*b = Bool(strconv.FormatBool(v))
 }
 
+// Clear sets b to the empty string, the zero value, which an
+// encoding/json "omitempty" field omits entirely.
 func (b *Bool) Clear() { *b = "" }
 
+// ClearExplicit sets b to "unset", which encodes as JSON null even in
+// an "omitempty" field. Use it to tell a receiver to reset a value it
+// may have set, rather than leave it alone.
+func (b *Bool) ClearExplicit() { *b = "unset" }
+
 func (b Bool) Get() (v bool, ok bool) {
 	switch b {
 	case "true":
@@ -36,10 +66,116 @@ func (b Bool) Get() (v bool, ok bool) {
 	}
 }
 
+// Valid reports whether b's backing string is one of the recognized
+// states: "true", "false", or unset ("" or "unset").
+func (b Bool) Valid() bool {
+	switch b {
+	case "true", "false", "", "unset":
+		return true
+	}
+	return false
+}
+
+// Normalize returns b in canonical form: "unset" if b is "" or
+// "unset", and b itself otherwise. Invalid values are returned as is,
+// so Valid still reports them.
+func (b Bool) Normalize() Bool {
+	if b == "" {
+		return "unset"
+	}
+	return b
+}
+
+// String returns "true", "false", or "unset". Backing values other
+// than "true" and "false" are reported as "unset", matching Get.
+func (b Bool) String() string {
+	if v, ok := b.Get(); ok {
+		return strconv.FormatBool(v)
+	}
+	return "unset"
+}
+
+// Explain returns a description of b for people reading a config
+// dump: "enabled", "disabled", or "not set (default applies)". Like
+// String, it treats backing values other than "true" and "false" as
+// unset.
+func (b Bool) Explain() string {
+	if v, ok := b.Get(); ok {
+		return explainBool(v)
+	}
+	return "not set (default applies)"
+}
+
+// ExplainDefault is like Explain, but names the default that applies
+// when b is unset, as in "not set (defaults to enabled)".
+func (b Bool) ExplainDefault(def bool) string {
+	if v, ok := b.Get(); ok {
+		return explainBool(v)
+	}
+	return "not set (defaults to " + explainBool(def) + ")"
+}
+
+func explainBool(v bool) string {
+	if v {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// Format implements fmt.Formatter.
+//
+// The %s and %v verbs format the String form of b, honoring width,
+// precision and the '-' flag so opt.Bools line up in tables. %+v
+// instead formats the raw backing string, quoted, which is useful for
+// debugging values that aren't what they seem; %#v formats b as Go
+// syntax. %q formats the quoted String form.
+func (b Bool) Format(f fmt.State, verb rune) {
+	var s string
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		s = strconv.Quote(string(b))
+	case verb == 'v' && f.Flag('#'):
+		s = "opt.Bool(" + strconv.Quote(string(b)) + ")"
+	case verb == 'v', verb == 's':
+		s = b.String()
+	case verb == 'q':
+		s = strconv.Quote(b.String())
+	default:
+		fmt.Fprintf(f, "%%!%c(opt.Bool=%s)", verb, string(b))
+		return
+	}
+	fmt.Fprintf(f, formatDirective(f), s)
+}
+
+// formatDirective returns a %s directive with the width, precision and
+// padding flags of f.
+func formatDirective(f fmt.State) string {
+	dir := []byte{'%'}
+	if f.Flag('-') {
+		dir = append(dir, '-')
+	}
+	if f.Flag('0') {
+		dir = append(dir, '0')
+	}
+	if w, ok := f.Width(); ok {
+		dir = strconv.AppendInt(dir, int64(w), 10)
+	}
+	if p, ok := f.Precision(); ok {
+		dir = append(dir, '.')
+		dir = strconv.AppendInt(dir, int64(p), 10)
+	}
+	return string(append(dir, 's'))
+}
+
 // Scan implements database/sql.Scanner.
+//
+// Besides SQL booleans and integers, it accepts the textual forms
+// some drivers return, case-insensitively: "true" and "false" (and
+// "t", "f", "1", "0"), and "unknown", the SQL three-valued logic
+// spelling of NULL, which is scanned as unset like NULL itself.
 func (b *Bool) Scan(src any) error {
 	if src == nil {
-		*b = ""
+		*b = "unset"
 		return nil
 	}
 	switch src := src.(type) {
@@ -57,11 +193,215 @@ func (b *Bool) Scan(src any) error {
 			*b = "true"
 		}
 		return nil
+	case string:
+		return b.scanText(src)
+	case []byte:
+		return b.scanText(string(src))
 	default:
 		return fmt.Errorf("opt.Bool.Scan: invalid type %T: %v", src, src)
 	}
 }
 
+func (b *Bool) scanText(s string) error {
+	switch strings.ToLower(s) {
+	case "true", "t", "1":
+		*b = "true"
+	case "false", "f", "0":
+		*b = "false"
+	case "unknown":
+		*b = "unset"
+	default:
+		return fmt.Errorf("opt.Bool.Scan: invalid value %q", s)
+	}
+	return nil
+}
+
+// TriIntScanner is a database/sql.Scanner that stores into a Bool
+// from an integer column using the tristate encoding -1 (unset), 0
+// (false) and 1 (true). It's returned by ScanTriInt.
+type TriIntScanner struct {
+	b *Bool
+}
+
+// ScanTriInt returns a Scanner that stores into b from a
+// tristate integer column, for schemas that store optional booleans
+// as a SMALLINT of -1, 0 or 1 rather than a nullable boolean:
+//
+//	rows.Scan(opt.ScanTriInt(&b))
+//
+// NULL is scanned as unset like -1. Any other value is an error,
+// including other integers, which Bool.Scan would treat as true.
+func ScanTriInt(b *Bool) TriIntScanner {
+	return TriIntScanner{b}
+}
+
+// Scan implements database/sql.Scanner.
+func (s TriIntScanner) Scan(src any) error {
+	var n int64
+	switch src := src.(type) {
+	case nil:
+		*s.b = "unset"
+		return nil
+	case int64:
+		n = src
+	case string:
+		return s.scanText(src)
+	case []byte:
+		return s.scanText(string(src))
+	default:
+		return fmt.Errorf("opt.ScanTriInt: invalid type %T: %v", src, src)
+	}
+	switch n {
+	case -1:
+		*s.b = "unset"
+	case 0:
+		*s.b = "false"
+	case 1:
+		*s.b = "true"
+	default:
+		return fmt.Errorf("opt.ScanTriInt: invalid value %d", n)
+	}
+	return nil
+}
+
+// scanText scans an integer returned as text, as some drivers do.
+func (s TriIntScanner) scanText(src string) error {
+	n, err := strconv.ParseInt(src, 10, 64)
+	if err != nil {
+		return fmt.Errorf("opt.ScanTriInt: invalid value %q", src)
+	}
+	return s.Scan(n)
+}
+
+// ParseBoolEnv returns the optional boolean value of the named
+// environment variable, using os.LookupEnv.
+//
+// The present result reports whether the variable was in the
+// environment at all. A variable set to the empty string is present
+// but unset, letting callers explicitly clear a value configured by a
+// lower layer. Other values are parsed with strconv.ParseBool. A
+// value that doesn't parse is an error, rather than a silent clear,
+// so that a typo doesn't override a lower layer's setting.
+func ParseBoolEnv(name string) (b Bool, present bool, err error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "unset", false, nil
+	}
+	if v == "" {
+		return "unset", true, nil
+	}
+	pv, err := strconv.ParseBool(v)
+	if err != nil {
+		return "unset", true, fmt.Errorf("opt.ParseBoolEnv: invalid boolean %s=%q", name, v)
+	}
+	b.Set(pv)
+	return b, true, nil
+}
+
+// ParseBoolPrompt parses a user's answer to an interactive yes/no
+// question: "y" or "yes" is true and "n" or "no" is false, in any
+// case. A blank answer means to keep the current value and is
+// returned as unset. Surrounding whitespace, such as the newline a
+// line read from a terminal ends with, is ignored.
+//
+// The ok result reports whether s was one of those answers; other
+// input is returned as unset with ok false, so the caller can ask
+// again.
+func ParseBoolPrompt(s string) (b Bool, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes":
+		return "true", true
+	case "n", "no":
+		return "false", true
+	case "":
+		return "unset", true
+	}
+	return "unset", false
+}
+
+// ParseBoolMap parses a comma-separated list of feature flags, as in
+// an environment variable like TS_FLAGS="foo=true,bar=false,baz=".
+//
+// Each entry is "key=value", where value is anything strconv.ParseBool
+// accepts, or empty to mean unset. A bare "key" means true. Space
+// around keys and values is ignored, as are empty entries. If a key
+// appears more than once, the last entry wins.
+func ParseBoolMap(s string) (map[string]Bool, error) {
+	m := map[string]Bool{}
+	for i, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		k, v, hasValue := strings.Cut(entry, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k == "" {
+			return nil, fmt.Errorf("opt.ParseBoolMap: entry %d (%q) has no key", i+1, entry)
+		}
+		switch {
+		case !hasValue:
+			m[k] = "true"
+		case v == "":
+			m[k] = "unset"
+		default:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("opt.ParseBoolMap: entry %d (%q): invalid value %q for %q", i+1, entry, v, k)
+			}
+			m[k] = Bool(strconv.FormatBool(b))
+		}
+	}
+	return m, nil
+}
+
+// CSVField returns b as a CSV cell: "true", "false", or the empty
+// string if b is unset (or invalid).
+func (b Bool) CSVField() string {
+	if v, ok := b.Get(); ok {
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// BoolFromCSVField parses a CSV cell as written by CSVField. An empty
+// or whitespace-only cell is unset. Other values are parsed with
+// strconv.ParseBool, so the "TRUE" and "FALSE" that spreadsheets write
+// are accepted too.
+func BoolFromCSVField(field string) (Bool, error) {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return "unset", nil
+	}
+	v, err := strconv.ParseBool(field)
+	if err != nil {
+		return "unset", fmt.Errorf("invalid opt.Bool CSV field %q", field)
+	}
+	var b Bool
+	b.Set(v)
+	return b, nil
+}
+
+// LabelValue returns b as a metrics label value: "true", "false", or
+// "unset". It's never empty, and both spellings of unset (as well as
+// invalid values) map to "unset", so a tristate label has exactly
+// three values. It's the same as String, but it's the method metrics
+// exporters should use, as it promises to keep that label set stable.
+func (b Bool) LabelValue() string { return b.String() }
+
+// Value implements database/sql/driver.Valuer, returning a bool, or nil
+// (SQL NULL) if b is unset.
+func (b Bool) Value() (driver.Value, error) {
+	switch b {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "", "unset":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid opt.Bool value %q", string(b))
+}
+
 // EqualBool reports whether b is equal to v.
 // If b is empty or not a valid bool, it reports false.
 func (b Bool) EqualBool(v bool) bool {
@@ -69,12 +409,179 @@ func (b Bool) EqualBool(v bool) bool {
 	return ok && p == v
 }
 
+// IsTrue reports whether b is set to true. It's EqualBool(true), in a
+// form usable in templates, as in {{if .Feature.IsTrue}}.
+func (b Bool) IsTrue() bool { return b == "true" }
+
+// IsFalse reports whether b is set to false. An unset b is neither
+// true nor false.
+func (b Bool) IsFalse() bool { return b == "false" }
+
+// IsSet reports whether b is set, to either true or false.
+func (b Bool) IsSet() bool {
+	_, ok := b.Get()
+	return ok
+}
+
+// Compare returns -1, 0 or +1 as b sorts before, with or after other
+// in the order unset < false < true. As with Get, any backing value
+// other than "true" or "false", including both "" and "unset", is
+// unset, so all unset values compare equal.
+func (b Bool) Compare(other Bool) int {
+	x, y := b.rank(), other.rank()
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
+// rank returns b's position in the Compare order.
+func (b Bool) rank() int {
+	v, ok := b.Get()
+	switch {
+	case !ok:
+		return 0
+	case !v:
+		return 1
+	}
+	return 2
+}
+
+// Coalesce returns the first of vals that's set to true or false, or
+// unset if none is. With vals ordered from highest to lowest
+// precedence, it resolves a setting configured at several layers.
+func Coalesce(vals ...Bool) Bool {
+	for _, v := range vals {
+		if _, ok := v.Get(); ok {
+			return v
+		}
+	}
+	return "unset"
+}
+
+// BoolStats counts the states of a slice of Bools.
+type BoolStats struct {
+	True, False int
+	Unset       int // both "" and "unset"
+	Invalid     int // any other backing value
+}
+
+// Stats returns counts of the states in bs.
+func Stats(bs []Bool) BoolStats {
+	var st BoolStats
+	for _, b := range bs {
+		switch b {
+		case "true":
+			st.True++
+		case "false":
+			st.False++
+		case "", "unset":
+			st.Unset++
+		default:
+			st.Invalid++
+		}
+	}
+	return st
+}
+
 var (
 	trueBytes  = []byte("true")
 	falseBytes = []byte("false")
 	nullBytes  = []byte("null")
 )
 
+// AppendText implements encoding.TextAppender, appending "true",
+// "false", or nothing at all if b is unset.
+func (b Bool) AppendText(buf []byte) ([]byte, error) {
+	switch b {
+	case "true", "false":
+		return append(buf, b...), nil
+	case "", "unset":
+		return buf, nil
+	}
+	return buf, fmt.Errorf("invalid opt.Bool value %q", string(b))
+}
+
+// MarshalText implements encoding.TextMarshaler. See AppendText.
+func (b Bool) MarshalText() ([]byte, error) {
+	return b.AppendText(nil)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting what
+// MarshalText produces: "true", "false", or the empty string for
+// unset. "unset" is also accepted, so String's output round-trips.
+func (b *Bool) UnmarshalText(text []byte) error {
+	switch v := Bool(text); v {
+	case "true", "false", "", "unset":
+		*b = v.Normalize()
+	default:
+		return fmt.Errorf("invalid opt.Bool text %q", text)
+	}
+	return nil
+}
+
+// Binary encodings of a Bool, as a single byte.
+const (
+	binaryUnset byte = iota
+	binaryFalse
+	binaryTrue
+)
+
+// AppendBinary appends the single byte binary encoding of b to buf,
+// for compact wire formats such as netmap deltas.
+func (b Bool) AppendBinary(buf []byte) ([]byte, error) {
+	switch b {
+	case "true":
+		return append(buf, binaryTrue), nil
+	case "false":
+		return append(buf, binaryFalse), nil
+	case "", "unset":
+		return append(buf, binaryUnset), nil
+	}
+	return buf, fmt.Errorf("invalid opt.Bool value %q", string(b))
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. See AppendBinary.
+func (b Bool) MarshalBinary() ([]byte, error) {
+	return b.AppendBinary(make([]byte, 0, 1))
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, decoding the
+// single byte written by AppendBinary.
+func (b *Bool) UnmarshalBinary(data []byte) error {
+	if len(data) != 1 {
+		return fmt.Errorf("invalid opt.Bool binary length %d", len(data))
+	}
+	switch data[0] {
+	case binaryTrue:
+		*b = "true"
+	case binaryFalse:
+		*b = "false"
+	case binaryUnset:
+		*b = "unset"
+	default:
+		return fmt.Errorf("invalid opt.Bool binary value %#x", data[0])
+	}
+	return nil
+}
+
+// HashInto writes a stable, single byte representation of b's logical
+// value to h, for cache keys. Like Equal, it doesn't distinguish the
+// unset (or invalid) backing values, so "" and "unset" hash the same.
+func (b Bool) HashInto(h hash.Hash) {
+	v := binaryUnset
+	if x, ok := b.Get(); ok {
+		v = binaryFalse
+		if x {
+			v = binaryTrue
+		}
+	}
+	h.Write([]byte{v})
+}
+
 func (b Bool) MarshalJSON() ([]byte, error) {
 	switch b {
 	case "true":
@@ -87,7 +594,32 @@ func (b Bool) MarshalJSON() ([]byte, error) {
 	return nil, fmt.Errorf("invalid opt.Bool value %q", string(b))
 }
 
+// maxBoolJSONLen is the length of the longest valid JSON encoding of a
+// Bool, "false".
+const maxBoolJSONLen = len("false")
+
+// maxBoolJSONInput is the most input UnmarshalJSON looks at before
+// trimming whitespace. It's generous, to allow for whitespace around
+// a valid value, but bounds the work done on huge garbage values.
+const maxBoolJSONInput = 64
+
+// UnmarshalJSON implements json.Unmarshaler.
+//
+// encoding/json passes values without surrounding whitespace, but
+// other callers may not, so leading and trailing JSON whitespace is
+// ignored. MarshalJSON never emits any.
 func (b *Bool) UnmarshalJSON(j []byte) error {
+	// Reject anything too long to be valid before doing any work on
+	// it, so huge garbage values (including ones that are mostly
+	// whitespace) fail cheaply, then apply the exact bound once the
+	// whitespace is gone.
+	if len(j) > maxBoolJSONInput {
+		return invalidBoolJSONError(j)
+	}
+	j = bytes.Trim(j, " \t\r\n")
+	if len(j) > maxBoolJSONLen {
+		return invalidBoolJSONError(j)
+	}
 	// Note: written with a bunch of ifs instead of a switch
 	// because I'm sure the Go compiler optimizes away these
 	// []byte->string allocations in an == comparison, but I'm too
@@ -104,5 +636,38 @@ func (b *Bool) UnmarshalJSON(j []byte) error {
 		*b = "unset"
 		return nil
 	}
-	return fmt.Errorf("invalid opt.Bool value %q", j)
+	return invalidBoolJSONError(j)
+}
+
+func invalidBoolJSONError(j []byte) error {
+	return fmt.Errorf("invalid opt.Bool value: got %s %s", jsonKind(j), truncateJSON(j))
+}
+
+// jsonKind returns the kind of JSON value j is ("number", "object",
+// etc), judging by its first byte.
+func jsonKind(j []byte) string {
+	if len(j) == 0 {
+		return "empty input"
+	}
+	switch c := j[0]; {
+	case c == '{':
+		return "object"
+	case c == '[':
+		return "array"
+	case c == '"':
+		return "string"
+	case c == '-' || ('0' <= c && c <= '9'):
+		return "number"
+	}
+	return "token"
+}
+
+// truncateJSON returns j for use in an error message, shortened if
+// it's long.
+func truncateJSON(j []byte) string {
+	const maxLen = 32
+	if len(j) > maxLen {
+		return string(j[:maxLen]) + "..."
+	}
+	return string(j)
 }
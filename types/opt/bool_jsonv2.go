@@ -0,0 +1,60 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build goexperiment.jsonv2
+// +build goexperiment.jsonv2
+
+package opt
+
+import (
+	"encoding/json/jsontext"
+	"fmt"
+)
+
+// This file adds the streaming methods of the experimental
+// encoding/json/v2 package, which is only built with
+// GOEXPERIMENT=jsonv2. They encode the same way as MarshalJSON and
+// UnmarshalJSON, which encoding/json (v1) keeps using, but work on
+// tokens directly, without allocating.
+
+// MarshalJSONTo implements json.MarshalerTo from encoding/json/v2.
+func (b Bool) MarshalJSONTo(enc *jsontext.Encoder) error {
+	switch b {
+	case "true":
+		return enc.WriteToken(jsontext.True)
+	case "false":
+		return enc.WriteToken(jsontext.False)
+	case "", "unset":
+		return enc.WriteToken(jsontext.Null)
+	}
+	return fmt.Errorf("invalid opt.Bool value %q", string(b))
+}
+
+// UnmarshalJSONFrom implements json.UnmarshalerFrom from
+// encoding/json/v2.
+func (b *Bool) UnmarshalJSONFrom(dec *jsontext.Decoder) error {
+	switch dec.PeekKind() {
+	case jsontext.KindTrue, jsontext.KindFalse, jsontext.KindNull:
+		tok, err := dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		switch tok.Kind() {
+		case jsontext.KindTrue:
+			*b = "true"
+		case jsontext.KindFalse:
+			*b = "false"
+		default:
+			*b = "unset"
+		}
+		return nil
+	}
+	// Consume the whole value, so the error can describe it and the
+	// decoder is left at the next one.
+	v, err := dec.ReadValue()
+	if err != nil {
+		return err
+	}
+	return invalidBoolJSONError(v)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build goexperiment.jsonv2
+// +build goexperiment.jsonv2
+
+package opt
+
+import (
+	"bytes"
+	jsonv1 "encoding/json"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"io"
+	"testing"
+)
+
+var (
+	_ json.MarshalerTo     = Bool("")
+	_ json.UnmarshalerFrom = (*Bool)(nil)
+)
+
+func TestBoolJSONv2(t *testing.T) {
+	type S struct {
+		B Bool
+	}
+	tests := []struct {
+		in       Bool
+		want     string
+		wantBack Bool
+	}{
+		{"true", `{"B":true}`, "true"},
+		{"false", `{"B":false}`, "false"},
+		{"", `{"B":null}`, "unset"},
+		{"unset", `{"B":null}`, "unset"},
+	}
+	for _, tt := range tests {
+		j, err := json.Marshal(S{tt.in})
+		if err != nil {
+			t.Fatalf("Marshal(%q): %v", tt.in, err)
+		}
+		if string(j) != tt.want {
+			t.Errorf("Marshal(%q) = %s; want %s", tt.in, j, tt.want)
+		}
+		// It agrees with encoding/json (v1).
+		j1, err := jsonv1.Marshal(S{tt.in})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(j, j1) {
+			t.Errorf("Marshal(%q) = %s; v1 gives %s", tt.in, j, j1)
+		}
+		var back S
+		if err := json.Unmarshal(j, &back); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", j, err)
+		}
+		if back.B != tt.wantBack {
+			t.Errorf("Unmarshal(%s) = %q; want %q", j, back.B, tt.wantBack)
+		}
+	}
+
+	if _, err := json.Marshal(Bool("yes")); err == nil {
+		t.Error("Marshal of invalid value succeeded")
+	}
+	for _, in := range []string{`"true"`, `1`, `{}`, `[true]`} {
+		var b Bool
+		if err := json.Unmarshal([]byte(in), &b); err == nil {
+			t.Errorf("Unmarshal(%s) = %q; want error", in, b)
+		}
+	}
+}
+
+func TestBoolJSONv2Stream(t *testing.T) {
+	// Values are read token by token from a stream, leaving the
+	// decoder positioned after each one.
+	dec := jsontext.NewDecoder(bytes.NewReader([]byte(`true false null {"x":1} true`)))
+	want := []Bool{"true", "false", "unset", "", "true"}
+	for i, w := range want {
+		var b Bool
+		err := json.UnmarshalDecode(dec, &b)
+		if i == 3 {
+			if err == nil {
+				t.Errorf("value %d: got %q; want error", i, b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		if b != w {
+			t.Errorf("value %d = %q; want %q", i, b, w)
+		}
+	}
+}
+
+func TestBoolJSONv2Allocs(t *testing.T) {
+	enc := jsontext.NewEncoder(io.Discard)
+	for _, b := range []Bool{"true", "false", "unset"} {
+		if n := testing.AllocsPerRun(1000, func() {
+			if err := b.MarshalJSONTo(enc); err != nil {
+				t.Fatal(err)
+			}
+		}); n != 0 {
+			t.Errorf("MarshalJSONTo(%q) allocs = %v; want 0", b, n)
+		}
+	}
+
+	in := bytes.Repeat([]byte("true false null "), 1000)
+	dec := jsontext.NewDecoder(bytes.NewReader(in))
+	var b Bool
+	if n := testing.AllocsPerRun(1000, func() {
+		if err := b.UnmarshalJSONFrom(dec); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Errorf("UnmarshalJSONFrom allocs = %v; want 0", n)
+	}
+}
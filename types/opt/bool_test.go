@@ -5,8 +5,16 @@
 package opt
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"os"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -120,3 +128,767 @@ func TestBoolEqualBool(t *testing.T) {
 		}
 	}
 }
+
+func TestBoolPredicates(t *testing.T) {
+	tests := []struct {
+		b                    Bool
+		isTrue, isFalse, set bool
+	}{
+		{"true", true, false, true},
+		{"false", false, true, true},
+		{"", false, false, false},
+		{"unset", false, false, false},
+		{"True", false, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.b.IsTrue(); got != tt.isTrue {
+			t.Errorf("(%q).IsTrue() = %v", string(tt.b), got)
+		}
+		if got := tt.b.IsFalse(); got != tt.isFalse {
+			t.Errorf("(%q).IsFalse() = %v", string(tt.b), got)
+		}
+		if got := tt.b.IsSet(); got != tt.set {
+			t.Errorf("(%q).IsSet() = %v", string(tt.b), got)
+		}
+	}
+}
+
+func TestParseBoolEnv(t *testing.T) {
+	const name = "TS_TEST_OPT_PARSE_BOOL_ENV"
+	tests := []struct {
+		name        string
+		set         bool
+		val         string
+		want        Bool
+		wantPresent bool
+		wantErr     bool
+	}{
+		{name: "absent", set: false, want: "unset", wantPresent: false},
+		{name: "empty", set: true, val: "", want: "unset", wantPresent: true},
+		{name: "true", set: true, val: "true", want: "true", wantPresent: true},
+		{name: "false", set: true, val: "false", want: "false", wantPresent: true},
+		{name: "one", set: true, val: "1", want: "true", wantPresent: true},
+		{name: "garbage", set: true, val: "yes-ish", want: "unset", wantPresent: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.set {
+				t.Setenv(name, tt.val)
+			} else {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			got, present, err := ParseBoolEnv(name)
+			if got != tt.want || present != tt.wantPresent || (err != nil) != tt.wantErr {
+				t.Errorf("ParseBoolEnv = (%q, %v, %v); want (%q, %v, err=%v)", got, present, err, tt.want, tt.wantPresent, tt.wantErr)
+			}
+		})
+	}
+	t.Setenv(name, "yes-ish")
+	if _, _, err := ParseBoolEnv(name); err == nil || err.Error() != `opt.ParseBoolEnv: invalid boolean TS_TEST_OPT_PARSE_BOOL_ENV="yes-ish"` {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestBoolFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		b      Bool
+		want   string
+	}{
+		{"%v", "true", "true"},
+		{"%s", "false", "false"},
+		{"%v", "", "unset"},
+		{"%v", "unset", "unset"},
+		{"%v", "garbage", "unset"},
+		{"%q", "true", `"true"`},
+		{"%8s|", "true", "    true|"},
+		{"%-8s|", "true", "true    |"},
+		{"%-8v|", "", "unset   |"},
+		{"%.2s", "false", "fa"},
+		{"%+v", "", `""`},
+		{"%+v", "unset", `"unset"`},
+		{"%+v", "garbage", `"garbage"`},
+		{"%+-10v|", "true", `"true"    |`},
+		{"%#v", "false", `opt.Bool("false")`},
+		{"%d", "true", "%!d(opt.Bool=true)"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf(tt.format, tt.b); got != tt.want {
+			t.Errorf("Sprintf(%q, %+v) = %q; want %q", tt.format, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBoolValid(t *testing.T) {
+	tests := []struct {
+		b    Bool
+		want bool
+	}{
+		{"true", true},
+		{"false", true},
+		{"", true},
+		{"unset", true},
+		{"True", false},
+		{"1", false},
+		{"null", false},
+		{" ", false},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Valid(); got != tt.want {
+			t.Errorf("(%q).Valid() = %v; want %v", string(tt.b), got, tt.want)
+		}
+	}
+	if n := testing.AllocsPerRun(1000, func() {
+		for _, tt := range tests {
+			tt.b.Valid()
+		}
+	}); n != 0 {
+		t.Errorf("Valid allocated %v times per run; want 0", n)
+	}
+}
+
+func BenchmarkBoolValid(b *testing.B) {
+	bools := []Bool{"true", "false", "", "unset", "garbage"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, v := range bools {
+			v.Valid()
+		}
+	}
+}
+
+func TestBoolAppendText(t *testing.T) {
+	tests := []struct {
+		b       Bool
+		want    string
+		wantErr bool
+	}{
+		{"true", "true", false},
+		{"false", "false", false},
+		{"", "", false},
+		{"unset", "", false},
+		{"garbage", "", true},
+	}
+	for _, tt := range tests {
+		buf := []byte("prefix:")
+		got, err := tt.b.AppendText(buf)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("(%q).AppendText error = %v; want error %v", string(tt.b), err, tt.wantErr)
+			continue
+		}
+		if string(got) != "prefix:"+tt.want {
+			t.Errorf("(%q).AppendText = %q; want %q", string(tt.b), got, "prefix:"+tt.want)
+		}
+		mt, err := tt.b.MarshalText()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("(%q).MarshalText error = %v; want error %v", string(tt.b), err, tt.wantErr)
+		}
+		if string(mt) != tt.want {
+			t.Errorf("(%q).MarshalText = %q; want %q", string(tt.b), mt, tt.want)
+		}
+	}
+}
+
+func TestBoolOmitEmpty(t *testing.T) {
+	type S struct {
+		A Bool `json:",omitempty"`
+		B Bool `json:",omitempty"`
+		C Bool `json:",omitempty"`
+	}
+	var s S
+	s.A.Set(false)
+	s.B = "true"
+	s.B.Clear()
+	s.C = "true"
+	s.C.ClearExplicit()
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"A":false,"C":null}`; string(j) != want {
+		t.Errorf("Marshal = %s; want %s", j, want)
+	}
+
+	// Both clear states are unset.
+	for _, b := range []Bool{s.B, s.C} {
+		if _, ok := b.Get(); ok {
+			t.Errorf("(%q).Get() ok = true; want false", string(b))
+		}
+	}
+
+	// An explicit null survives a round trip; an omitted key stays omitted.
+	var back S
+	if err := json.Unmarshal(j, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != (S{A: "false", C: "unset"}) {
+		t.Errorf("round trip = %+v", back)
+	}
+}
+
+func TestBoolUnsetRoundTrip(t *testing.T) {
+	var fromSQL Bool
+	if err := fromSQL.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON Bool
+	if err := json.Unmarshal([]byte("null"), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if fromSQL != "unset" || fromJSON != "unset" {
+		t.Errorf("decoded unset values = %q (SQL), %q (JSON); want both \"unset\"", string(fromSQL), string(fromJSON))
+	}
+
+	// DB -> struct -> JSON, including through an omitempty field.
+	j, err := json.Marshal(struct {
+		B Bool `json:",omitempty"`
+	}{fromSQL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != `{"B":null}` {
+		t.Errorf("Scan(nil) then Marshal = %s; want {\"B\":null}", j)
+	}
+
+	// JSON -> struct -> DB.
+	v, err := fromJSON.Value()
+	if err != nil || v != nil {
+		t.Errorf("Value of JSON null = %v, %v; want nil, nil", v, err)
+	}
+}
+
+func TestBoolValueScan(t *testing.T) {
+	for _, b := range []Bool{"true", "false", "", "unset"} {
+		v, err := b.Value()
+		if err != nil {
+			t.Fatalf("(%q).Value: %v", string(b), err)
+		}
+		var back Bool
+		if err := back.Scan(v); err != nil {
+			t.Fatalf("Scan(%v): %v", v, err)
+		}
+		if back != b && !(b == "" && back == "unset") {
+			t.Errorf("(%q).Value then Scan = %q", string(b), string(back))
+		}
+	}
+	if _, err := Bool("garbage").Value(); err == nil {
+		t.Error("Value of invalid Bool succeeded")
+	}
+}
+
+func TestBoolScanText(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Bool
+	}{
+		{"true", "true"},
+		{"TRUE", "true"},
+		{"True", "true"},
+		{"tRuE", "true"},
+		{"t", "true"},
+		{"1", "true"},
+		{[]byte("TRUE"), "true"},
+		{"false", "false"},
+		{"FALSE", "false"},
+		{"fAlSe", "false"},
+		{"F", "false"},
+		{"0", "false"},
+		{[]byte("False"), "false"},
+		{"UNKNOWN", "unset"},
+		{"unknown", "unset"},
+		{"Unknown", "unset"},
+		{[]byte("UNKNOWN"), "unset"},
+		{nil, "unset"},
+	}
+	for _, tt := range tests {
+		b := Bool("garbage")
+		if err := b.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if b != tt.want {
+			t.Errorf("Scan(%#v) = %q; want %q", tt.src, string(b), string(tt.want))
+		}
+	}
+	for _, src := range []any{"", "yes", " true", "nil", []byte("2")} {
+		var b Bool
+		if err := b.Scan(src); err == nil {
+			t.Errorf("Scan(%#v) succeeded with %q", src, string(b))
+		}
+	}
+}
+
+func TestScanTriInt(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Bool
+	}{
+		{int64(-1), "unset"},
+		{int64(0), "false"},
+		{int64(1), "true"},
+		{nil, "unset"},
+		{"-1", "unset"},
+		{"0", "false"},
+		{[]byte("1"), "true"},
+	}
+	for _, tt := range tests {
+		b := Bool("garbage")
+		if err := ScanTriInt(&b).Scan(tt.src); err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if b != tt.want {
+			t.Errorf("Scan(%#v) = %q; want %q", tt.src, string(b), string(tt.want))
+		}
+	}
+	for _, src := range []any{int64(2), int64(-2), "2", "", "true", true, 1.0} {
+		b := Bool("false")
+		if err := ScanTriInt(&b).Scan(src); err == nil {
+			t.Errorf("Scan(%#v) succeeded with %q", src, string(b))
+		} else if b != "false" {
+			t.Errorf("failed Scan(%#v) changed value to %q", src, string(b))
+		}
+	}
+}
+
+func TestBoolExplain(t *testing.T) {
+	tests := []struct {
+		b         Bool
+		want      string
+		wantTrue  string // ExplainDefault(true)
+		wantFalse string // ExplainDefault(false)
+	}{
+		{"true", "enabled", "enabled", "enabled"},
+		{"false", "disabled", "disabled", "disabled"},
+		{"", "not set (default applies)", "not set (defaults to enabled)", "not set (defaults to disabled)"},
+		{"unset", "not set (default applies)", "not set (defaults to enabled)", "not set (defaults to disabled)"},
+		{"garbage", "not set (default applies)", "not set (defaults to enabled)", "not set (defaults to disabled)"},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Explain(); got != tt.want {
+			t.Errorf("(%q).Explain() = %q; want %q", string(tt.b), got, tt.want)
+		}
+		if got := tt.b.ExplainDefault(true); got != tt.wantTrue {
+			t.Errorf("(%q).ExplainDefault(true) = %q; want %q", string(tt.b), got, tt.wantTrue)
+		}
+		if got := tt.b.ExplainDefault(false); got != tt.wantFalse {
+			t.Errorf("(%q).ExplainDefault(false) = %q; want %q", string(tt.b), got, tt.wantFalse)
+		}
+	}
+}
+
+func TestParseBoolPrompt(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   Bool
+		wantOK bool
+	}{
+		{"y", "true", true},
+		{"Y", "true", true},
+		{"yes", "true", true},
+		{"YES", "true", true},
+		{"Yes\n", "true", true},
+		{"n", "false", true},
+		{"N", "false", true},
+		{"no", "false", true},
+		{"No", "false", true},
+		{" nO \r\n", "false", true},
+		{"", "unset", true},
+		{"  \n", "unset", true},
+		{"true", "unset", false},
+		{"1", "unset", false},
+		{"yep", "unset", false},
+		{"nope", "unset", false},
+		{"y es", "unset", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseBoolPrompt(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseBoolPrompt(%q) = %q, %v; want %q, %v", tt.in, string(got), ok, string(tt.want), tt.wantOK)
+		}
+	}
+}
+
+func TestBoolCompare(t *testing.T) {
+	order := [][]Bool{{"", "unset", "garbage"}, {"false"}, {"true"}}
+	for i, xs := range order {
+		for j, ys := range order {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			for _, x := range xs {
+				for _, y := range ys {
+					if got := x.Compare(y); got != want {
+						t.Errorf("(%q).Compare(%q) = %v; want %v", string(x), string(y), got, want)
+					}
+				}
+			}
+		}
+	}
+
+	bs := []Bool{"true", "", "false", "unset", "true", "false"}
+	sort.SliceStable(bs, func(i, j int) bool { return bs[i].Compare(bs[j]) < 0 })
+	if want := []Bool{"", "unset", "false", "false", "true", "true"}; !reflect.DeepEqual(bs, want) {
+		t.Errorf("sorted = %#v; want %#v", bs, want)
+	}
+}
+
+func TestParseBoolMap(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]Bool
+		wantErr string
+	}{
+		{in: "", want: map[string]Bool{}},
+		{in: "foo", want: map[string]Bool{"foo": "true"}},
+		{in: "foo=true,bar=false,baz", want: map[string]Bool{"foo": "true", "bar": "false", "baz": "true"}},
+		{in: "foo=1,bar=F,baz=TRUE", want: map[string]Bool{"foo": "true", "bar": "false", "baz": "true"}},
+		{in: "baz=", want: map[string]Bool{"baz": "unset"}},
+		{in: " foo = false , bar ,, baz= ,", want: map[string]Bool{"foo": "false", "bar": "true", "baz": "unset"}},
+		{in: "foo=true,foo=false", want: map[string]Bool{"foo": "false"}},
+		{in: "foo=false,foo", want: map[string]Bool{"foo": "true"}},
+		{in: "foo,=true", wantErr: `opt.ParseBoolMap: entry 2 ("=true") has no key`},
+		{in: "foo=yes", wantErr: `opt.ParseBoolMap: entry 1 ("foo=yes"): invalid value "yes" for "foo"`},
+		{in: "a,b=c=d", wantErr: `opt.ParseBoolMap: entry 2 ("b=c=d"): invalid value "c=d" for "b"`},
+	}
+	for _, tt := range tests {
+		got, err := ParseBoolMap(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseBoolMap(%q) error = %v; want %s", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseBoolMap(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseBoolMap(%q) = %#v; want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []struct {
+		in   []Bool
+		want Bool
+	}{
+		{nil, "unset"},
+		{[]Bool{"", "unset", ""}, "unset"},
+		{[]Bool{"true"}, "true"},
+		{[]Bool{"false", "true"}, "false"},
+		{[]Bool{"true", "false"}, "true"},
+		{[]Bool{"", "unset", "false", "true"}, "false"},
+		{[]Bool{"", "true", "", "false"}, "true"},
+		{[]Bool{"garbage", "", "true"}, "true"},
+		{[]Bool{"", "", "", "false"}, "false"},
+	}
+	for _, tt := range tests {
+		if got := Coalesce(tt.in...); got != tt.want {
+			t.Errorf("Coalesce(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		in   []Bool
+		want BoolStats
+	}{
+		{nil, BoolStats{}},
+		{[]Bool{"true", "true", "false"}, BoolStats{True: 2, False: 1}},
+		{[]Bool{"", "unset", "true", "", "garbage", "false", "TRUE", "unset"}, BoolStats{True: 1, False: 1, Unset: 4, Invalid: 2}},
+	}
+	for _, tt := range tests {
+		if got := Stats(tt.in); got != tt.want {
+			t.Errorf("Stats(%#v) = %+v; want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBoolUnmarshalJSONError(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`3`, "invalid opt.Bool value: got number 3"},
+		{`-1.5e3`, "invalid opt.Bool value: got number -1.5e3"},
+		{`{"a":true}`, `invalid opt.Bool value: got object {"a":true}`},
+		{`[true]`, "invalid opt.Bool value: got array [true]"},
+		{`"true"`, `invalid opt.Bool value: got string "true"`},
+		{`[1,2,3,4,5,6,7,8,9,10,11,12,13,14,15]`, "invalid opt.Bool value: got array [1,2,3,4,5,6,7,8,9,10,11,12,13,1..."},
+	}
+	for _, tt := range tests {
+		var b Bool
+		err := b.UnmarshalJSON([]byte(tt.in))
+		if err == nil {
+			t.Errorf("UnmarshalJSON(%s) succeeded", tt.in)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("UnmarshalJSON(%s) error:\n got: %v\nwant: %v", tt.in, err, tt.want)
+		}
+	}
+
+	// And via encoding/json, where the error is seen in context.
+	var s struct{ B Bool }
+	err := json.Unmarshal([]byte(`{"B":3}`), &s)
+	if err == nil || err.Error() != "invalid opt.Bool value: got number 3" {
+		t.Errorf("json.Unmarshal error = %v", err)
+	}
+}
+
+func TestBoolCSV(t *testing.T) {
+	row := []Bool{"true", "false", "", "unset"}
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	var fields []string
+	for _, b := range row {
+		fields = append(fields, b.CSVField())
+	}
+	if err := w.Write(fields); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if got, want := sb.String(), "true,false,,\n"; got != want {
+		t.Errorf("CSV = %q; want %q", got, want)
+	}
+
+	rec, err := csv.NewReader(strings.NewReader(sb.String())).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bool{"true", "false", "unset", "unset"}
+	for i, field := range rec {
+		got, err := BoolFromCSVField(field)
+		if err != nil {
+			t.Fatalf("BoolFromCSVField(%q): %v", field, err)
+		}
+		if got != want[i] {
+			t.Errorf("field %d: got %q; want %q", i, string(got), string(want[i]))
+		}
+	}
+}
+
+func TestBoolNormalize(t *testing.T) {
+	tests := []struct {
+		in, want Bool
+	}{
+		{"true", "true"},
+		{"false", "false"},
+		{"", "unset"},
+		{"unset", "unset"},
+		{"bogus", "bogus"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Normalize(); got != tt.want {
+			t.Errorf("Bool(%q).Normalize() = %q; want %q", string(tt.in), got, tt.want)
+		}
+		if got := tt.in.Normalize().Normalize(); got != tt.want {
+			t.Errorf("Normalize of %q isn't idempotent: %q", string(tt.in), got)
+		}
+	}
+}
+
+func TestBoolLabelValue(t *testing.T) {
+	tests := []struct {
+		in   Bool
+		want string
+	}{
+		{"true", "true"},
+		{"false", "false"},
+		{"", "unset"},
+		{"unset", "unset"},
+		{"bogus", "unset"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.LabelValue(); got != tt.want {
+			t.Errorf("Bool(%q).LabelValue() = %q; want %q", string(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestBoolFromCSVField(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Bool
+		wantErr bool
+	}{
+		{"true", "true", false},
+		{"false", "false", false},
+		{"TRUE", "true", false},
+		{" false ", "false", false},
+		{"", "unset", false},
+		{"   ", "unset", false},
+		{"\t", "unset", false},
+		{"yes", "unset", true},
+		{"unset", "unset", true},
+	}
+	for _, tt := range tests {
+		got, err := BoolFromCSVField(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("BoolFromCSVField(%q) error = %v; want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("BoolFromCSVField(%q) = %q; want %q", tt.in, string(got), string(tt.want))
+		}
+	}
+}
+
+func TestBoolUnmarshalJSONSpace(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Bool
+	}{
+		{" true ", "true"},
+		{"\ttrue", "true"},
+		{"false\r\n", "false"},
+		{"\n  false\t", "false"},
+		{" null ", "unset"},
+		{"\r\nnull", "unset"},
+	}
+	for _, tt := range tests {
+		var b Bool
+		if err := b.UnmarshalJSON([]byte(tt.in)); err != nil {
+			t.Errorf("UnmarshalJSON(%q): %v", tt.in, err)
+			continue
+		}
+		if b != tt.want {
+			t.Errorf("UnmarshalJSON(%q) = %q; want %q", tt.in, string(b), string(tt.want))
+		}
+	}
+	for _, in := range []string{" tru e ", "t rue", " ", "\u00a0true", "true\v"} {
+		var b Bool
+		if err := b.UnmarshalJSON([]byte(in)); err == nil {
+			t.Errorf("UnmarshalJSON(%q) succeeded with %q", in, string(b))
+		}
+	}
+}
+
+func TestBoolDecoderUseNumber(t *testing.T) {
+	type S struct {
+		T, F, N Bool
+		Count   json.Number
+	}
+	dec := json.NewDecoder(strings.NewReader(`{"T": true, "F": false, "N": null, "Count": 3}`))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	var s S
+	if err := dec.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if want := (S{T: "true", F: "false", N: "unset", Count: "3"}); s != want {
+		t.Errorf("got %+v; want %+v", s, want)
+	}
+
+	// Numbers are still rejected; UseNumber doesn't make them bools.
+	dec = json.NewDecoder(strings.NewReader(`{"T": 1}`))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(new(S)); err == nil {
+		t.Error("decoding number into Bool succeeded")
+	}
+}
+
+func TestBoolUnmarshalJSONHuge(t *testing.T) {
+	junk := []byte(`"` + strings.Repeat("x", 1<<20) + `"`)
+	var b Bool
+	if err := b.UnmarshalJSON(junk); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	const runs = 100
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	allocs := testing.AllocsPerRun(runs, func() {
+		b.UnmarshalJSON(junk)
+	})
+	runtime.ReadMemStats(&after)
+	if allocs > 5 {
+		t.Errorf("UnmarshalJSON of 1MB junk: %v allocs per run; want <= 5", allocs)
+	}
+	// AllocsPerRun does one warm-up run.
+	if perRun := (after.TotalAlloc - before.TotalAlloc) / (runs + 1); perRun > 1<<10 {
+		t.Errorf("UnmarshalJSON of 1MB junk: %v bytes allocated per run; want <= 1KiB", perRun)
+	}
+
+	// Whitespace doesn't get around the bound: the input is rejected
+	// on its length before it's trimmed, however it's padded.
+	padded := []byte(strings.Repeat(" ", 1<<20) + "true")
+	if err := b.UnmarshalJSON(padded); err == nil {
+		t.Error("1MB of whitespace around true: unexpected success")
+	}
+	padded = []byte(strings.Repeat(" ", maxBoolJSONInput-len("true")) + "true")
+	if err := b.UnmarshalJSON(padded); err != nil || b != "true" {
+		t.Errorf("true padded to %d bytes = %q, %v; want true", len(padded), b, err)
+	}
+}
+
+func TestBoolBinary(t *testing.T) {
+	for _, b := range []Bool{"true", "false", "", "unset"} {
+		bin, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%q: %v", b, err)
+		}
+		if len(bin) != 1 {
+			t.Errorf("%q encoded to %d bytes; want 1", b, len(bin))
+		}
+		var got Bool
+		if err := got.UnmarshalBinary(bin); err != nil {
+			t.Fatalf("%q: %v", b, err)
+		}
+		if !got.Equal(b) {
+			t.Errorf("%q round-tripped to %q", b, got)
+		}
+	}
+
+	// AppendBinary appends, for packing several into one buffer.
+	buf, _ := Bool("true").AppendBinary([]byte{0xff})
+	buf, _ = Bool("").AppendBinary(buf)
+	if want := []byte{0xff, 2, 0}; !bytes.Equal(buf, want) {
+		t.Errorf("AppendBinary = %x; want %x", buf, want)
+	}
+
+	if _, err := Bool("maybe").MarshalBinary(); err == nil {
+		t.Error("invalid value encoded")
+	}
+	for _, bad := range [][]byte{nil, {3}, {0xff}, {1, 1}} {
+		var b Bool
+		if err := b.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary(%x) = %q; want error", bad, b)
+		}
+	}
+}
+
+func TestBoolHashInto(t *testing.T) {
+	sum := func(bs ...Bool) string {
+		h := sha256.New()
+		for _, b := range bs {
+			b.HashInto(h)
+		}
+		return fmt.Sprintf("%x", h.Sum(nil))
+	}
+	if sum("") != sum("unset") {
+		t.Error(`"" and "unset" hash differently`)
+	}
+	if sum("maybe") != sum("") {
+		t.Error("invalid value hashes differently from unset")
+	}
+	seen := map[string]Bool{}
+	for _, b := range []Bool{"", "true", "false"} {
+		s := sum(b)
+		if prev, ok := seen[s]; ok {
+			t.Errorf("%q and %q hash the same", prev, b)
+		}
+		seen[s] = b
+	}
+	if sum("true", "") == sum("", "true") {
+		t.Error("order doesn't matter")
+	}
+}
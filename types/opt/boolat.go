@@ -0,0 +1,38 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "time"
+
+// BoolAt is a Bool along with when its logical value last changed, for
+// auditing preferences. It's JSON-encoded as {"value":...,"at":...},
+// where an unset value is null.
+//
+// The zero value is unset, with a zero At.
+type BoolAt struct {
+	Value Bool      `json:"value"`
+	At    time.Time `json:"at"`
+}
+
+// SetAt sets the value to v. If that changes the logical value, At is
+// set to t; setting the value it already has leaves At alone, so At is
+// when it last changed, not when it was last set.
+func (b *BoolAt) SetAt(v bool, t time.Time) {
+	if cur, ok := b.Value.Get(); ok && cur == v {
+		return
+	}
+	b.Value.Set(v)
+	b.At = t
+}
+
+// ClearAt unsets the value. Like SetAt, it only sets At to t if the
+// value was set.
+func (b *BoolAt) ClearAt(t time.Time) {
+	if _, ok := b.Value.Get(); !ok {
+		return
+	}
+	b.Value = "unset"
+	b.At = t
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBoolAt(t *testing.T) {
+	t1 := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+
+	var b BoolAt
+	steps := []struct {
+		name   string
+		do     func()
+		want   Bool
+		wantAt time.Time
+	}{
+		{"set", func() { b.SetAt(true, t1) }, "true", t1},
+		{"same_value", func() { b.SetAt(true, t2) }, "true", t1},
+		{"change", func() { b.SetAt(false, t2) }, "false", t2},
+		{"clear", func() { b.ClearAt(t3) }, "unset", t3},
+		{"clear_again", func() { b.ClearAt(t3.Add(time.Hour)) }, "unset", t3},
+	}
+	for _, s := range steps {
+		s.do()
+		if b.Value != s.want || !b.At.Equal(s.wantAt) {
+			t.Errorf("after %s: got %q at %v; want %q at %v", s.name, string(b.Value), b.At, string(s.want), s.wantAt)
+		}
+	}
+
+	// An explicit "unset" counts as unset.
+	b = BoolAt{Value: "unset", At: t1}
+	b.ClearAt(t2)
+	if !b.At.Equal(t1) {
+		t.Errorf("ClearAt of unset changed At to %v", b.At)
+	}
+}
+
+func TestBoolAtJSON(t *testing.T) {
+	at := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   BoolAt
+		want string
+	}{
+		{BoolAt{Value: "true", At: at}, `{"value":true,"at":"2022-05-01T10:00:00Z"}`},
+		{BoolAt{Value: "false", At: at}, `{"value":false,"at":"2022-05-01T10:00:00Z"}`},
+		{BoolAt{Value: "", At: at}, `{"value":null,"at":"2022-05-01T10:00:00Z"}`},
+		{BoolAt{}, `{"value":null,"at":"0001-01-01T00:00:00Z"}`},
+	}
+	for _, tt := range tests {
+		j, err := json.Marshal(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(j) != tt.want {
+			t.Errorf("Marshal(%+v) = %s; want %s", tt.in, j, tt.want)
+		}
+		var back BoolAt
+		if err := json.Unmarshal(j, &back); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", j, err)
+		}
+		if logical(back.Value) != logical(tt.in.Value) || !back.At.Equal(tt.in.At) {
+			t.Errorf("round trip of %+v = %+v", tt.in, back)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// MarshalBoolMap returns the JSON object encoding of m with its unset
+// entries omitted entirely, rather than encoded as null the way
+// json.Marshal would. Keys are sorted. It's an error for an entry to
+// have an invalid backing value.
+func MarshalBoolMap(m map[string]Bool) ([]byte, error) {
+	return marshalBoolMap("MarshalBoolMap", m, false)
+}
+
+// MarshalBoolMapCanonical returns the canonical JSON object encoding of
+// m, for signing or hashing: the same map contents always produce the
+// same bytes. Keys are sorted by their bytes and encoded as json.Marshal
+// does, and each value is true, false or null, with both spellings of
+// unset encoded as null. There's no whitespace. It's an error for an
+// entry to have an invalid backing value.
+//
+// Unlike MarshalBoolMap, unset entries are kept, so a map with a key
+// explicitly unset hashes differently from one without the key.
+func MarshalBoolMapCanonical(m map[string]Bool) ([]byte, error) {
+	return marshalBoolMap("MarshalBoolMapCanonical", m, true)
+}
+
+func marshalBoolMap(fn string, m map[string]Bool, keepUnset bool) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		if !v.Valid() {
+			return nil, fmt.Errorf("opt.%s: key %q: invalid opt.Bool value %q", fn, k, string(v))
+		}
+		if _, ok := v.Get(); ok || keepUnset {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kj, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kj)
+		buf.WriteByte(':')
+		vj, _ := m[k].MarshalJSON() // can't fail; validated above
+		buf.Write(vj)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MergeBoolJSON merges two JSON objects of booleans and nulls, as in a
+// base config and an override of it, returning the merged object with
+// its keys sorted.
+//
+// Only keys present in override affect the result: a true or false
+// replaces the base value, and a null clears it to unset. Keys absent
+// from override keep their base value. Cleared keys are kept in the
+// result as null, rather than omitted, so that merges compose:
+// merging a and then the result of merging b and c is the same as
+// merging a and b and then c.
+//
+// An empty base is treated as an empty object.
+func MergeBoolJSON(base, override []byte) ([]byte, error) {
+	m := map[string]Bool{}
+	if len(bytes.TrimSpace(base)) > 0 {
+		if err := json.Unmarshal(base, &m); err != nil {
+			return nil, fmt.Errorf("opt.MergeBoolJSON: base: %w", err)
+		}
+	}
+	var o map[string]Bool
+	if err := json.Unmarshal(override, &o); err != nil {
+		return nil, fmt.Errorf("opt.MergeBoolJSON: override: %w", err)
+	}
+	for k, v := range o {
+		m[k] = v
+	}
+	return json.Marshal(m)
+}
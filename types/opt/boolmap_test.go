@@ -0,0 +1,153 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalBoolMap(t *testing.T) {
+	tests := []struct {
+		name string
+		in   map[string]Bool
+		want string
+	}{
+		{"nil", nil, `{}`},
+		{"all_unset", map[string]Bool{"a": "", "b": "unset"}, `{}`},
+		{"sorted", map[string]Bool{"zeta": "true", "alpha": "false", "mid": "true"}, `{"alpha":false,"mid":true,"zeta":true}`},
+		{"omits_unset", map[string]Bool{"on": "true", "gone": "", "null": "unset", "off": "false"}, `{"off":false,"on":true}`},
+		{"escaped_key", map[string]Bool{`a"b`: "true", "<c>": "false"}, `{"\u003cc\u003e":false,"a\"b":true}`}, // escaped like json.Marshal
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalBoolMap(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+			// It's valid JSON that decodes to the set entries.
+			var back map[string]bool
+			if err := json.Unmarshal(got, &back); err != nil {
+				t.Fatalf("invalid JSON %s: %v", got, err)
+			}
+			want := map[string]bool{}
+			for k, v := range tt.in {
+				if b, ok := v.Get(); ok {
+					want[k] = b
+				}
+			}
+			if !reflect.DeepEqual(back, want) {
+				t.Errorf("decoded %v; want %v", back, want)
+			}
+		})
+	}
+
+	if _, err := MarshalBoolMap(map[string]Bool{"ok": "true", "bad": "yes"}); err == nil {
+		t.Error("invalid value succeeded")
+	} else if want := `opt.MarshalBoolMap: key "bad": invalid opt.Bool value "yes"`; err.Error() != want {
+		t.Errorf("error = %q; want %q", err, want)
+	}
+}
+
+func TestMarshalBoolMapCanonical(t *testing.T) {
+	m := map[string]Bool{
+		"zeta":  "true",
+		"alpha": "false",
+		"mid":   "",
+		"Mid":   "unset",
+		"<c>":   "true",
+		"é":     "false",
+	}
+	const golden = `{"\u003cc\u003e":true,"Mid":null,"alpha":false,"mid":null,"zeta":true,"é":false}`
+	first, err := MarshalBoolMapCanonical(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != golden {
+		t.Errorf("got  %s\nwant %s", first, golden)
+	}
+	for i := 0; i < 100; i++ {
+		// Rebuild the map each time so its iteration order varies.
+		m2 := make(map[string]Bool, len(m))
+		for k, v := range m {
+			m2[k] = v
+		}
+		got, err := MarshalBoolMapCanonical(m2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, first) {
+			t.Fatalf("run %d: got %s; want %s", i, got, first)
+		}
+	}
+
+	for _, tt := range []struct {
+		in   map[string]Bool
+		want string
+	}{
+		{nil, `{}`},
+		{map[string]Bool{"a": ""}, `{"a":null}`},
+		{map[string]Bool{"a": "unset"}, `{"a":null}`},
+	} {
+		got, err := MarshalBoolMapCanonical(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("MarshalBoolMapCanonical(%v) = %s; want %s", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := MarshalBoolMapCanonical(map[string]Bool{"bad": "yes"}); err == nil {
+		t.Error("invalid value succeeded")
+	} else if want := `opt.MarshalBoolMapCanonical: key "bad": invalid opt.Bool value "yes"`; err.Error() != want {
+		t.Errorf("error = %q; want %q", err, want)
+	}
+}
+
+func TestMergeBoolJSON(t *testing.T) {
+	tests := []struct {
+		name           string
+		base, override string
+		want           string
+		wantErr        bool
+	}{
+		{"keep_via_absence", `{"a":true,"b":false}`, `{}`, `{"a":true,"b":false}`, false},
+		{"override_set", `{"a":true,"b":false}`, `{"b":true,"c":false}`, `{"a":true,"b":true,"c":false}`, false},
+		{"clear_via_null", `{"a":true,"b":false}`, `{"a":null}`, `{"a":null,"b":false}`, false},
+		{"null_new_key", `{"a":true}`, `{"z":null}`, `{"a":true,"z":null}`, false},
+		{"empty_base", ``, `{"a":false}`, `{"a":false}`, false},
+		{"null_override", `{"a":true}`, `null`, `{"a":true}`, false},
+		{"bad_base", `{"a":1}`, `{}`, ``, true},
+		{"bad_override", `{}`, `{"a":"yes"}`, ``, true},
+		{"override_not_object", `{}`, `[true]`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeBoolJSON([]byte(tt.base), []byte(tt.override))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v; wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Merges compose.
+	a, b, c := []byte(`{"x":true,"y":true}`), []byte(`{"x":null}`), []byte(`{"y":false}`)
+	bc, _ := MergeBoolJSON(b, c)
+	right, _ := MergeBoolJSON(a, bc)
+	ab, _ := MergeBoolJSON(a, b)
+	left, _ := MergeBoolJSON(ab, c)
+	if string(left) != string(right) {
+		t.Errorf("merge doesn't compose: %s != %s", left, right)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// codec is one way of encoding a Bool and decoding it again.
+type codec struct {
+	name      string
+	roundTrip func(Bool) (Bool, error)
+}
+
+var codecs = []codec{
+	{"json", func(b Bool) (Bool, error) {
+		j, err := json.Marshal(b)
+		if err != nil {
+			return "", err
+		}
+		var got Bool
+		err = json.Unmarshal(j, &got)
+		return got, err
+	}},
+	{"text", func(b Bool) (Bool, error) {
+		t, err := b.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		var got Bool
+		err = got.UnmarshalText(t)
+		return got, err
+	}},
+	{"binary", func(b Bool) (Bool, error) {
+		bin, err := b.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		var got Bool
+		err = got.UnmarshalBinary(bin)
+		return got, err
+	}},
+	{"sql", func(b Bool) (Bool, error) {
+		v, err := b.Value()
+		if err != nil {
+			return "", err
+		}
+		var got Bool
+		err = got.Scan(v)
+		return got, err
+	}},
+	{"flag", func(b Bool) (Bool, error) {
+		s := BoolFlag(&b).String()
+		var got Bool
+		err := BoolFlag(&got).Set(s)
+		return got, err
+	}},
+	{"string", func(b Bool) (Bool, error) {
+		var got Bool
+		err := got.UnmarshalText([]byte(b.String()))
+		return got, err
+	}},
+}
+
+// TestCodecsAgree checks that every codec round-trips each logical
+// state, including both spellings of unset, to the same canonical
+// Bool, so that no codec drifts from the others.
+func TestCodecsAgree(t *testing.T) {
+	states := []struct {
+		in   Bool
+		want Bool
+	}{
+		{"true", "true"},
+		{"false", "false"},
+		{"", "unset"},
+		{"unset", "unset"},
+	}
+	for _, c := range codecs {
+		for _, st := range states {
+			got, err := c.roundTrip(st.in)
+			if err != nil {
+				t.Errorf("%s: %q: %v", c.name, st.in, err)
+				continue
+			}
+			if got != st.want {
+				t.Errorf("%s: %q round-tripped to %q; want %q", c.name, st.in, got, st.want)
+			}
+		}
+		if _, err := c.roundTrip("maybe"); err == nil && c.name != "flag" && c.name != "string" {
+			// String (and so the flag) reports invalid values as
+			// unset rather than failing.
+			t.Errorf("%s: invalid value round-tripped without error", c.name)
+		}
+	}
+}
+
+// TestCodecsZeroValue checks that the zero Bool and explicitly
+// cleared ones decode identically, to the canonical spelling, through
+// every codec.
+func TestCodecsZeroValue(t *testing.T) {
+	var zero, cleared, explicit Bool
+	cleared.Set(true)
+	cleared.Clear()
+	explicit.Set(true)
+	explicit.ClearExplicit()
+	for _, c := range codecs {
+		var got []Bool
+		for _, b := range []Bool{zero, cleared, explicit} {
+			v, err := c.roundTrip(b)
+			if err != nil {
+				t.Fatalf("%s: %q: %v", c.name, b, err)
+			}
+			got = append(got, v)
+		}
+		for i, v := range got {
+			if v != zero.Normalize() {
+				t.Errorf("%s: value %d decoded to %q; want %q", c.name, i, v, zero.Normalize())
+			}
+		}
+	}
+}
+
+func TestBoolFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want Bool
+	}{
+		{nil, ""},
+		{[]string{"-x"}, "true"},
+		{[]string{"-x=false"}, "false"},
+		{[]string{"-x=0"}, "false"},
+		{[]string{"-x=true"}, "true"},
+		{[]string{"-x=true", "-x="}, "unset"},
+		{[]string{"-x=unset"}, "unset"},
+	}
+	for _, tt := range tests {
+		var b Bool
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(BoolFlag(&b), "x", "")
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if b != tt.want {
+			t.Errorf("%q: got %q; want %q", tt.args, b, tt.want)
+		}
+	}
+
+	var b Bool
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(BoolFlag(&b), "x", "")
+	if err := fs.Parse([]string{"-x=maybe"}); err == nil {
+		t.Error("invalid value accepted")
+	}
+	fs.PrintDefaults() // mustn't panic on the zero boolFlag
+}
+
+func TestBoolFlagPrintDefaults(t *testing.T) {
+	var unset, on, off Bool
+	on.Set(true)
+	off.Set(false)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(BoolFlag(&unset), "auto", "unset by default")
+	fs.Var(BoolFlag(&on), "on", "true by default")
+	fs.Var(BoolFlag(&off), "off", "false by default")
+	for name, want := range map[string]string{"auto": "unset", "on": "true", "off": "false"} {
+		if got := fs.Lookup(name).DefValue; got != want {
+			t.Errorf("-%s DefValue = %q; want %q", name, got, want)
+		}
+	}
+
+	var sb strings.Builder
+	fs.SetOutput(&sb)
+	fs.PrintDefaults()
+	// Every default is shown, including unset.
+	want := "" +
+		"  -auto\n    \tunset by default (default unset)\n" +
+		"  -off\n    \tfalse by default (default false)\n" +
+		"  -on\n    \ttrue by default (default true)\n"
+	if got := sb.String(); got != want {
+		t.Errorf("PrintDefaults:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "context"
+
+// boolContextKey is the context key type for WithBool. Being
+// unexported, it can't collide with keys from other packages, and any
+// value stored under it is known to be a Bool.
+type boolContextKey string
+
+// WithBool returns a copy of ctx carrying b under the given name, for
+// request-scoped feature overrides.
+func WithBool(ctx context.Context, name string, b Bool) context.Context {
+	return context.WithValue(ctx, boolContextKey(name), b)
+}
+
+// BoolFromContext returns the Bool that WithBool stored in ctx under
+// name (by the nearest such call in the chain), or unset if there
+// isn't one.
+func BoolFromContext(ctx context.Context, name string) Bool {
+	b, ok := ctx.Value(boolContextKey(name)).(Bool)
+	if !ok {
+		return "unset"
+	}
+	return b
+}
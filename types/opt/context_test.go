@@ -0,0 +1,44 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBoolContext(t *testing.T) {
+	type otherKey string
+
+	ctx := context.Background()
+	if got := BoolFromContext(ctx, "a"); got != "unset" {
+		t.Errorf("empty context: got %q; want unset", got)
+	}
+
+	ctx = WithBool(ctx, "a", "true")
+	ctx = context.WithValue(ctx, otherKey("b"), "false") // same name, different key type
+	ctx = WithBool(ctx, "c", "false")
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+	child = WithBool(child, "a", "false") // shadows parent
+
+	tests := []struct {
+		ctx  context.Context
+		name string
+		want Bool
+	}{
+		{ctx, "a", "true"},
+		{ctx, "b", "unset"},
+		{ctx, "c", "false"},
+		{ctx, "missing", "unset"},
+		{child, "a", "false"},
+		{child, "c", "false"},
+	}
+	for _, tt := range tests {
+		if got := BoolFromContext(tt.ctx, tt.name); got != tt.want {
+			t.Errorf("BoolFromContext(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "sort"
+
+// BoolChange is a change to the logical state of one key of a
+// map[string]Bool. Old and New are "true", "false", or "unset".
+type BoolChange struct {
+	Key      string
+	Old, New Bool
+}
+
+// BoolMapDiff is the difference between two map[string]Bool, by key.
+// Each list is sorted by key.
+type BoolMapDiff struct {
+	Added   []BoolChange // unset before, set after
+	Removed []BoolChange // set before, unset after
+	Changed []BoolChange // set before and after, to different values
+}
+
+// IsEmpty reports whether the diff has no changes.
+func (d BoolMapDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffBoolMap returns the changes in logical state from before to
+// after. A missing key, "", "unset" and invalid values are all unset,
+// so moving between them isn't a change.
+func DiffBoolMap(before, after map[string]Bool) BoolMapDiff {
+	keys := make(map[string]bool, len(before)+len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var d BoolMapDiff
+	for _, k := range sorted {
+		c := BoolChange{Key: k, Old: logical(before[k]), New: logical(after[k])}
+		switch {
+		case c.Old == c.New:
+		case !c.Old.IsSet():
+			d.Added = append(d.Added, c)
+		case !c.New.IsSet():
+			d.Removed = append(d.Removed, c)
+		default:
+			d.Changed = append(d.Changed, c)
+		}
+	}
+	return d
+}
+
+// logical returns b as "true", "false", or "unset".
+func logical(b Bool) Bool {
+	if _, ok := b.Get(); ok {
+		return b
+	}
+	return "unset"
+}
+
+// DescribeChange returns a line for a change log describing the change
+// of the setting name from old to new, like "ShieldsUp: enabled →
+// disabled" or "RouteAll: not set → enabled", and whether there was a
+// logical change at all. If there wasn't, including between "" and
+// "unset", it returns "", false.
+func DescribeChange(name string, old, new Bool) (string, bool) {
+	o, n := logical(old), logical(new)
+	if o == n {
+		return "", false
+	}
+	return name + ": " + describeState(o) + " → " + describeState(n), true
+}
+
+func describeState(b Bool) string {
+	if v, ok := b.Get(); ok {
+		return explainBool(v)
+	}
+	return "not set"
+}
+
+// Merge3 merges the changes that local and remote made to a setting
+// since base, the value they last agreed on. A side that changed it
+// (logically) wins over one that didn't, and if both made the same
+// change there's nothing to resolve. If they changed it to different
+// values, conflict is true and result is local's value, to keep if the
+// caller doesn't resolve the conflict some other way. Clearing a
+// setting counts as changing it. The result is "true", "false" or
+// "unset", whatever spelling the inputs used.
+func Merge3(base, local, remote Bool) (result Bool, conflict bool) {
+	b, l, r := logical(base), logical(local), logical(remote)
+	switch {
+	case l == r, r == b:
+		return l, false
+	case l == b:
+		return r, false
+	}
+	return l, true
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffBoolMap(t *testing.T) {
+	before := map[string]Bool{
+		"flip_on":      "false",
+		"flip_off":     "true",
+		"same":         "true",
+		"cleared":      "true",
+		"deleted":      "false",
+		"unset_to_set": "unset",
+		"unset_same":   "",
+		"unset_forms":  "unset",
+	}
+	after := map[string]Bool{
+		"flip_on":      "true",
+		"flip_off":     "false",
+		"same":         "true",
+		"cleared":      "unset",
+		"unset_to_set": "false",
+		"unset_same":   "",
+		"unset_forms":  "",
+		"brand_new":    "true",
+		"new_garbage":  "junk",
+	}
+	got := DiffBoolMap(before, after)
+	want := BoolMapDiff{
+		Added: []BoolChange{
+			{Key: "brand_new", Old: "unset", New: "true"},
+			{Key: "unset_to_set", Old: "unset", New: "false"},
+		},
+		Removed: []BoolChange{
+			{Key: "cleared", Old: "true", New: "unset"},
+			{Key: "deleted", Old: "false", New: "unset"},
+		},
+		Changed: []BoolChange{
+			{Key: "flip_off", Old: "true", New: "false"},
+			{Key: "flip_on", Old: "false", New: "true"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+	if got.IsEmpty() {
+		t.Error("IsEmpty = true")
+	}
+
+	if d := DiffBoolMap(before, before); !d.IsEmpty() {
+		t.Errorf("diff with self = %+v; want empty", d)
+	}
+	if d := DiffBoolMap(nil, map[string]Bool{"x": "unset"}); !d.IsEmpty() {
+		t.Errorf("diff of nil and unset = %+v; want empty", d)
+	}
+}
+
+func TestDescribeChange(t *testing.T) {
+	tests := []struct {
+		old, new Bool
+		want     string
+		wantOK   bool
+	}{
+		{"true", "false", "X: enabled → disabled", true},
+		{"false", "true", "X: disabled → enabled", true},
+		{"", "true", "X: not set → enabled", true},
+		{"unset", "false", "X: not set → disabled", true},
+		{"true", "", "X: enabled → not set", true},
+		{"false", "unset", "X: disabled → not set", true},
+		{"true", "true", "", false},
+		{"false", "false", "", false},
+		{"", "", "", false},
+		{"", "unset", "", false},
+		{"unset", "", "", false},
+		{"bogus", "", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := DescribeChange("X", tt.old, tt.new)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("DescribeChange(X, %q, %q) = %q, %v; want %q, %v", tt.old, tt.new, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name                string
+		base, local, remote Bool
+		want                Bool
+		wantConflict        bool
+	}{
+		{"no_change", "true", "true", "true", "true", false},
+		{"no_change_unset", "", "unset", "", "unset", false},
+		{"local_only", "true", "false", "true", "false", false},
+		{"local_clears", "true", "", "true", "unset", false},
+		{"remote_only", "true", "true", "false", "false", false},
+		{"remote_sets", "", "unset", "true", "true", false},
+		{"same_change", "false", "true", "true", "true", false},
+		{"both_clear", "true", "unset", "", "unset", false},
+		{"conflict", "", "true", "false", "true", true},
+		{"conflict_clear", "true", "false", "", "false", true},
+		{"conflict_clear_local", "false", "unset", "true", "unset", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflict := Merge3(tt.base, tt.local, tt.remote)
+			if got != tt.want || conflict != tt.wantConflict {
+				t.Errorf("Merge3(%q, %q, %q) = %q, %v; want %q, %v", tt.base, tt.local, tt.remote, got, conflict, tt.want, tt.wantConflict)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "fmt"
+
+// BoolFromEnum converts v, a value of a boolean-like enum such as
+// `type Enabled string` with values "enabled" and "disabled", to a
+// Bool by looking it up in values. Values not in the map are unset.
+func BoolFromEnum[T comparable](v T, values map[T]bool) Bool {
+	b, ok := values[v]
+	if !ok {
+		return "unset"
+	}
+	var ret Bool
+	ret.Set(b)
+	return ret
+}
+
+// BoolFromStringer is like BoolFromEnum, but for enums that are
+// fmt.Stringers, such as integer enums, looking up v's String value in
+// values.
+func BoolFromStringer[T fmt.Stringer](v T, values map[string]bool) Bool {
+	return BoolFromEnum(v.String(), values)
+}
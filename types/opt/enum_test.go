@@ -0,0 +1,72 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "testing"
+
+type enabled string
+
+const (
+	enabledOn  enabled = "enabled"
+	enabledOff enabled = "disabled"
+)
+
+var enabledValues = map[enabled]bool{enabledOn: true, enabledOff: false}
+
+type mode int
+
+const (
+	modeOff mode = iota
+	modeOn
+	modeAuto
+)
+
+func (m mode) String() string {
+	switch m {
+	case modeOff:
+		return "off"
+	case modeOn:
+		return "on"
+	case modeAuto:
+		return "auto"
+	}
+	return "unknown"
+}
+
+func TestBoolFromEnum(t *testing.T) {
+	tests := []struct {
+		in   enabled
+		want Bool
+	}{
+		{enabledOn, "true"},
+		{enabledOff, "false"},
+		{"", "unset"},
+		{"Enabled", "unset"}, // case matters
+		{"maybe", "unset"},
+	}
+	for _, tt := range tests {
+		if got := BoolFromEnum(tt.in, enabledValues); got != tt.want {
+			t.Errorf("BoolFromEnum(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBoolFromStringer(t *testing.T) {
+	values := map[string]bool{"on": true, "off": false}
+	tests := []struct {
+		in   mode
+		want Bool
+	}{
+		{modeOn, "true"},
+		{modeOff, "false"},
+		{modeAuto, "unset"},
+		{mode(42), "unset"},
+	}
+	for _, tt := range tests {
+		if got := BoolFromStringer(tt.in, values); got != tt.want {
+			t.Errorf("BoolFromStringer(%v) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
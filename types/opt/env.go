@@ -0,0 +1,98 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// ApplyEnvOverrides overrides the Bool fields of the struct that
+// structPtr points to with the values of environment variables.
+//
+// A field's variable is prefix followed by its name in upper snake
+// case, so with prefix "TS_" the field ShieldsUp is set by
+// TS_SHIELDS_UP. A field tag like `env:"NAME"` replaces the derived
+// name (but not the prefix), and `env:"-"` skips the field. Nested
+// struct fields, and non-nil pointers to them, are walked with their
+// own name added to the prefix, as in TS_NET_ROUTE_ALL. Bools in
+// slices, arrays and maps have no variable and are left alone.
+//
+// Variables are read with ParseBoolEnv: one that's absent leaves its
+// field alone, and one that's set to the empty string clears the field
+// to unset. If any has an invalid value, it returns ParseBoolEnv's
+// error and changes nothing.
+func ApplyEnvOverrides(structPtr any, prefix string) error {
+	rv := reflect.ValueOf(structPtr)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("opt.ApplyEnvOverrides: got %T, want non-nil pointer to struct", structPtr)
+	}
+	type override struct {
+		field reflect.Value
+		value Bool
+	}
+	var overrides []override
+	var err error
+	walkBools(rv, func(path []boolStep, fv reflect.Value) {
+		name, ok := envVarName(prefix, path)
+		if !ok || err != nil {
+			return
+		}
+		var b Bool
+		var present bool
+		b, present, err = ParseBoolEnv(name)
+		if present && err == nil {
+			overrides = append(overrides, override{fv, b})
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, o := range overrides {
+		o.field.Set(reflect.ValueOf(o.value))
+	}
+	return nil
+}
+
+// envVarName returns the environment variable for the Bool at path,
+// and false if it doesn't have one because it's in a slice, array or
+// map, or it or a struct containing it is tagged `env:"-"`.
+func envVarName(prefix string, path []boolStep) (string, bool) {
+	names := make([]string, len(path))
+	for i, s := range path {
+		if s.field == nil {
+			return "", false
+		}
+		name := s.field.Tag.Get("env")
+		switch name {
+		case "-":
+			return "", false
+		case "":
+			name = envName(s.field.Name)
+		}
+		names[i] = name
+	}
+	return prefix + strings.Join(names, "_"), true
+}
+
+// envName returns a Go field name like "RouteAll" or "AllowLANAccess"
+// in upper snake case, like "ROUTE_ALL" or "ALLOW_LAN_ACCESS".
+func envName(field string) string {
+	r := []rune(field)
+	var sb strings.Builder
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) {
+			prev := r[i-1]
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToUpper(c))
+	}
+	return sb.String()
+}
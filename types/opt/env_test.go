@@ -0,0 +1,93 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "testing"
+
+func TestApplyEnvOverrides(t *testing.T) {
+	type netPrefs struct {
+		RouteAll Bool
+	}
+	type prefs struct {
+		ShieldsUp      Bool
+		AllowLANAccess Bool
+		Exit           Bool
+		Kept           Bool
+		Tagged         Bool `env:"CUSTOM"`
+		Skipped        Bool `env:"-"`
+		Net            netPrefs
+		Ptr            *netPrefs
+		Count          int
+		private        Bool
+	}
+	t.Setenv("TS_SHIELDS_UP", "true")
+	t.Setenv("TS_ALLOW_LAN_ACCESS", "0")
+	t.Setenv("TS_EXIT", "") // clears
+	t.Setenv("TS_CUSTOM", "TRUE")
+	t.Setenv("TS_SKIPPED", "true")
+	t.Setenv("TS_NET_ROUTE_ALL", "false")
+	t.Setenv("TS_PTR_ROUTE_ALL", "t")
+	t.Setenv("TS_PRIVATE", "true")
+
+	p := prefs{
+		AllowLANAccess: "true",
+		Exit:           "true",
+		Kept:           "false", // TS_KEPT is absent
+		Ptr:            &netPrefs{},
+	}
+	if err := ApplyEnvOverrides(&p, "TS_"); err != nil {
+		t.Fatal(err)
+	}
+	want := prefs{
+		ShieldsUp:      "true",
+		AllowLANAccess: "false",
+		Exit:           "unset",
+		Kept:           "false",
+		Tagged:         "true",
+		Net:            netPrefs{RouteAll: "false"},
+		Ptr:            p.Ptr,
+	}
+	if p != want {
+		t.Errorf("got %+v\nwant %+v", p, want)
+	}
+	if p.Ptr.RouteAll != "true" {
+		t.Errorf("Ptr.RouteAll = %q; want true", p.Ptr.RouteAll)
+	}
+
+	// An invalid value is an error and changes nothing.
+	t.Setenv("TS_EXIT", "maybe")
+	p2 := prefs{ShieldsUp: "false"}
+	if err := ApplyEnvOverrides(&p2, "TS_"); err == nil {
+		t.Error("invalid value succeeded")
+	} else if want := `opt.ParseBoolEnv: invalid boolean TS_EXIT="maybe"`; err.Error() != want {
+		t.Errorf("error = %q; want %q", err, want)
+	}
+	if p2.ShieldsUp != "false" {
+		t.Errorf("after error, ShieldsUp = %q; want unchanged", p2.ShieldsUp)
+	}
+
+	for _, bad := range []any{nil, p, (*prefs)(nil), new(int)} {
+		if err := ApplyEnvOverrides(bad, "TS_"); err == nil {
+			t.Errorf("ApplyEnvOverrides(%T) succeeded", bad)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"ShieldsUp", "SHIELDS_UP"},
+		{"RouteAll", "ROUTE_ALL"},
+		{"AllowLANAccess", "ALLOW_LAN_ACCESS"},
+		{"SSH", "SSH"},
+		{"Exit", "EXIT"},
+		{"Use4via6", "USE4VIA6"},
+		{"IPv6Only", "I_PV6_ONLY"}, // needs an env tag
+	}
+	for _, tt := range tests {
+		if got := envName(tt.in); got != tt.want {
+			t.Errorf("envName(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "reflect"
+
+// Equaler is implemented by types that know when two of their values
+// mean the same thing even if they're represented differently, such
+// as a Bool backed by "" and one backed by "unset".
+type Equaler interface {
+	// Equal reports whether other is of the same type and logically
+	// equal to the receiver.
+	Equal(other any) bool
+}
+
+var (
+	_ Equaler = Bool("")
+	_ Equaler = Ratio{}
+	_ Equaler = List[int]{}
+	_ Equaler = Set[int]{}
+	_ Equaler = PatchBool{}
+)
+
+// Equal reports whether a and b are logically equal: using a's Equal
+// method if it's an Equaler, else b's, and otherwise
+// reflect.DeepEqual.
+func Equal(a, b any) bool {
+	if e, ok := a.(Equaler); ok {
+		return e.Equal(b)
+	}
+	if e, ok := b.(Equaler); ok {
+		return e.Equal(a)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Equal reports whether other is a Bool with the same logical value as
+// b. All unset (and invalid) values are equal to each other.
+func (b Bool) Equal(other any) bool {
+	o, ok := other.(Bool)
+	return ok && logical(b) == logical(o)
+}
+
+// Equal reports whether other is a Ratio that's unset like r, or set to
+// the same value.
+func (r Ratio) Equal(other any) bool {
+	o, ok := other.(Ratio)
+	return ok && r.ok == o.ok && (!r.ok || r.v == o.v)
+}
+
+// Equal reports whether other is a List that's unset like l, or set to
+// elements that are pairwise Equal. A nil and an empty set list are
+// equal.
+func (l List[T]) Equal(other any) bool {
+	o, ok := other.(List[T])
+	if !ok || l.ok != o.ok || len(l.s) != len(o.s) {
+		return false
+	}
+	for i := range l.s {
+		if !Equal(l.s[i], o.s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether other is a Set that's unset like s, or set to
+// the same elements.
+func (s Set[T]) Equal(other any) bool {
+	o, ok := other.(Set[T])
+	if !ok || s.ok != o.ok || len(s.m) != len(o.m) {
+		return false
+	}
+	for e := range s.m {
+		if !o.Contains(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether other is a PatchBool that's absent like p, or
+// present with a logically equal value.
+func (p PatchBool) Equal(other any) bool {
+	o, ok := other.(PatchBool)
+	return ok && p.present == o.present && p.b.Equal(o.b)
+}
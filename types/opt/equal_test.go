@@ -0,0 +1,51 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	var unsetPatch PatchBool
+	if err := unsetPatch.UnmarshalJSON([]byte("null")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		a, b any
+		want bool
+	}{
+		{"bool_unset_spellings", Bool(""), Bool("unset"), true},
+		{"bool_unset_invalid", Bool("unset"), Bool("maybe"), true},
+		{"bool_true", Bool("true"), Bool("true"), true},
+		{"bool_true_false", Bool("true"), Bool("false"), false},
+		{"bool_false_unset", Bool("false"), Bool(""), false},
+		{"bool_vs_string", Bool("true"), "true", false},
+		{"string_vs_bool", "true", Bool("true"), false},
+		{"ratio_unset", Ratio{}, Ratio{}, true},
+		{"ratio_set", Clamp(0.5), Clamp(0.5), true},
+		{"ratio_set_unset", Clamp(0), Ratio{}, false},
+		{"list_unset_empty", List[int]{}, ListOf[int](), false},
+		{"list_nil_empty", ListOf[int](), ListOf([]int{}...), true},
+		{"list_elems", ListOf[Bool]("", "true"), ListOf[Bool]("unset", "true"), true},
+		{"list_differ", ListOf(1, 2), ListOf(1, 3), false},
+		{"set_order", SetOf("a", "b"), SetOf("b", "a"), true},
+		{"set_differ", SetOf("a"), SetOf("a", "b"), false},
+		{"set_unset_empty", Set[string]{}, SetOf[string](), false},
+		{"patch_absent", PatchBool{}, PatchBool{}, true},
+		{"patch_null_vs_absent", unsetPatch, PatchBool{}, false},
+		{"not_equalers", []int{1}, []int{1}, true},
+		{"not_equalers_differ", map[string]int{"a": 1}, map[string]int{"a": 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Equal(tt.a, tt.b); got != tt.want {
+				t.Errorf("Equal(%v, %v) = %v; want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := Equal(tt.b, tt.a); got != tt.want {
+				t.Errorf("Equal(%v, %v) = %v; want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
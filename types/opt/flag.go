@@ -0,0 +1,49 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"flag"
+	"strconv"
+)
+
+// BoolFlag returns a flag.Value that stores its value in b, for use
+// with flag.Var. Like a flag.Bool, a bare --name sets it to true, but
+// the flag is unset unless given, and --name= or --name=unset sets it
+// back to unset explicitly.
+//
+// The value's String is "true", "false" or "unset", never empty, so
+// it's readable as a flag's DefValue, and flag.PrintDefaults shows
+// every default, including "(default unset)".
+func BoolFlag(b *Bool) flag.Value {
+	return boolFlag{b}
+}
+
+type boolFlag struct{ b *Bool }
+
+func (f boolFlag) IsBoolFlag() bool { return true }
+
+func (f boolFlag) String() string {
+	if f.b == nil {
+		// The zero value, which flag.PrintDefaults compares DefValue
+		// with to decide whether a default is worth showing. It
+		// mustn't match any real value, so that unset is shown too.
+		return ""
+	}
+	return f.b.String()
+}
+
+func (f boolFlag) Set(s string) error {
+	if s == "" || s == "unset" {
+		*f.b = "unset"
+		return nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	f.b.Set(v)
+	return nil
+}
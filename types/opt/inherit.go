@@ -0,0 +1,67 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// InheritBool is like Bool, but with a fourth state, "inherit", for
+// hierarchical settings such as policies that can explicitly defer to
+// the value set by their parent. The string is "true", "false",
+// "inherit", or the empty string (or "unset") to mean unset.
+//
+// It's a separate type rather than another Bool value so that code
+// handling Bools never has to consider "inherit".
+//
+// It's JSON-encoded as true, false, the string "inherit" or null.
+type InheritBool string
+
+// Get returns the value of b if it's set to true or false.
+func (b InheritBool) Get() (v bool, ok bool) {
+	return Bool(b).Get()
+}
+
+// IsInherit reports whether b is "inherit".
+func (b InheritBool) IsInherit() bool { return b == "inherit" }
+
+// Resolve returns the value of b if it's set to true or false, and
+// otherwise parent, the value it would inherit.
+//
+// Unset and "inherit" resolve the same way. The difference is in
+// intent: "inherit" is an explicit choice to follow the parent, which
+// should be kept, while unset is merely the absence of a choice, which
+// a default or later configuration may fill in.
+func (b InheritBool) Resolve(parent bool) bool {
+	if v, ok := b.Get(); ok {
+		return v
+	}
+	return parent
+}
+
+func (b InheritBool) MarshalJSON() ([]byte, error) {
+	if b.IsInherit() {
+		return []byte(`"inherit"`), nil
+	}
+	j, err := Bool(b).MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("invalid opt.InheritBool value %q", string(b))
+	}
+	return j, nil
+}
+
+func (b *InheritBool) UnmarshalJSON(j []byte) error {
+	if string(bytes.Trim(j, " \t\r\n")) == `"inherit"` {
+		*b = "inherit"
+		return nil
+	}
+	var v Bool
+	if err := v.UnmarshalJSON(j); err != nil {
+		return fmt.Errorf("invalid opt.InheritBool value: got %s %s", jsonKind(j), truncateJSON(j))
+	}
+	*b = InheritBool(v)
+	return nil
+}
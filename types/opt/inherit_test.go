@@ -0,0 +1,76 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInheritBoolResolve(t *testing.T) {
+	tests := []struct {
+		b      InheritBool
+		parent bool
+		want   bool
+	}{
+		{"true", false, true},
+		{"false", true, false},
+		{"inherit", true, true},
+		{"inherit", false, false},
+		{"", true, true},
+		{"unset", false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Resolve(tt.parent); got != tt.want {
+			t.Errorf("(%q).Resolve(%v) = %v; want %v", tt.b, tt.parent, got, tt.want)
+		}
+	}
+	if !InheritBool("inherit").IsInherit() || InheritBool("").IsInherit() {
+		t.Error("IsInherit wrong")
+	}
+}
+
+func TestInheritBoolJSON(t *testing.T) {
+	type S struct {
+		B InheritBool
+	}
+	tests := []struct {
+		in       InheritBool
+		want     string
+		wantBack InheritBool
+	}{
+		{"true", `{"B":true}`, "true"},
+		{"false", `{"B":false}`, "false"},
+		{"inherit", `{"B":"inherit"}`, "inherit"},
+		{"", `{"B":null}`, "unset"},
+		{"unset", `{"B":null}`, "unset"},
+	}
+	for _, tt := range tests {
+		j, err := json.Marshal(S{tt.in})
+		if err != nil {
+			t.Fatalf("Marshal(%q): %v", tt.in, err)
+		}
+		if string(j) != tt.want {
+			t.Errorf("Marshal(%q) = %s; want %s", tt.in, j, tt.want)
+		}
+		var back S
+		if err := json.Unmarshal(j, &back); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", j, err)
+		}
+		if back.B != tt.wantBack {
+			t.Errorf("Unmarshal(%s) = %q; want %q", j, back.B, tt.wantBack)
+		}
+	}
+
+	if _, err := json.Marshal(InheritBool("yes")); err == nil {
+		t.Error("Marshal of invalid value succeeded")
+	}
+	for _, in := range []string{`"true"`, `"Inherit"`, `"unset"`, `1`, `{}`} {
+		var b InheritBool
+		if err := json.Unmarshal([]byte(in), &b); err == nil {
+			t.Errorf("Unmarshal(%s) = %q; want error", in, b)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package optreflect contains reflection helpers shared by the
+// packages under types/opt that work on structs of opt.Bool fields.
+package optreflect
+
+import (
+	"reflect"
+	"strings"
+
+	"tailscale.com/types/opt"
+)
+
+// BoolType is the reflect.Type of opt.Bool.
+var BoolType = reflect.TypeOf(opt.Bool(""))
+
+// IsStructPtr reports whether v is a non-nil pointer to a struct.
+func IsStructPtr(v reflect.Value) bool {
+	return v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct
+}
+
+// JSONName returns the name given to f by its json struct tag, or the
+// empty string if the tag doesn't rename it.
+func JSONName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
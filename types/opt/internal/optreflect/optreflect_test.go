@@ -0,0 +1,51 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optreflect
+
+import (
+	"reflect"
+	"testing"
+
+	"tailscale.com/types/opt"
+)
+
+func TestIsStructPtr(t *testing.T) {
+	type s struct{ B opt.Bool }
+	tests := []struct {
+		v    any
+		want bool
+	}{
+		{&s{}, true},
+		{(*s)(nil), false},
+		{s{}, false},
+		{new(int), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsStructPtr(reflect.ValueOf(tt.v)); got != tt.want {
+			t.Errorf("IsStructPtr(%#v) = %v; want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestJSONName(t *testing.T) {
+	type s struct {
+		Plain   opt.Bool
+		Renamed opt.Bool `json:"renamed,omitempty"`
+		Opts    opt.Bool `json:",omitempty"`
+		Skipped opt.Bool `json:"-"`
+	}
+	want := map[string]string{"Plain": "", "Renamed": "renamed", "Opts": "", "Skipped": ""}
+	st := reflect.TypeOf(s{})
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if f.Type != BoolType {
+			t.Errorf("%s has type %v; want BoolType", f.Name, f.Type)
+		}
+		if got := JSONName(f); got != want[f.Name] {
+			t.Errorf("JSONName(%s) = %q; want %q", f.Name, got, want[f.Name])
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+)
+
+// List is an optional slice to be JSON-encoded. Unlike a plain slice,
+// it distinguishes an unset list (JSON null), a list explicitly set to
+// empty (JSON []) and a populated list.
+//
+// The zero value is unset.
+type List[T any] struct {
+	s  []T
+	ok bool
+}
+
+// ListOf returns a set List of the provided elements. With no
+// arguments it returns a set, empty List.
+func ListOf[T any](s ...T) List[T] {
+	return List[T]{s: s, ok: true}
+}
+
+// Set sets l to s. A nil s sets l to the empty list, not unset.
+func (l *List[T]) Set(s []T) { *l = List[T]{s: s, ok: true} }
+
+func (l *List[T]) Clear() { *l = List[T]{} }
+
+// Get returns the list's elements and whether it's set.
+func (l List[T]) Get() (s []T, ok bool) { return l.s, l.ok }
+
+// Append appends elems to l, setting l if it was unset.
+func (l *List[T]) Append(elems ...T) {
+	l.s = append(l.s, elems...)
+	l.ok = true
+}
+
+func (l List[T]) MarshalJSON() ([]byte, error) {
+	if !l.ok {
+		return nullBytes, nil
+	}
+	if len(l.s) == 0 {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(l.s)
+}
+
+func (l *List[T]) UnmarshalJSON(j []byte) error {
+	if string(j) == "null" {
+		*l = List[T]{}
+		return nil
+	}
+	var s []T
+	if err := json.Unmarshal(j, &s); err != nil {
+		return err
+	}
+	*l = List[T]{s: s, ok: true}
+	return nil
+}
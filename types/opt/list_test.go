@@ -0,0 +1,85 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	type S struct {
+		L List[string]
+	}
+	tests := []struct {
+		name    string
+		in      S
+		want    string // JSON
+		wantOK  bool
+		wantLen int
+	}{
+		{name: "unset", in: S{}, want: `{"L":null}`},
+		{name: "nil_set", in: S{L: List[string]{ok: true}}, want: `{"L":[]}`, wantOK: true},
+		{name: "empty", in: S{L: ListOf[string]()}, want: `{"L":[]}`, wantOK: true},
+		{name: "populated", in: S{L: ListOf("a", "b")}, want: `{"L":["a","b"]}`, wantOK: true, wantLen: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := json.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(j) != tt.want {
+				t.Errorf("wrong JSON:\n got: %s\nwant: %s\n", j, tt.want)
+			}
+			var back S
+			if err := json.Unmarshal(j, &back); err != nil {
+				t.Fatalf("Unmarshal %#q: %v", j, err)
+			}
+			s, ok := back.L.Get()
+			if ok != tt.wantOK || len(s) != tt.wantLen {
+				t.Errorf("Get after round trip = %q, %v; want len %d, %v", s, ok, tt.wantLen, tt.wantOK)
+			}
+			want, _ := tt.in.L.Get()
+			if tt.wantLen > 0 && !reflect.DeepEqual(s, want) {
+				t.Errorf("elements = %q; want %q", s, want)
+			}
+		})
+	}
+}
+
+func TestListAbsent(t *testing.T) {
+	var s struct{ L List[int] }
+	if err := json.Unmarshal([]byte(`{}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.L.Get(); ok {
+		t.Error("absent field is set")
+	}
+	if err := json.Unmarshal([]byte(`{"L":"nope"}`), &s); err == nil {
+		t.Error("unexpected success unmarshaling string into List[int]")
+	}
+}
+
+func TestListAppend(t *testing.T) {
+	var l List[int]
+	if _, ok := l.Get(); ok {
+		t.Fatal("zero List is set")
+	}
+	l.Append()
+	if s, ok := l.Get(); !ok || len(s) != 0 {
+		t.Errorf("after empty Append, Get = %v, %v; want [], true", s, ok)
+	}
+	l.Append(1, 2)
+	l.Append(3)
+	if s, ok := l.Get(); !ok || !reflect.DeepEqual(s, []int{1, 2, 3}) {
+		t.Errorf("after Appends, Get = %v, %v; want [1 2 3], true", s, ok)
+	}
+	l.Clear()
+	if _, ok := l.Get(); ok {
+		t.Error("set after Clear")
+	}
+}
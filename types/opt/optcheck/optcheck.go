@@ -0,0 +1,75 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package optcheck validates that required opt.Bool fields of a struct
+// have been set.
+package optcheck
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"tailscale.com/types/opt"
+	"tailscale.com/types/opt/internal/optreflect"
+)
+
+// Missing returns the names of the opt.Bool fields of v that are
+// tagged `opt:"required"` but unset, in field order. v must be a
+// struct or a non-nil pointer to one.
+//
+// Exported struct fields (and non-nil pointers to structs) are checked
+// recursively, with their fields reported as dot-separated paths such
+// as "Prefs.RouteAll". A pointer back to a struct that's already being
+// checked, as in a cyclic structure, isn't followed again.
+func Missing(v any) ([]string, error) {
+	rv := reflect.ValueOf(v)
+	active := map[any]bool{}
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		active[v] = true
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("optcheck: %T is not a struct or non-nil pointer to one", v)
+	}
+	return missing(nil, rv, "", active), nil
+}
+
+// missing appends the required but unset fields of v to dst. active
+// holds the struct pointers on the path to v, to stop at cycles.
+func missing(dst []string, v reflect.Value, prefix string, active map[any]bool) []string {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		switch {
+		case f.Type == optreflect.BoolType:
+			if _, ok := fv.Interface().(opt.Bool).Get(); !ok && isRequired(f) {
+				dst = append(dst, prefix+f.Name)
+			}
+		case f.Type.Kind() == reflect.Struct:
+			dst = missing(dst, fv, prefix+f.Name+".", active)
+		case f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct && !fv.IsNil():
+			if p := fv.Interface(); !active[p] {
+				active[p] = true
+				dst = missing(dst, fv.Elem(), prefix+f.Name+".", active)
+				delete(active, p)
+			}
+		}
+	}
+	return dst
+}
+
+// isRequired reports whether f's opt struct tag includes "required".
+func isRequired(f reflect.StructField) bool {
+	for _, o := range strings.Split(f.Tag.Get("opt"), ",") {
+		if o == "required" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optcheck
+
+import (
+	"reflect"
+	"testing"
+
+	"tailscale.com/types/opt"
+)
+
+type sub struct {
+	Enabled opt.Bool `opt:"required"`
+	Extra   opt.Bool
+}
+
+type config struct {
+	RouteAll  opt.Bool `opt:"required"`
+	ShieldsUp opt.Bool `json:"shields,omitempty" opt:"required"`
+	Optional  opt.Bool
+	Name      string `opt:"required"` // not an opt.Bool; ignored
+	Sub       sub
+	SubPtr    *sub
+	private   opt.Bool `opt:"required"`
+}
+
+func TestMissing(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want []string
+	}{
+		{
+			name: "zero",
+			in:   config{},
+			want: []string{"RouteAll", "ShieldsUp", "Sub.Enabled"},
+		},
+		{
+			name: "all_set",
+			in: &config{
+				RouteAll:  "false",
+				ShieldsUp: "true",
+				Sub:       sub{Enabled: "true"},
+				SubPtr:    &sub{Enabled: "false"},
+			},
+		},
+		{
+			name: "explicitly_unset",
+			in: &config{
+				RouteAll:  "unset",
+				ShieldsUp: "true",
+				Optional:  "unset",
+				Sub:       sub{Enabled: "false", Extra: ""},
+			},
+			want: []string{"RouteAll"},
+		},
+		{
+			name: "nested_pointer",
+			in: &config{
+				RouteAll:  "true",
+				ShieldsUp: "true",
+				Sub:       sub{Enabled: "true"},
+				SubPtr:    &sub{Extra: "true"},
+			},
+			want: []string{"SubPtr.Enabled"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Missing(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []any{nil, 1, (*config)(nil)} {
+		if _, err := Missing(bad); err == nil {
+			t.Errorf("Missing(%#v) succeeded", bad)
+		}
+	}
+}
+
+func TestMissingCycle(t *testing.T) {
+	type node struct {
+		On   opt.Bool `opt:"required"`
+		Next *node
+		Peer *node
+	}
+	a := &node{}
+	b := &node{On: "true", Next: a}
+	a.Next = a // self cycle
+	a.Peer = b // and a longer one, back through b.Next
+	got, err := Missing(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"On"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
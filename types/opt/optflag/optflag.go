@@ -0,0 +1,59 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package optflag provides a container of tristate feature flags.
+package optflag
+
+import (
+	"encoding/json"
+
+	"tailscale.com/types/opt"
+)
+
+// FlagSet is a set of named feature flags, each of which may be true,
+// false or unset. It's JSON-encoded as an object whose values are
+// booleans or null.
+type FlagSet map[string]opt.Bool
+
+// Get returns the value of the named flag and whether it's set.
+func (s FlagSet) Get(name string) (v bool, ok bool) {
+	return s[name].Get()
+}
+
+// SetFrom sets the flags in m, leaving others alone.
+func (s *FlagSet) SetFrom(m map[string]bool) {
+	if *s == nil && len(m) > 0 {
+		*s = make(FlagSet, len(m))
+	}
+	for name, v := range m {
+		var b opt.Bool
+		b.Set(v)
+		(*s)[name] = b
+	}
+}
+
+// Merge overlays o onto s. Flags that are set in o replace those in
+// s; flags that are unset in o leave s's value alone.
+func (s *FlagSet) Merge(o FlagSet) {
+	for name, b := range o {
+		if _, ok := b.Get(); !ok {
+			continue
+		}
+		if *s == nil {
+			*s = make(FlagSet)
+		}
+		(*s)[name] = b
+	}
+}
+
+// MergeJSON overlays the JSON-encoded FlagSet j onto s, as in Merge.
+// A flag whose value is null in j passes through s's existing value.
+func (s *FlagSet) MergeJSON(j []byte) error {
+	var o FlagSet
+	if err := json.Unmarshal(j, &o); err != nil {
+		return err
+	}
+	s.Merge(o)
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optflag
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFlagSetGet(t *testing.T) {
+	var s FlagSet
+	if _, ok := s.Get("foo"); ok {
+		t.Error("nil FlagSet has foo set")
+	}
+	s.SetFrom(map[string]bool{"foo": true, "bar": false})
+	if v, ok := s.Get("foo"); !v || !ok {
+		t.Errorf("foo = %v, %v; want true, true", v, ok)
+	}
+	if v, ok := s.Get("bar"); v || !ok {
+		t.Errorf("bar = %v, %v; want false, true", v, ok)
+	}
+	if _, ok := s.Get("baz"); ok {
+		t.Error("baz is set")
+	}
+}
+
+func TestFlagSetMergeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    FlagSet
+		overlay string
+		want    FlagSet
+	}{
+		{
+			name:    "set_replaces",
+			base:    FlagSet{"a": "true", "b": "false"},
+			overlay: `{"a":false}`,
+			want:    FlagSet{"a": "false", "b": "false"},
+		},
+		{
+			name:    "null_passes_through",
+			base:    FlagSet{"a": "true"},
+			overlay: `{"a":null,"b":null}`,
+			want:    FlagSet{"a": "true"},
+		},
+		{
+			name:    "adds_new",
+			base:    FlagSet{"a": "true"},
+			overlay: `{"b":true}`,
+			want:    FlagSet{"a": "true", "b": "true"},
+		},
+		{
+			name:    "nil_base",
+			overlay: `{"a":true,"b":null}`,
+			want:    FlagSet{"a": "true"},
+		},
+		{
+			name:    "empty_overlay",
+			base:    FlagSet{"a": "false"},
+			overlay: `{}`,
+			want:    FlagSet{"a": "false"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.base
+			if err := s.MergeJSON([]byte(tt.overlay)); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s, tt.want) {
+				t.Errorf("got %v; want %v", s, tt.want)
+			}
+		})
+	}
+
+	var s FlagSet
+	if err := s.MergeJSON([]byte(`{"a":"yes"}`)); err == nil {
+		t.Error("MergeJSON of non-boolean succeeded")
+	}
+}
+
+func TestFlagSetJSON(t *testing.T) {
+	in := FlagSet{"a": "true", "b": "false", "c": ""}
+	j, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":true,"b":false,"c":null}`; string(j) != want {
+		t.Errorf("Marshal = %s; want %s", j, want)
+	}
+	var back FlagSet
+	if err := json.Unmarshal(j, &back); err != nil {
+		t.Fatal(err)
+	}
+	want := FlagSet{"a": "true", "b": "false", "c": "unset"}
+	if !reflect.DeepEqual(back, want) {
+		t.Errorf("round trip = %v; want %v", back, want)
+	}
+}
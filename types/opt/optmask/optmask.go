@@ -0,0 +1,108 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package optmask applies field-mask partial updates, in the style of
+// protobuf's FieldMask, to structs of opt.Bool fields.
+package optmask
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"tailscale.com/types/opt/internal/optreflect"
+)
+
+// Apply copies the opt.Bool fields named by paths from the struct
+// pointed to by src to the struct pointed to by dst, which must be of
+// the same type. Fields not named by paths are left alone, so a masked
+// field that's unset in src clears it in dst.
+//
+// Each path is a dot-separated list of field names, such as
+// "prefs.route_all". A name matches a field's Go name or JSON name,
+// ignoring case and underscores, so both "route_all" and "RouteAll"
+// match a RouteAll field. A path naming a struct field applies to
+// every opt.Bool field within it.
+//
+// It returns an error, without modifying dst, if dst or src isn't a
+// non-nil pointer to a struct of the same type, or if a path doesn't
+// name an opt.Bool or struct field.
+func Apply(dst, src any, paths []string) error {
+	dv, sv := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if !optreflect.IsStructPtr(dv) {
+		return fmt.Errorf("optmask: dst is %T, not a non-nil pointer to a struct", dst)
+	}
+	if !optreflect.IsStructPtr(sv) || sv.Type() != dv.Type() {
+		return fmt.Errorf("optmask: src is %T, not a non-nil %v", src, dv.Type())
+	}
+	indexes := make([][]int, len(paths))
+	for i, p := range paths {
+		idx, err := resolve(dv.Type().Elem(), p)
+		if err != nil {
+			return err
+		}
+		indexes[i] = idx
+	}
+	for _, idx := range indexes {
+		copyBools(dv.Elem().FieldByIndex(idx), sv.Elem().FieldByIndex(idx))
+	}
+	return nil
+}
+
+// resolve returns the field index sequence of path within t.
+func resolve(t reflect.Type, path string) ([]int, error) {
+	var idx []int
+	for _, name := range strings.Split(path, ".") {
+		f, ok := fieldByMaskName(t, name)
+		if !ok {
+			return nil, fmt.Errorf("optmask: path %q: %v has no field %q", path, t, name)
+		}
+		idx = append(idx, f.Index...)
+		t = f.Type
+	}
+	if t != optreflect.BoolType && t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("optmask: path %q is %v, not opt.Bool", path, t)
+	}
+	return idx, nil
+}
+
+// fieldByMaskName returns the exported field of t that name refers
+// to.
+func fieldByMaskName(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	want := normalize(name)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if normalize(f.Name) == want || normalize(optreflect.JSONName(f)) == want {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// copyBools copies src to dst if they're opt.Bools, or else copies
+// each opt.Bool field within the structs dst and src.
+func copyBools(dst, src reflect.Value) {
+	if dst.Type() == optreflect.BoolType {
+		dst.Set(src)
+		return
+	}
+	if dst.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Type().Field(i).IsExported() {
+			copyBools(dst.Field(i), src.Field(i))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optmask
+
+import (
+	"strings"
+	"testing"
+
+	"tailscale.com/types/opt"
+)
+
+type prefs struct {
+	RouteAll  opt.Bool `json:"routeAll"`
+	ShieldsUp opt.Bool
+	Exit      opt.Bool
+	Name      string
+	SSH       struct {
+		Enabled opt.Bool
+		Check   opt.Bool
+	}
+}
+
+func TestApply(t *testing.T) {
+	base := func() prefs {
+		var p prefs
+		p.RouteAll, p.ShieldsUp, p.Exit, p.Name = "true", "true", "false", "base"
+		p.SSH.Enabled, p.SSH.Check = "true", "false"
+		return p
+	}
+	src := prefs{ShieldsUp: "false", Exit: "true", Name: "src"}
+	src.SSH.Enabled = "false"
+	src.SSH.Check = "true"
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  func(*prefs)
+	}{
+		{
+			name:  "set_one_clear_another",
+			paths: []string{"shields_up", "route_all"},
+			want:  func(p *prefs) { p.ShieldsUp, p.RouteAll = "false", "" },
+		},
+		{
+			name:  "go_and_json_names",
+			paths: []string{"Exit", "routeAll"},
+			want:  func(p *prefs) { p.Exit, p.RouteAll = "true", "" },
+		},
+		{
+			name:  "nested",
+			paths: []string{"ssh.enabled"},
+			want:  func(p *prefs) { p.SSH.Enabled = "false" },
+		},
+		{
+			name:  "whole_struct",
+			paths: []string{"ssh"},
+			want:  func(p *prefs) { p.SSH.Enabled, p.SSH.Check = "false", "true" },
+		},
+		{
+			name: "empty_mask",
+			want: func(*prefs) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := base()
+			if err := Apply(&got, &src, tt.paths); err != nil {
+				t.Fatal(err)
+			}
+			want := base()
+			tt.want(&want)
+			if got != want {
+				t.Errorf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	var src prefs
+	tests := []struct {
+		name    string
+		dst     any
+		src     any
+		paths   []string
+		wantErr string
+	}{
+		{"unknown_field", &prefs{}, &src, []string{"exit", "bogus"}, `has no field "bogus"`},
+		{"not_opt_bool", &prefs{}, &src, []string{"name"}, "is string, not opt.Bool"},
+		{"through_leaf", &prefs{}, &src, []string{"exit.more"}, `has no field "more"`},
+		{"dst_not_pointer", prefs{}, &src, nil, "not a non-nil pointer to a struct"},
+		{"src_mismatch", &prefs{}, &struct{ Exit opt.Bool }{}, nil, "not a non-nil *optmask.prefs"},
+		{"src_nil", &prefs{}, (*prefs)(nil), nil, "not a non-nil *optmask.prefs"},
+		{"src_untyped_nil", &prefs{}, nil, nil, "src is <nil>, not a non-nil *optmask.prefs"},
+		{"src_not_pointer", &prefs{}, prefs{}, nil, "not a non-nil *optmask.prefs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Apply(tt.dst, tt.src, tt.paths)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v; want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A bad path leaves dst untouched, even if earlier paths were fine.
+	dst := prefs{Exit: "false"}
+	src.Exit = "true"
+	if err := Apply(&dst, &src, []string{"exit", "bogus"}); err == nil {
+		t.Fatal("unexpected success")
+	}
+	if dst.Exit != "false" {
+		t.Errorf("dst modified on error: %+v", dst)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package optmigrate helps migrate legacy structs that use *bool for
+// optional booleans to structs that use opt.Bool.
+package optmigrate
+
+import (
+	"fmt"
+	"reflect"
+
+	"tailscale.com/types/opt"
+	"tailscale.com/types/opt/internal/optreflect"
+)
+
+var boolPtrType = reflect.TypeOf((*bool)(nil))
+
+// CopyBools copies the *bool fields of the struct pointed to by src into
+// the opt.Bool fields of the struct pointed to by dst. A nil *bool
+// becomes an unset opt.Bool.
+//
+// Each exported opt.Bool field in dst is matched with the src field of
+// the same name or, failing that, the src field with the same JSON
+// name. Struct-valued fields that match are copied recursively. Fields
+// of dst of other types, and fields with no match in src, are left
+// alone.
+//
+// A src field promoted from an embedded struct pointer that's nil is
+// treated as absent.
+//
+// It returns an error, without modifying dst, if dst or src isn't a
+// non-nil pointer to a struct, or if an opt.Bool field in dst matches
+// a src field that isn't a *bool.
+func CopyBools(dst, src any) error {
+	dv, sv := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if !optreflect.IsStructPtr(dv) {
+		return fmt.Errorf("optmigrate: dst is %T, not a non-nil pointer to a struct", dst)
+	}
+	if !optreflect.IsStructPtr(sv) {
+		return fmt.Errorf("optmigrate: src is %T, not a non-nil pointer to a struct", src)
+	}
+	var sets []boolSet
+	if err := planStruct(&sets, dv.Elem(), sv.Elem(), ""); err != nil {
+		return err
+	}
+	for _, s := range sets {
+		s.dst.Set(reflect.ValueOf(s.val))
+	}
+	return nil
+}
+
+// boolSet is an assignment of val to the opt.Bool field dst.
+type boolSet struct {
+	dst reflect.Value
+	val opt.Bool
+}
+
+// planStruct appends to sets the assignments that copy the *bool
+// fields of sv into the opt.Bool fields of dv, without making any, so
+// that CopyBools can check every field before it changes one.
+func planStruct(sets *[]boolSet, dv, sv reflect.Value, path string) error {
+	dt := dv.Type()
+	for i := 0; i < dt.NumField(); i++ {
+		df := dt.Field(i)
+		if !df.IsExported() {
+			continue
+		}
+		sf, ok := matchField(sv.Type(), df)
+		if !ok {
+			continue
+		}
+		sfv, err := sv.FieldByIndexErr(sf.Index)
+		if err != nil {
+			continue // promoted through a nil embedded pointer
+		}
+		name := path + df.Name
+		dfv := dv.Field(i)
+		switch {
+		case df.Type == optreflect.BoolType:
+			if sf.Type != boolPtrType {
+				return fmt.Errorf("optmigrate: field %s is opt.Bool in dst but %v in src", name, sf.Type)
+			}
+			b := opt.Bool("unset")
+			if !sfv.IsNil() {
+				b.Set(sfv.Elem().Bool())
+			}
+			*sets = append(*sets, boolSet{dfv, b})
+		case df.Type.Kind() == reflect.Struct && sf.Type.Kind() == reflect.Struct:
+			if err := planStruct(sets, dfv, sfv, name+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchField returns the field of st corresponding to df: the field of
+// the same name if there is one, otherwise the field with the same JSON
+// name.
+func matchField(st reflect.Type, df reflect.StructField) (reflect.StructField, bool) {
+	if sf, ok := st.FieldByName(df.Name); ok && sf.IsExported() {
+		return sf, true
+	}
+	want := optreflect.JSONName(df)
+	if want == "" {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		if sf.IsExported() && optreflect.JSONName(sf) == want {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optmigrate
+
+import (
+	"reflect"
+	"testing"
+
+	"tailscale.com/types/opt"
+)
+
+type legacyNested struct {
+	Enabled *bool
+}
+
+type legacy struct {
+	Name      string
+	RouteAll  *bool
+	ShieldsUp *bool `json:"shields"`
+	Unset     *bool
+	Count     int
+	Nested    legacyNested
+	private   *bool
+}
+
+type current struct {
+	Name     string
+	RouteAll opt.Bool
+	Shields  opt.Bool `json:"shields"`
+	Unset    opt.Bool
+	Missing  opt.Bool
+	Count    int
+	Nested   struct {
+		Enabled opt.Bool
+	}
+	private opt.Bool
+}
+
+func TestCopyBools(t *testing.T) {
+	yes, no := true, false
+	src := &legacy{
+		Name:      "foo",
+		RouteAll:  &yes,
+		ShieldsUp: &no,
+		Count:     3,
+		Nested:    legacyNested{Enabled: &yes},
+		private:   &yes,
+	}
+	dst := &current{Name: "bar", Missing: "true", Unset: "false"}
+	if err := CopyBools(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	want := &current{
+		Name:     "bar", // non-opt.Bool fields untouched
+		RouteAll: "true",
+		Shields:  "false",
+		Unset:    "unset",
+		Missing:  "true",
+	}
+	want.Nested.Enabled = "true"
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v; want %+v", dst, want)
+	}
+}
+
+func TestCopyBoolsErrors(t *testing.T) {
+	var dst current
+	tests := []struct {
+		name     string
+		dst, src any
+	}{
+		{"nil_dst", (*current)(nil), &legacy{}},
+		{"non_pointer_src", &dst, legacy{}},
+		{"non_struct_dst", new(int), &legacy{}},
+		{"mismatched_type", &dst, &struct{ RouteAll bool }{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CopyBools(tt.dst, tt.src); err == nil {
+				t.Error("unexpected success")
+			}
+		})
+	}
+}
+
+func TestCopyBoolsNilEmbedded(t *testing.T) {
+	type Base struct {
+		RouteAll *bool
+	}
+	type legacyEmbed struct {
+		*Base
+		ShieldsUp *bool
+	}
+	yes := true
+	dst := &current{RouteAll: "false"}
+	if err := CopyBools(dst, &legacyEmbed{ShieldsUp: &yes}); err != nil {
+		t.Fatal(err)
+	}
+	if dst.RouteAll != "false" {
+		t.Errorf("RouteAll = %q; want it left alone", dst.RouteAll)
+	}
+
+	if err := CopyBools(dst, &legacyEmbed{Base: &Base{RouteAll: &yes}}); err != nil {
+		t.Fatal(err)
+	}
+	if dst.RouteAll != "true" {
+		t.Errorf("RouteAll = %q; want true from the embedded struct", dst.RouteAll)
+	}
+}
+
+func TestCopyBoolsErrorLeavesDst(t *testing.T) {
+	type later struct {
+		RouteAll opt.Bool
+		Shields  opt.Bool
+	}
+	yes := true
+	src := &struct {
+		RouteAll *bool
+		Shields  string // not a *bool; found after RouteAll
+	}{RouteAll: &yes}
+	dst := &later{RouteAll: "false", Shields: "false"}
+	if err := CopyBools(dst, src); err == nil {
+		t.Fatal("unexpected success")
+	}
+	if want := (later{RouteAll: "false", Shields: "false"}); *dst != want {
+		t.Errorf("after error, dst = %+v; want unchanged %+v", *dst, want)
+	}
+}
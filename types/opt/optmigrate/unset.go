@@ -0,0 +1,90 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optmigrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// legacyUnset is how an older encoding sometimes wrote an unset
+// opt.Bool: as a JSON string, rather than null.
+var legacyUnset = []byte(`"unset"`)
+
+// FixLegacyUnset rewrites the opt.Bool fields named by paths in the
+// JSON object blob from the legacy string "unset" to null, the
+// canonical encoding of an unset opt.Bool. Other values, including
+// other fields set to "unset", are left alone.
+//
+// Each path is a dot-separated list of object keys, such as
+// "Prefs.RouteAll". Where a path passes through an array, it's applied
+// to each of the array's elements. Paths that don't exist in blob are
+// ignored.
+//
+// The changed result reports whether anything was rewritten. If not,
+// blob is returned as is; otherwise it's re-encoded compactly, with
+// the keys of the objects along the rewritten paths sorted.
+func FixLegacyUnset(blob []byte, paths []string) (out []byte, changed bool, err error) {
+	out = blob
+	for _, p := range paths {
+		var c bool
+		out, c, err = fixPath(out, strings.Split(p, "."))
+		if err != nil {
+			return nil, false, fmt.Errorf("optmigrate: path %q: %w", p, err)
+		}
+		changed = changed || c
+	}
+	return out, changed, nil
+}
+
+// fixPath rewrites the value at keys within the JSON value v.
+func fixPath(v json.RawMessage, keys []string) (json.RawMessage, bool, error) {
+	v = bytes.TrimSpace(v)
+	if len(keys) == 0 {
+		if bytes.Equal(v, legacyUnset) {
+			return json.RawMessage("null"), true, nil
+		}
+		return v, false, nil
+	}
+	switch {
+	case len(v) > 0 && v[0] == '[':
+		var elems []json.RawMessage
+		if err := json.Unmarshal(v, &elems); err != nil {
+			return nil, false, err
+		}
+		changed := false
+		for i, e := range elems {
+			ne, c, err := fixPath(e, keys)
+			if err != nil {
+				return nil, false, err
+			}
+			elems[i], changed = ne, changed || c
+		}
+		if !changed {
+			return v, false, nil
+		}
+		out, err := json.Marshal(elems)
+		return out, true, err
+	case len(v) > 0 && v[0] == '{':
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(v, &obj); err != nil {
+			return nil, false, err
+		}
+		child, ok := obj[keys[0]]
+		if !ok {
+			return v, false, nil
+		}
+		nc, changed, err := fixPath(child, keys[1:])
+		if err != nil || !changed {
+			return v, false, err
+		}
+		obj[keys[0]] = nc
+		out, err := json.Marshal(obj)
+		return out, true, err
+	}
+	return v, false, nil // scalar or null where an object was expected
+}
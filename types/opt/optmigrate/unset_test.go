@@ -0,0 +1,85 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optmigrate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"tailscale.com/types/opt"
+)
+
+func TestFixLegacyUnset(t *testing.T) {
+	paths := []string{"RouteAll", "Prefs.ShieldsUp", "Peers.Enabled"}
+	tests := []struct {
+		name        string
+		in          string
+		want        string
+		wantChanged bool
+	}{
+		{
+			name:        "top_level",
+			in:          `{"RouteAll":"unset","Name":"unset"}`,
+			want:        `{"Name":"unset","RouteAll":null}`, // Name isn't a known field
+			wantChanged: true,
+		},
+		{
+			name:        "nested",
+			in:          `{"Prefs": {"ShieldsUp": "unset", "RouteAll": "unset"}, "Other": [1, 2]}`,
+			want:        `{"Other":[1,2],"Prefs":{"RouteAll":"unset","ShieldsUp":null}}`,
+			wantChanged: true,
+		},
+		{
+			name:        "array",
+			in:          `{"Peers":[{"Enabled":"unset"},{"Enabled":true},{"Enabled":null}]}`,
+			want:        `{"Peers":[{"Enabled":null},{"Enabled":true},{"Enabled":null}]}`,
+			wantChanged: true,
+		},
+		{
+			name: "already_canonical",
+			in:   `{ "RouteAll": null, "Prefs": {"ShieldsUp": false} }`,
+			want: `{ "RouteAll": null, "Prefs": {"ShieldsUp": false} }`,
+		},
+		{
+			name: "missing_paths",
+			in:   `{"Prefs":null,"Peers":"none"}`,
+			want: `{"Prefs":null,"Peers":"none"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := FixLegacyUnset([]byte(tt.in), paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || changed != tt.wantChanged {
+				t.Errorf("got %s, %v; want %s, %v", got, changed, tt.want, tt.wantChanged)
+			}
+		})
+	}
+
+	// The result decodes into opt.Bool fields, which the legacy
+	// blob doesn't.
+	type prefs struct{ RouteAll, ShieldsUp opt.Bool }
+	legacy := []byte(`{"RouteAll":"unset","ShieldsUp":true}`)
+	if err := json.Unmarshal(legacy, new(prefs)); err == nil {
+		t.Fatal("legacy blob unexpectedly decoded")
+	}
+	fixed, _, err := FixLegacyUnset(legacy, []string{"RouteAll", "ShieldsUp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p prefs
+	if err := json.Unmarshal(fixed, &p); err != nil {
+		t.Fatal(err)
+	}
+	if want := (prefs{RouteAll: "unset", ShieldsUp: "true"}); p != want {
+		t.Errorf("decoded %+v; want %+v", p, want)
+	}
+
+	if _, _, err := FixLegacyUnset([]byte(`{"RouteAll":`), paths); err == nil {
+		t.Error("invalid JSON succeeded")
+	}
+}
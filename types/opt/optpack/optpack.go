@@ -0,0 +1,94 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package optpack packs the opt.Bool fields of a struct into a compact
+// bit string, for storing or sending structs of many feature flags.
+//
+// Each opt.Bool field takes 2 bits, holding the value of its
+// opt.Bool.MarshalBinary encoding: 0 for unset, 1 for false and 2 for
+// true. Fields are numbered in depth-first order of the struct's
+// exported fields, recursing into nested structs, and field i is in
+// bits 2*(i%4) and 2*(i%4)+1 of byte i/4, counting from the least
+// significant bit. Unused high bits of the last byte are zero. A
+// struct with n opt.Bool fields packs into (n+3)/4 bytes.
+//
+// The encoding has no field names or count, so the packing and
+// unpacking structs must have the same opt.Bool fields in the same
+// order; only add new fields at the end.
+package optpack
+
+import (
+	"fmt"
+	"reflect"
+
+	"tailscale.com/types/opt"
+	"tailscale.com/types/opt/internal/optreflect"
+)
+
+// Pack returns the packed encoding of the opt.Bool fields of v, a
+// struct or pointer to a struct. Both spellings of unset pack the
+// same. It's an error for a field to have an invalid backing value.
+func Pack(v any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("optpack: %T is not a struct", v)
+	}
+	fields := boolFields(rv, nil)
+	buf := make([]byte, (len(fields)+3)/4)
+	for i, f := range fields {
+		b, err := f.Interface().(opt.Bool).MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("optpack: field %d: %w", i, err)
+		}
+		buf[i/4] |= b[0] << (2 * (i % 4))
+	}
+	return buf, nil
+}
+
+// Unpack sets the opt.Bool fields of the struct pointed to by dst from
+// data, as encoded by Pack. Decoded unset fields are "unset". It's an
+// error, leaving dst unmodified, if data isn't the length Pack would
+// produce for dst or has an invalid 2-bit value or non-zero unused
+// bits.
+func Unpack(data []byte, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("optpack: dst is %T, not a non-nil pointer to a struct", dst)
+	}
+	fields := boolFields(rv.Elem(), nil)
+	if want := (len(fields) + 3) / 4; len(data) != want {
+		return fmt.Errorf("optpack: got %d bytes; want %d for %d fields", len(data), want, len(fields))
+	}
+	if n := len(fields) % 4; n != 0 && data[len(data)-1]>>(2*n) != 0 {
+		return fmt.Errorf("optpack: unused bits set in final byte %#x", data[len(data)-1])
+	}
+	vals := make([]opt.Bool, len(fields))
+	for i := range fields {
+		bits := data[i/4] >> (2 * (i % 4)) & 3
+		if err := vals[i].UnmarshalBinary([]byte{bits}); err != nil {
+			return fmt.Errorf("optpack: field %d: %w", i, err)
+		}
+	}
+	for i, f := range fields {
+		f.Set(reflect.ValueOf(vals[i]))
+	}
+	return nil
+}
+
+// boolFields appends the opt.Bool fields within the struct v to dst,
+// in depth-first order, and returns the extended slice.
+func boolFields(v reflect.Value, dst []reflect.Value) []reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		switch f := v.Field(i); {
+		case f.Type() == optreflect.BoolType:
+			dst = append(dst, f)
+		case f.Kind() == reflect.Struct:
+			dst = boolFields(f, dst)
+		}
+	}
+	return dst
+}
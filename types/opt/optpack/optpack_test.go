@@ -0,0 +1,112 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optpack
+
+import (
+	"bytes"
+	"testing"
+
+	"tailscale.com/types/opt"
+)
+
+type features struct {
+	A, B, C, D opt.Bool
+	Name       string // not packed
+	E, F       opt.Bool
+	Nested     struct {
+		G, H opt.Bool
+		I    opt.Bool
+	}
+	J, K     opt.Bool
+	internal opt.Bool // not packed
+}
+
+func TestPackUnpack(t *testing.T) {
+	in := features{
+		A: "true", B: "false", C: "", D: "unset",
+		Name: "x",
+		E:    "false", F: "true",
+		J: "true", K: "",
+	}
+	in.Nested.G, in.Nested.H, in.Nested.I = "false", "", "true"
+
+	got, err := Pack(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fields A..K are numbered 0..10, with 2 bits each:
+	//	byte 0: D=0 C=0 B=1 A=2 -> 0b00_00_01_10
+	//	byte 1: H=0 G=1 F=2 E=1 -> 0b00_01_10_01
+	//	byte 2: -   K=0 J=2 I=2 -> 0b00_00_10_10
+	want := []byte{0b00_00_01_10, 0b00_01_10_01, 0b00_00_10_10}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Pack = %08b; want %08b", got, want)
+	}
+	if byVal, err := Pack(in); err != nil || !bytes.Equal(byVal, want) {
+		t.Errorf("Pack of non-pointer = %08b, %v", byVal, err)
+	}
+
+	out := features{Name: "keep", A: "false", internal: "true"}
+	if err := Unpack(got, &out); err != nil {
+		t.Fatal(err)
+	}
+	check := []struct {
+		name      string
+		got, want opt.Bool
+	}{
+		{"A", out.A, "true"},
+		{"B", out.B, "false"},
+		{"C", out.C, "unset"},
+		{"D", out.D, "unset"},
+		{"E", out.E, "false"},
+		{"F", out.F, "true"},
+		{"G", out.Nested.G, "false"},
+		{"H", out.Nested.H, "unset"},
+		{"I", out.Nested.I, "true"},
+		{"J", out.J, "true"},
+		{"K", out.K, "unset"},
+		{"internal", out.internal, "true"},
+	}
+	for _, c := range check {
+		if c.got != c.want {
+			t.Errorf("%s = %q; want %q", c.name, c.got, c.want)
+		}
+	}
+	if out.Name != "keep" {
+		t.Errorf("Name = %q; want unchanged", out.Name)
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	if _, err := Pack(features{A: "yes"}); err == nil {
+		t.Error("Pack of invalid value succeeded")
+	}
+	if _, err := Pack(1); err == nil {
+		t.Error("Pack of non-struct succeeded")
+	}
+	if got, err := Pack(struct{ Name string }{}); err != nil || len(got) != 0 {
+		t.Errorf("Pack of no fields = %v, %v; want empty", got, err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"short", []byte{0, 0}},
+		{"long", []byte{0, 0, 0, 0}},
+		{"invalid_bits", []byte{0b11, 0, 0}},
+		{"unused_bits", []byte{0, 0, 0b01_00_00_00}},
+	} {
+		out := features{A: "true"}
+		if err := Unpack(tt.data, &out); err == nil {
+			t.Errorf("%s: Unpack succeeded", tt.name)
+		} else if out.A != "true" {
+			t.Errorf("%s: Unpack modified dst on error", tt.name)
+		}
+	}
+	if err := Unpack(nil, features{}); err == nil {
+		t.Error("Unpack into non-pointer succeeded")
+	}
+}
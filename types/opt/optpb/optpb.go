@@ -0,0 +1,35 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package optpb converts between the types in package opt and their
+// protobuf well-known wrapper type equivalents.
+//
+// It's separate from package opt so that package doesn't depend on
+// protobuf.
+package optpb
+
+import (
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"tailscale.com/types/opt"
+)
+
+// BoolValue returns b as a google.protobuf.BoolValue. It returns nil if
+// b is unset.
+func BoolValue(b opt.Bool) *wrapperspb.BoolValue {
+	v, ok := b.Get()
+	if !ok {
+		return nil
+	}
+	return wrapperspb.Bool(v)
+}
+
+// FromBoolValue returns v as an opt.Bool. A nil v is unset.
+func FromBoolValue(v *wrapperspb.BoolValue) opt.Bool {
+	if v == nil {
+		return "unset"
+	}
+	var b opt.Bool
+	b.Set(v.GetValue())
+	return b
+}
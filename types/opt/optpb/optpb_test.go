@@ -0,0 +1,35 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package optpb
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"tailscale.com/types/opt"
+)
+
+func TestBoolValue(t *testing.T) {
+	tests := []struct {
+		in   opt.Bool
+		want *wrapperspb.BoolValue
+		back opt.Bool
+	}{
+		{"true", wrapperspb.Bool(true), "true"},
+		{"false", wrapperspb.Bool(false), "false"},
+		{"", nil, "unset"},
+		{"unset", nil, "unset"},
+		{"garbage", nil, "unset"},
+	}
+	for _, tt := range tests {
+		got := BoolValue(tt.in)
+		if (got == nil) != (tt.want == nil) || (got != nil && got.GetValue() != tt.want.GetValue()) {
+			t.Errorf("BoolValue(%q) = %v; want %v", string(tt.in), got, tt.want)
+		}
+		if back := FromBoolValue(got); back != tt.back {
+			t.Errorf("FromBoolValue(BoolValue(%q)) = %q; want %q", string(tt.in), string(back), string(tt.back))
+		}
+	}
+}
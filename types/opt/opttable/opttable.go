@@ -0,0 +1,62 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package opttable renders tables of opt.Bool values for CLI output.
+package opttable
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"tailscale.com/types/opt"
+)
+
+// Row is a labeled opt.Bool to write as a table row.
+type Row struct {
+	Label string
+	Value opt.Bool
+}
+
+// ANSI escape sequences used for color output.
+const (
+	ansiGreen = "\x1b[32m"
+	ansiRed   = "\x1b[31m"
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+)
+
+// Indicator returns the symbol for b: "✓" for true, "✗" for false, "–"
+// for unset, and "?" for an invalid value. If color is true, the
+// symbol is wrapped in ANSI color escapes.
+func Indicator(b opt.Bool, color bool) string {
+	sym, c := "?", ""
+	if v, ok := b.Get(); ok {
+		if v {
+			sym, c = "✓", ansiGreen
+		} else {
+			sym, c = "✗", ansiRed
+		}
+	} else if b == "" || b == "unset" {
+		sym, c = "–", ansiDim
+	}
+	if !color || c == "" {
+		return sym
+	}
+	return c + sym + ansiReset
+}
+
+// Write writes one "label<TAB>indicator" line to tw for each row, so
+// the indicators line up in a column. The caller is responsible for
+// flushing tw.
+//
+// The indicator is the last cell of each line, so the color escapes
+// don't throw off tabwriter's alignment.
+func Write(tw *tabwriter.Writer, rows []Row, color bool) error {
+	for _, r := range rows {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\n", r.Label, Indicator(r.Value, color)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
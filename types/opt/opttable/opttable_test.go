@@ -0,0 +1,79 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opttable
+
+import (
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"tailscale.com/types/opt"
+)
+
+func TestIndicator(t *testing.T) {
+	tests := []struct {
+		in        opt.Bool
+		want      string
+		wantColor string
+	}{
+		{"true", "✓", "\x1b[32m✓\x1b[0m"},
+		{"false", "✗", "\x1b[31m✗\x1b[0m"},
+		{"", "–", "\x1b[2m–\x1b[0m"},
+		{"unset", "–", "\x1b[2m–\x1b[0m"},
+		{"maybe", "?", "?"},
+	}
+	for _, tt := range tests {
+		if got := Indicator(tt.in, false); got != tt.want {
+			t.Errorf("Indicator(%q, false) = %q; want %q", tt.in, got, tt.want)
+		}
+		if got := Indicator(tt.in, true); got != tt.wantColor {
+			t.Errorf("Indicator(%q, true) = %q; want %q", tt.in, got, tt.wantColor)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rows := []Row{
+		{"MagicDNS", "true"},
+		{"Shields up", "false"},
+		{"Exit node", ""},
+	}
+	tests := []struct {
+		name  string
+		color bool
+		want  string
+	}{
+		{
+			name: "plain",
+			want: "" +
+				"MagicDNS    ✓\n" +
+				"Shields up  ✗\n" +
+				"Exit node   –\n",
+		},
+		{
+			name:  "color",
+			color: true,
+			want: "" +
+				"MagicDNS    \x1b[32m✓\x1b[0m\n" +
+				"Shields up  \x1b[31m✗\x1b[0m\n" +
+				"Exit node   \x1b[2m–\x1b[0m\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			tw := tabwriter.NewWriter(&sb, 0, 2, 2, ' ', 0)
+			if err := Write(tw, rows, tt.color); err != nil {
+				t.Fatal(err)
+			}
+			if err := tw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
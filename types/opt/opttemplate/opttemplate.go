@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package opttemplate provides text/template functions for branching
+// on opt.Bool values.
+//
+// It's separate from package opt so that opt's many importers don't
+// all depend on text/template.
+package opttemplate
+
+import (
+	"text/template"
+
+	"tailscale.com/types/opt"
+)
+
+// FuncMap returns the template functions, for passing to
+// template.Template.Funcs:
+//
+//	isSet b         whether b is set, to true or false
+//	isTrue b        whether b is set to true
+//	isFalse b       whether b is set to false
+//	getOr b default b's value, or default if b is unset
+//
+// as in {{if isTrue .Feature}} or {{getOr .RouteAll true}}. The
+// IsSet, IsTrue and IsFalse methods of opt.Bool can also be used
+// directly, as in {{if .Feature.IsTrue}}.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"isSet":   opt.Bool.IsSet,
+		"isTrue":  opt.Bool.IsTrue,
+		"isFalse": opt.Bool.IsFalse,
+		"getOr":   getOr,
+	}
+}
+
+func getOr(b opt.Bool, def bool) bool {
+	if v, ok := b.Get(); ok {
+		return v
+	}
+	return def
+}
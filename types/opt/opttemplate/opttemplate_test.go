@@ -0,0 +1,39 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opttemplate
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"tailscale.com/types/opt"
+)
+
+func TestFuncMap(t *testing.T) {
+	const text = `{{if isTrue .B}}on{{else if isFalse .B}}off{{else}}default{{end}}` +
+		` set={{isSet .B}} getOr={{getOr .B true}},{{getOr .B false}}` +
+		` method={{if .B.IsTrue}}T{{end}}{{if .B.IsFalse}}F{{end}}{{if not .B.IsSet}}U{{end}}`
+	tmpl := template.Must(template.New("").Funcs(FuncMap()).Parse(text))
+
+	tests := []struct {
+		b    opt.Bool
+		want string
+	}{
+		{"true", "on set=true getOr=true,true method=T"},
+		{"false", "off set=true getOr=false,false method=F"},
+		{"", "default set=false getOr=true,false method=U"},
+		{"unset", "default set=false getOr=true,false method=U"},
+	}
+	for _, tt := range tests {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, struct{ B opt.Bool }{tt.b}); err != nil {
+			t.Fatalf("Execute(%q): %v", tt.b, err)
+		}
+		if got := sb.String(); got != tt.want {
+			t.Errorf("Execute(%q) = %q; want %q", tt.b, got, tt.want)
+		}
+	}
+}
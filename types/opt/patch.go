@@ -0,0 +1,145 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ApplyJSONPatch applies the JSON object patch to the struct pointed to
+// by dst, as a partial update.
+//
+// Only fields whose keys are present in patch are modified. For
+// opt.Bool fields, that means a key set to true or false sets the
+// field, a key set to null clears it to unset, and an omitted key leaves
+// it unchanged. Other field types follow encoding/json's rules for
+// decoding into an existing value: nested structs and maps are merged,
+// while slices and other values are replaced.
+//
+// Unlike a plain json.Unmarshal, it's an error for patch to be anything
+// but a JSON object or to name a field that dst doesn't have, so typos
+// in a patch don't silently do nothing.
+func ApplyJSONPatch(dst any, patch []byte) error {
+	patch = bytes.TrimSpace(patch)
+	if len(patch) == 0 || patch[0] != '{' {
+		return errors.New("opt.ApplyJSONPatch: patch is not a JSON object")
+	}
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("opt.ApplyJSONPatch: %w", err)
+	}
+	if dec.More() {
+		return errors.New("opt.ApplyJSONPatch: trailing data after patch")
+	}
+	return nil
+}
+
+// DiffJSONPatch returns the minimal JSON object patch that
+// ApplyJSONPatch would apply to a copy of old to make it match new,
+// which must be structs (or pointers to structs) of the same type.
+//
+// Fields that are the same in both are omitted. A Bool field that
+// changed to true or false is set to that boolean, and one that changed
+// to unset is set to null. Bool fields are compared by logical state,
+// so "" and "unset" are the same; an invalid Bool in new is an error.
+// Other fields that changed are set to
+// their new value. Keys are the fields' JSON names, in field order.
+//
+// Embedded structs aren't supported.
+func DiffJSONPatch(old, new any) ([]byte, error) {
+	ov, nv := reflect.Indirect(reflect.ValueOf(old)), reflect.Indirect(reflect.ValueOf(new))
+	if ov.Kind() != reflect.Struct || ov.Type() != nv.Type() {
+		return nil, fmt.Errorf("opt.DiffJSONPatch: %T and %T aren't the same struct type", old, new)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous {
+			return nil, fmt.Errorf("opt.DiffJSONPatch: embedded field %s not supported", sf.Name)
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		of, nf := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if ob, ok := of.(Bool); ok {
+			if logical(ob) == logical(nf.(Bool)) && nf.(Bool).Valid() {
+				continue
+			}
+		} else if reflect.DeepEqual(of, nf) {
+			continue
+		}
+		val, err := json.Marshal(nf)
+		if err != nil {
+			return nil, fmt.Errorf("opt.DiffJSONPatch: field %s: %w", sf.Name, err)
+		}
+		key, _ := json.Marshal(name)
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// PatchBool is a Bool field of a PATCH request body that records
+// whether the client sent it.
+//
+// A struct of Bools can't tell an omitted key from one set to null,
+// as both decode as unset. A PatchBool field's Present method
+// reports whether its key appeared in the decoded JSON at all, so a
+// handler can leave omitted fields alone and clear null ones.
+//
+// Decoding into a reused value only updates the fields present in the
+// new input, so decode each request body into a fresh struct.
+type PatchBool struct {
+	b       Bool
+	present bool
+}
+
+// Present reports whether the field was in the decoded JSON, even if
+// null.
+func (p PatchBool) Present() bool { return p.present }
+
+// Get returns the field's value, which is unset if the field was
+// omitted or null.
+func (p PatchBool) Get() Bool { return p.b.Normalize() }
+
+// Apply sets *dst to the field's value if the field was present,
+// and reports whether it did.
+func (p PatchBool) Apply(dst *Bool) bool {
+	if p.present {
+		*dst = p.b
+	}
+	return p.present
+}
+
+func (p PatchBool) MarshalJSON() ([]byte, error) { return p.b.MarshalJSON() }
+
+func (p *PatchBool) UnmarshalJSON(j []byte) error {
+	var b Bool
+	if err := b.UnmarshalJSON(j); err != nil {
+		return err
+	}
+	*p = PatchBool{b: b, present: true}
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	type config struct {
+		RouteAll  Bool
+		ShieldsUp Bool
+		Exit      Bool
+		Name      string
+	}
+	base := config{RouteAll: "true", ShieldsUp: "false", Exit: "true", Name: "foo"}
+
+	tests := []struct {
+		name  string
+		patch string
+		want  config
+	}{
+		{
+			name:  "null_clears",
+			patch: `{"RouteAll":null}`,
+			want:  config{RouteAll: "unset", ShieldsUp: "false", Exit: "true", Name: "foo"},
+		},
+		{
+			name:  "present_sets",
+			patch: `{"ShieldsUp":true,"Exit":false}`,
+			want:  config{RouteAll: "true", ShieldsUp: "true", Exit: "false", Name: "foo"},
+		},
+		{
+			name:  "omitted_keeps",
+			patch: `{"Name":"bar"}`,
+			want:  config{RouteAll: "true", ShieldsUp: "false", Exit: "true", Name: "bar"},
+		},
+		{
+			name:  "empty",
+			patch: ` {} `,
+			want:  base,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := base
+			if err := ApplyJSONPatch(&got, []byte(tt.patch)); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+			// Cleared fields must still read as unset.
+			if _, ok := got.RouteAll.Get(); ok != (tt.want.RouteAll == "true") {
+				t.Errorf("RouteAll.Get ok = %v", ok)
+			}
+		})
+	}
+
+	for _, bad := range []string{
+		`null`,
+		`[]`,
+		``,
+		`{"RoutAll":true}`, // typo
+		`{"RouteAll":3}`,   // wrong type
+		`{"Exit":true} {}`, // trailing data
+	} {
+		got := base
+		if err := ApplyJSONPatch(&got, []byte(bad)); err == nil {
+			t.Errorf("ApplyJSONPatch(%#q) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestDiffJSONPatch(t *testing.T) {
+	type prefs struct {
+		RouteAll  Bool
+		ShieldsUp Bool `json:"shieldsUp"`
+		Exit      Bool
+		Name      string
+		Skipped   Bool `json:"-"`
+		internal  Bool
+	}
+	base := prefs{RouteAll: "true", ShieldsUp: "false", Exit: "", Name: "foo"}
+
+	tests := []struct {
+		name string
+		new  prefs
+		want string
+	}{
+		{"same", base, `{}`},
+		{"unset_spellings", prefs{RouteAll: "true", ShieldsUp: "false", Exit: "unset", Name: "foo"}, `{}`},
+		{"flip", prefs{RouteAll: "false", ShieldsUp: "false", Name: "foo"}, `{"RouteAll":false}`},
+		{"to_unset", prefs{RouteAll: "true", ShieldsUp: "unset", Name: "foo"}, `{"shieldsUp":null}`},
+		{"to_set", prefs{RouteAll: "true", ShieldsUp: "false", Exit: "true", Name: "foo"}, `{"Exit":true}`},
+		{"several", prefs{RouteAll: "", ShieldsUp: "true", Exit: "false", Name: "bar"}, `{"RouteAll":null,"shieldsUp":true,"Exit":false,"Name":"bar"}`},
+		{"ignored_fields", prefs{RouteAll: "true", ShieldsUp: "false", Name: "foo", Skipped: "true", internal: "true"}, `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffJSONPatch(base, &tt.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+			// Applying the patch to old gives new.
+			applied := base
+			if err := ApplyJSONPatch(&applied, got); err != nil {
+				t.Fatal(err)
+			}
+			if logical(applied.RouteAll) != logical(tt.new.RouteAll) ||
+				logical(applied.ShieldsUp) != logical(tt.new.ShieldsUp) ||
+				logical(applied.Exit) != logical(tt.new.Exit) ||
+				applied.Name != tt.new.Name {
+				t.Errorf("applied = %+v; want %+v", applied, tt.new)
+			}
+		})
+	}
+
+	if _, err := DiffJSONPatch(base, struct{ RouteAll Bool }{}); err == nil {
+		t.Error("different types succeeded")
+	}
+	if _, err := DiffJSONPatch(1, 2); err == nil {
+		t.Error("non-structs succeeded")
+	}
+	if _, err := DiffJSONPatch(prefs{}, prefs{RouteAll: "yes"}); err == nil {
+		t.Error("invalid Bool succeeded")
+	}
+}
+
+func TestPatchBool(t *testing.T) {
+	type patch struct {
+		RouteAll  PatchBool
+		ShieldsUp PatchBool
+		Exit      PatchBool `json:"exit"`
+	}
+	var p patch
+	if err := json.Unmarshal([]byte(`{"RouteAll":false,"exit":null}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		f           PatchBool
+		wantPresent bool
+		want        Bool
+	}{
+		{"RouteAll", p.RouteAll, true, "false"},
+		{"ShieldsUp", p.ShieldsUp, false, "unset"},
+		{"exit", p.Exit, true, "unset"},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Present(); got != tt.wantPresent {
+			t.Errorf("%s.Present() = %v; want %v", tt.name, got, tt.wantPresent)
+		}
+		if got := tt.f.Get(); got != tt.want {
+			t.Errorf("%s.Get() = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+
+	cur := struct{ RouteAll, ShieldsUp, Exit Bool }{"true", "true", "true"}
+	for _, a := range []struct {
+		f   PatchBool
+		dst *Bool
+	}{{p.RouteAll, &cur.RouteAll}, {p.ShieldsUp, &cur.ShieldsUp}, {p.Exit, &cur.Exit}} {
+		a.f.Apply(a.dst)
+	}
+	if cur.RouteAll != "false" || cur.ShieldsUp != "true" || cur.Exit != "unset" {
+		t.Errorf("after Apply: %+v", cur)
+	}
+
+	j, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"RouteAll":false,"ShieldsUp":null,"exit":null}`; string(j) != want {
+		t.Errorf("Marshal = %s; want %s", j, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"RouteAll":"yes"}`), new(patch)); err == nil {
+		t.Error("Unmarshal of invalid value succeeded")
+	}
+}
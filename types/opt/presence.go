@@ -0,0 +1,63 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// PresenceBool is a Bool that's JSON-encoded as an object with
+// explicit presence, like {"present":true,"value":false}, for
+// interoperating with APIs that model optional booleans that way
+// rather than with null.
+//
+// An object with "present" false is unset regardless of its "value".
+// Convert to and from Bool with Bool(p) and PresenceBool(b).
+type PresenceBool Bool
+
+func (p PresenceBool) MarshalJSON() ([]byte, error) {
+	switch p {
+	case "true":
+		return []byte(`{"present":true,"value":true}`), nil
+	case "false":
+		return []byte(`{"present":true,"value":false}`), nil
+	case "", "unset":
+		return []byte(`{"present":false,"value":false}`), nil
+	}
+	return nil, fmt.Errorf("invalid opt.Bool value %q", string(p))
+}
+
+func (p *PresenceBool) UnmarshalJSON(j []byte) error {
+	j = bytes.Trim(j, " \t\r\n")
+	if string(j) == "null" {
+		*p = "unset"
+		return nil
+	}
+	if len(j) == 0 || j[0] != '{' {
+		return fmt.Errorf("invalid opt.PresenceBool JSON %s: not an object", truncateJSON(j))
+	}
+	var o struct {
+		Present *bool `json:"present"`
+		Value   *bool `json:"value"`
+	}
+	if err := json.Unmarshal(j, &o); err != nil {
+		return fmt.Errorf("invalid opt.PresenceBool JSON %s: %w", truncateJSON(j), err)
+	}
+	switch {
+	case o.Present == nil:
+		return fmt.Errorf("invalid opt.PresenceBool JSON %s: missing \"present\"", truncateJSON(j))
+	case !*o.Present:
+		*p = "unset"
+	case o.Value == nil:
+		return fmt.Errorf("invalid opt.PresenceBool JSON %s: present without \"value\"", truncateJSON(j))
+	default:
+		var b Bool
+		b.Set(*o.Value)
+		*p = PresenceBool(b)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPresenceBool(t *testing.T) {
+	for _, tt := range []struct {
+		in   Bool
+		want string
+	}{
+		{"true", `{"present":true,"value":true}`},
+		{"false", `{"present":true,"value":false}`},
+		{"", `{"present":false,"value":false}`},
+		{"unset", `{"present":false,"value":false}`},
+	} {
+		j, err := json.Marshal(PresenceBool(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(j) != tt.want {
+			t.Errorf("Marshal(%q) = %s; want %s", string(tt.in), j, tt.want)
+		}
+		var back PresenceBool
+		if err := json.Unmarshal(j, &back); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", j, err)
+		}
+		if !Bool(back).Equal(tt.in) {
+			t.Errorf("round trip of %q = %q", string(tt.in), string(back))
+		}
+	}
+	if _, err := json.Marshal(PresenceBool("yes")); err == nil {
+		t.Error("Marshal of invalid value succeeded")
+	}
+
+	tests := []struct {
+		in      string
+		want    Bool
+		wantErr bool
+	}{
+		{`{"present":true,"value":false}`, "false", false},
+		{`{"value":true,"present":true}`, "true", false},
+		{`{"present":false}`, "unset", false},
+		{`{"present":false,"value":true}`, "unset", false},
+		{`{"present":false,"value":null}`, "unset", false},
+		{`null`, "unset", false},
+		{`{"present":true,"value":false,"extra":1}`, "false", false},
+		{`{}`, "", true},
+		{`{"value":true}`, "", true},
+		{`{"present":true}`, "", true},
+		{`{"present":true,"value":null}`, "", true},
+		{`{"present":"true","value":true}`, "", true},
+		{`{"present":true,"value":1}`, "", true},
+		{`true`, "", true},
+		{`[true,true]`, "", true},
+	}
+	for _, tt := range tests {
+		var got PresenceBool
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s): err = %v; wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if Bool(got) != tt.want {
+			t.Errorf("Unmarshal(%s) = %q; want %q", tt.in, string(got), string(tt.want))
+		}
+	}
+
+	// It works as a struct field.
+	var s struct{ Enabled PresenceBool }
+	if err := json.Unmarshal([]byte(`{"Enabled":{"present":true,"value":true}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := Bool(s.Enabled).Get(); !ok || !v {
+		t.Errorf("Enabled = %q; want true", string(s.Enabled))
+	}
+}
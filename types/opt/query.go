@@ -0,0 +1,36 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// BoolFromQuery returns the optional boolean query parameter key from
+// values.
+//
+// It's unset if key is absent or has an empty value. Otherwise the
+// first value is parsed leniently: anything strconv.ParseBool accepts,
+// plus "yes", "no", "on" (as sent by HTML checkboxes) and "off", in
+// any case. Invalid values are also unset.
+func BoolFromQuery(values url.Values, key string) Bool {
+	v := values.Get(key)
+	if v == "" {
+		return "unset"
+	}
+	switch strings.ToLower(v) {
+	case "yes", "on":
+		return "true"
+	case "no", "off":
+		return "false"
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return "unset"
+	}
+	return Bool(strconv.FormatBool(b))
+}
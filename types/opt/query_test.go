@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestBoolFromQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Bool
+	}{
+		{"", "unset"},
+		{"other=true", "unset"},
+		{"v", "unset"},
+		{"v=", "unset"},
+		{"v=true", "true"},
+		{"v=false", "false"},
+		{"v=1", "true"},
+		{"v=0", "false"},
+		{"v=TRUE", "true"},
+		{"v=Yes", "true"},
+		{"v=on", "true"},
+		{"v=no", "false"},
+		{"v=OFF", "false"},
+		{"v=maybe", "unset"},
+		{"v=%20", "unset"},
+		{"v=false&v=true", "false"}, // first wins
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := BoolFromQuery(q, "v"); got != tt.want {
+			t.Errorf("BoolFromQuery(%q) = %q; want %q", tt.query, got, tt.want)
+		}
+	}
+}
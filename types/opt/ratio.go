@@ -0,0 +1,80 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Ratio represents an optional ratio in the closed range [0,1], such
+// as a sampling rate, to be JSON-encoded as a number or null.
+//
+// The zero value is unset.
+type Ratio struct {
+	v  float64
+	ok bool
+}
+
+// Clamp returns a set Ratio of v clamped to the range [0,1].
+// NaN is clamped to 0.
+func Clamp(v float64) Ratio {
+	switch {
+	case math.IsNaN(v), v < 0:
+		v = 0
+	case v > 1:
+		v = 1
+	}
+	return Ratio{v: v, ok: true}
+}
+
+func validRatio(v float64) error {
+	if math.IsNaN(v) || v < 0 || v > 1 {
+		return fmt.Errorf("opt.Ratio value %v out of range [0,1]", v)
+	}
+	return nil
+}
+
+// Set sets r to v. It returns an error and leaves r unmodified if v
+// is not in the range [0,1].
+func (r *Ratio) Set(v float64) error {
+	if err := validRatio(v); err != nil {
+		return err
+	}
+	*r = Ratio{v: v, ok: true}
+	return nil
+}
+
+func (r *Ratio) Clear() { *r = Ratio{} }
+
+func (r Ratio) Get() (v float64, ok bool) { return r.v, r.ok }
+
+func (r Ratio) String() string {
+	if !r.ok {
+		return "unset"
+	}
+	return strconv.FormatFloat(r.v, 'g', -1, 64)
+}
+
+func (r Ratio) MarshalJSON() ([]byte, error) {
+	if !r.ok {
+		return nullBytes, nil
+	}
+	return strconv.AppendFloat(nil, r.v, 'g', -1, 64), nil
+}
+
+func (r *Ratio) UnmarshalJSON(j []byte) error {
+	if string(j) == "null" {
+		*r = Ratio{}
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(j, &v); err != nil {
+		return fmt.Errorf("invalid opt.Ratio value %q", j)
+	}
+	return r.Set(v)
+}
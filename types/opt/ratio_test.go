@@ -0,0 +1,111 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestRatioSet(t *testing.T) {
+	tests := []struct {
+		in      float64
+		wantErr bool
+	}{
+		{0, false},
+		{0.5, false},
+		{1, false},
+		{-math.SmallestNonzeroFloat64, true},
+		{math.Nextafter(1, 2), true},
+		{math.NaN(), true},
+		{math.Inf(1), true},
+		{math.Inf(-1), true},
+	}
+	for _, tt := range tests {
+		var r Ratio
+		err := r.Set(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%v) error = %v; want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		v, ok := r.Get()
+		if tt.wantErr {
+			if ok {
+				t.Errorf("Set(%v) failed but left value set to %v", tt.in, v)
+			}
+			continue
+		}
+		if !ok || v != tt.in {
+			t.Errorf("after Set(%v), Get = %v, %v", tt.in, v, ok)
+		}
+	}
+}
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{0, 0},
+		{1, 1},
+		{0.25, 0.25},
+		{-0.001, 0},
+		{1.001, 1},
+		{math.NaN(), 0},
+		{math.Inf(1), 1},
+		{math.Inf(-1), 0},
+	}
+	for _, tt := range tests {
+		v, ok := Clamp(tt.in).Get()
+		if !ok || v != tt.want {
+			t.Errorf("Clamp(%v) = %v, %v; want %v, true", tt.in, v, ok, tt.want)
+		}
+	}
+}
+
+func TestRatioJSON(t *testing.T) {
+	type S struct {
+		R Ratio
+	}
+	tests := []struct {
+		in      string
+		want    string // re-marshaled JSON; empty means want error
+		wantSet bool
+	}{
+		{`{"R":null}`, `{"R":null}`, false},
+		{`{}`, `{"R":null}`, false},
+		{`{"R":0}`, `{"R":0}`, true},
+		{`{"R":1}`, `{"R":1}`, true},
+		{`{"R":0.125}`, `{"R":0.125}`, true},
+		{`{"R":-0.0001}`, "", false},
+		{`{"R":1.0001}`, "", false},
+		{`{"R":"0.5"}`, "", false},
+		{`{"R":1e999}`, "", false},
+	}
+	for _, tt := range tests {
+		var s S
+		err := json.Unmarshal([]byte(tt.in), &s)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) succeeded; want error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if _, ok := s.R.Get(); ok != tt.wantSet {
+			t.Errorf("Unmarshal(%s) set = %v; want %v", tt.in, ok, tt.wantSet)
+		}
+		j, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(j) != tt.want {
+			t.Errorf("round trip of %s = %s; want %s", tt.in, j, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Set is an optional set to be JSON-encoded as an array. Like List, it
+// distinguishes an unset set (JSON null) from an explicitly empty one
+// (JSON []).
+//
+// The zero value is unset.
+type Set[T comparable] struct {
+	m  map[T]struct{}
+	ok bool
+}
+
+// SetOf returns a set Set of the provided elements. With no arguments
+// it returns a set, empty Set.
+func SetOf[T comparable](elems ...T) Set[T] {
+	var s Set[T]
+	s.Add(elems...)
+	s.ok = true
+	return s
+}
+
+// Add adds elems to s, setting s if it was unset.
+func (s *Set[T]) Add(elems ...T) {
+	if s.m == nil {
+		s.m = make(map[T]struct{}, len(elems))
+	}
+	for _, e := range elems {
+		s.m[e] = struct{}{}
+	}
+	s.ok = true
+}
+
+// Contains reports whether e is in s. An unset Set contains nothing.
+func (s Set[T]) Contains(e T) bool {
+	_, ok := s.m[e]
+	return ok
+}
+
+func (s *Set[T]) Clear() { *s = Set[T]{} }
+
+// Get returns the set's elements and whether it's set. The map is
+// shared with s, not copied. It may be nil for an empty set.
+func (s Set[T]) Get() (m map[T]struct{}, ok bool) { return s.m, s.ok }
+
+// MarshalJSON encodes s as a JSON array or null. Elements are sorted
+// by their JSON encoding, so the output is stable for any T.
+func (s Set[T]) MarshalJSON() ([]byte, error) {
+	if !s.ok {
+		return nullBytes, nil
+	}
+	elems := make([][]byte, 0, len(s.m))
+	for e := range s.m {
+		j, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, j)
+	}
+	sort.Slice(elems, func(i, j int) bool { return bytes.Compare(elems[i], elems[j]) < 0 })
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	buf.Write(bytes.Join(elems, []byte(",")))
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a JSON array or null into s. Duplicate
+// elements are collapsed.
+func (s *Set[T]) UnmarshalJSON(j []byte) error {
+	if string(j) == "null" {
+		*s = Set[T]{}
+		return nil
+	}
+	var elems []T
+	if err := json.Unmarshal(j, &elems); err != nil {
+		return err
+	}
+	*s = SetOf(elems...)
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	type S struct {
+		S Set[string]
+	}
+	tests := []struct {
+		name    string
+		in      S
+		want    string // JSON
+		wantOK  bool
+		wantLen int
+	}{
+		{name: "unset", in: S{}, want: `{"S":null}`},
+		{name: "empty", in: S{S: SetOf[string]()}, want: `{"S":[]}`, wantOK: true},
+		{name: "populated", in: S{S: SetOf("b", "c", "a")}, want: `{"S":["a","b","c"]}`, wantOK: true, wantLen: 3},
+		{name: "dups", in: S{S: SetOf("b", "a", "b")}, want: `{"S":["a","b"]}`, wantOK: true, wantLen: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := json.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(j) != tt.want {
+				t.Errorf("wrong JSON:\n got: %s\nwant: %s\n", j, tt.want)
+			}
+			var back S
+			if err := json.Unmarshal(j, &back); err != nil {
+				t.Fatalf("Unmarshal %#q: %v", j, err)
+			}
+			m, ok := back.S.Get()
+			if ok != tt.wantOK || len(m) != tt.wantLen {
+				t.Errorf("after round trip, Get = len %v, %v; want len %v, %v", len(m), ok, tt.wantLen, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSetUnmarshal(t *testing.T) {
+	var s Set[int]
+	if err := json.Unmarshal([]byte(`[3,1,3,2,1]`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := s.Get(); !ok || len(m) != 3 {
+		t.Errorf("Get = %v, %v; want 3 elements, true", m, ok)
+	}
+	for _, e := range []int{1, 2, 3} {
+		if !s.Contains(e) {
+			t.Errorf("missing %v", e)
+		}
+	}
+	if s.Contains(4) {
+		t.Error("unexpectedly contains 4")
+	}
+	if j, _ := json.Marshal(s); string(j) != `[1,2,3]` {
+		t.Errorf("re-marshaled = %s; want [1,2,3]", j)
+	}
+
+	// null on a set value unsets it.
+	if err := json.Unmarshal([]byte(`null`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := s.Get(); ok || m != nil {
+		t.Errorf("after null, Get = %v, %v; want nil, false", m, ok)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":1}`), &s); err == nil {
+		t.Error("Unmarshal of object succeeded")
+	}
+}
+
+func TestSetAddClear(t *testing.T) {
+	var s Set[string]
+	if _, ok := s.Get(); ok {
+		t.Fatal("zero Set is set")
+	}
+	if s.Contains("a") {
+		t.Fatal("zero Set contains a")
+	}
+	s.Add("a")
+	s.Add("a", "b")
+	if m, ok := s.Get(); !ok || len(m) != 2 || !s.Contains("b") {
+		t.Errorf("after Add, Get = %v, %v", m, ok)
+	}
+	s.Clear()
+	if j, _ := json.Marshal(s); string(j) != "null" {
+		t.Errorf("after Clear, JSON = %s; want null", j)
+	}
+}
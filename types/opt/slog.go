@@ -0,0 +1,41 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package opt
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer. A set Bool logs as a boolean. An
+// unset one logs as an empty group, which slog's handlers omit, so
+// the attribute is absent rather than an empty string.
+func (b Bool) LogValue() slog.Value {
+	if v, ok := b.Get(); ok {
+		return slog.BoolValue(v)
+	}
+	return slog.GroupValue()
+}
+
+// BoolFromLogValue returns the Bool that v, a structured logging
+// attribute value, records, reversing LogValue. A boolean is set, and
+// so are the strings "true" and "false", as a text log's attributes
+// are parsed. Any other value, including a null, an empty group or a
+// missing attribute's zero Value, is unset.
+func BoolFromLogValue(v slog.Value) Bool {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindBool:
+		var b Bool
+		b.Set(v.Bool())
+		return b
+	case slog.KindString:
+		switch s := v.String(); s {
+		case "true", "false":
+			return Bool(s)
+		}
+	}
+	return "unset"
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package opt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// recordHandler is a slog.Handler that keeps the attributes of the
+// last record it handled.
+type recordHandler struct {
+	attrs map[string]slog.Value
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.attrs = map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		h.attrs[a.Key] = a.Value
+		return true
+	})
+	return nil
+}
+
+func TestBoolLogValue(t *testing.T) {
+	tests := []struct {
+		in       Bool
+		want     Bool
+		wantJSON string // value of "x", or "" if absent
+	}{
+		{"true", "true", "true"},
+		{"false", "false", "false"},
+		{"", "unset", ""},
+		{"unset", "unset", ""},
+	}
+	for _, tt := range tests {
+		var h recordHandler
+		slog.New(&h).Info("msg", "x", tt.in)
+		if got := BoolFromLogValue(h.attrs["x"]); got != tt.want {
+			t.Errorf("%q: parsed back as %q; want %q", tt.in, got, tt.want)
+		}
+
+		var buf bytes.Buffer
+		slog.New(slog.NewJSONHandler(&buf, nil)).Info("msg", "x", tt.in)
+		var rec map[string]json.RawMessage
+		if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+			t.Fatalf("%q: invalid JSON %s: %v", tt.in, buf.Bytes(), err)
+		}
+		if got := string(rec["x"]); got != tt.wantJSON {
+			t.Errorf("%q: JSON x = %q; want %q (in %s)", tt.in, got, tt.wantJSON, buf.Bytes())
+		}
+	}
+}
+
+func TestBoolFromLogValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   slog.Value
+		want Bool
+	}{
+		{"bool_true", slog.BoolValue(true), "true"},
+		{"bool_false", slog.BoolValue(false), "false"},
+		{"string_true", slog.StringValue("true"), "true"},
+		{"string_false", slog.StringValue("false"), "false"},
+		{"string_other", slog.StringValue("yes"), "unset"},
+		{"missing", slog.Value{}, "unset"},
+		{"null", slog.AnyValue(nil), "unset"},
+		{"empty_group", slog.GroupValue(), "unset"},
+		{"int", slog.IntValue(1), "unset"},
+		{"valuer", slog.AnyValue(Bool("false")), "false"},
+		{"valuer_unset", slog.AnyValue(Bool("unset")), "unset"},
+	}
+	for _, tt := range tests {
+		if got := BoolFromLogValue(tt.in); got != tt.want {
+			t.Errorf("%s: got %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "reflect"
+
+// SparseDiff returns the Bool fields that differ between old and new,
+// structs (or pointers to structs) of the same type, keyed by path as
+// in SnapshotBools. Each value is new's: a pointer to its boolean if
+// it's set, or nil if it's unset. Unchanged fields are absent, so a
+// receiver can tell "changed to unset" (present with a nil value, or
+// null in JSON) from "unchanged" (absent).
+//
+// Fields are compared by logical state, so "" and "unset" are the
+// same. If old and new aren't structs of the same type, it returns
+// nil.
+func SparseDiff(old, new any) map[string]*bool {
+	ov, nv := reflect.Indirect(reflect.ValueOf(old)), reflect.Indirect(reflect.ValueOf(new))
+	if ov.Kind() != reflect.Struct || ov.Type() != nv.Type() {
+		return nil
+	}
+	before, after := SnapshotBools(old).vals, SnapshotBools(new).vals
+	ret := map[string]*bool{}
+	for path, b := range after {
+		if before[path] != b {
+			ret[path] = boolPtr(b)
+		}
+	}
+	for path, b := range before {
+		if _, ok := after[path]; !ok && b.IsSet() {
+			ret[path] = nil
+		}
+	}
+	return ret
+}
+
+// boolPtr returns a pointer to b's value, or nil if it's unset.
+func boolPtr(b Bool) *bool {
+	v, ok := b.Get()
+	if !ok {
+		return nil
+	}
+	return &v
+}
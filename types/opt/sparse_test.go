@@ -0,0 +1,63 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSparseDiff(t *testing.T) {
+	type netPrefs struct {
+		RouteAll Bool
+	}
+	type prefs struct {
+		ShieldsUp Bool
+		Exit      Bool
+		Corp      Bool
+		Same      Bool
+		Respelled Bool
+		Hostname  string
+		Net       netPrefs
+		Advertise *netPrefs
+	}
+	old := prefs{
+		ShieldsUp: "false",
+		Exit:      "true",
+		Same:      "true",
+		Respelled: "",
+		Hostname:  "a",
+		Advertise: &netPrefs{RouteAll: "true"},
+	}
+	new := prefs{
+		ShieldsUp: "true",
+		Exit:      "unset",
+		Corp:      "false",
+		Same:      "true",
+		Respelled: "unset",
+		Hostname:  "b",
+		Net:       netPrefs{RouteAll: "true"},
+	}
+	got := SparseDiff(&old, new)
+	// Encoded as JSON, which sorts the keys and shows nil as null.
+	j, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"Advertise.RouteAll":null,"Corp":false,"Exit":null,"Net.RouteAll":true,"ShieldsUp":true}`
+	if string(j) != want {
+		t.Errorf("got %s\nwant %s", j, want)
+	}
+
+	if got := SparseDiff(old, old); got == nil || len(got) != 0 {
+		t.Errorf("no change: got %v; want empty map", got)
+	}
+	if got := SparseDiff(old, netPrefs{}); got != nil {
+		t.Errorf("different types: got %v; want nil", got)
+	}
+	if got := SparseDiff(1, 2); got != nil {
+		t.Errorf("non-structs: got %v; want nil", got)
+	}
+}
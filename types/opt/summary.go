@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// SummarizeBools returns a line for each Bool field of v, a struct or
+// pointer to one, like "ShieldsUp: enabled", "RouteAll: disabled" or
+// "Exit: unset", sorted by name, for dumping preferences into support
+// bundles and bug reports. An invalid value is shown quoted, like
+// `Exit: invalid "yes"`.
+//
+// It finds Bools as ValidateBools does, and names them by the same
+// paths, like "Prefs.RouteAll" or "Peers[2].Exit". It returns nil if
+// v isn't a struct.
+func SummarizeBools(v any) []string {
+	rv := reflect.ValueOf(v)
+	if reflect.Indirect(rv).Kind() != reflect.Struct {
+		return nil
+	}
+	var lines []string
+	walkBools(rv, func(path []boolStep, v reflect.Value) {
+		b := v.Interface().(Bool)
+		var state string
+		switch x, ok := b.Get(); {
+		case ok:
+			state = explainBool(x)
+		case b == "" || b == "unset":
+			state = "unset"
+		default:
+			state = fmt.Sprintf("invalid %q", string(b))
+		}
+		lines = append(lines, boolPath(path)+": "+state)
+	})
+	sort.Strings(lines)
+	return lines
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeBools(t *testing.T) {
+	type netPrefs struct {
+		RouteAll Bool
+		MTU      int
+	}
+	type prefs struct {
+		ShieldsUp  Bool
+		Net        netPrefs
+		Advertise  *netPrefs
+		Missing    *netPrefs
+		Hostname   string
+		Exit       Bool
+		Corrupt    Bool
+		unexported Bool
+		Flags      []Bool
+	}
+	p := &prefs{
+		ShieldsUp:  "true",
+		Net:        netPrefs{RouteAll: "false"},
+		Advertise:  &netPrefs{RouteAll: "unset"},
+		Corrupt:    "yes",
+		unexported: "true",
+		Flags:      []Bool{"true"},
+	}
+	want := []string{
+		`Advertise.RouteAll: unset`,
+		`Corrupt: invalid "yes"`,
+		`Exit: unset`,
+		`Flags[0]: enabled`,
+		`Net.RouteAll: disabled`,
+		`ShieldsUp: enabled`,
+	}
+	if got := SummarizeBools(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+	if got := SummarizeBools(*p); !reflect.DeepEqual(got, want) {
+		t.Errorf("by value, got %q\nwant %q", got, want)
+	}
+
+	for _, v := range []any{nil, 1, Bool("true"), (*prefs)(nil)} {
+		if got := SummarizeBools(v); got != nil {
+			t.Errorf("SummarizeBools(%#v) = %q; want nil", v, got)
+		}
+	}
+}
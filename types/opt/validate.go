@@ -0,0 +1,31 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import "reflect"
+
+// ValidateBools returns the paths of the Bools within v, a struct or
+// pointer to one, whose backing values aren't valid (see Bool.Valid),
+// so that a bad assignment like Bool("yes") is caught before it fails
+// to marshal. It returns nil if they're all valid or v isn't a struct.
+//
+// It checks exported fields, recursing through structs, non-nil
+// pointers, slices, arrays and map values, and stopping at pointer
+// cycles. Paths are dot-separated field names with indexes and map
+// keys in brackets, as in "Prefs.RouteAll", "Peers[2].Exit" or
+// "Features[ssh]". Map entries are reported in key order.
+func ValidateBools(v any) []string {
+	rv := reflect.ValueOf(v)
+	if reflect.Indirect(rv).Kind() != reflect.Struct {
+		return nil
+	}
+	var bad []string
+	walkBools(rv, func(path []boolStep, b reflect.Value) {
+		if !b.Interface().(Bool).Valid() {
+			bad = append(bad, boolPath(path))
+		}
+	})
+	return bad
+}
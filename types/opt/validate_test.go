@@ -0,0 +1,72 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateBools(t *testing.T) {
+	type peer struct {
+		Name string
+		Exit Bool
+	}
+	type config struct {
+		RouteAll  Bool
+		ShieldsUp Bool
+		Prefs     struct {
+			SSH  Bool
+			Auto *Bool
+		}
+		Peers    []peer
+		Primary  *peer
+		Features map[string]Bool
+		Fixed    [2]Bool
+		internal Bool
+	}
+	yes := Bool("yes")
+
+	var good config
+	good.RouteAll, good.ShieldsUp = "true", "unset"
+	good.Peers = []peer{{"a", "false"}, {"b", ""}}
+	good.Features = map[string]Bool{"ssh": "true"}
+	good.internal = "bogus" // unexported fields aren't checked
+	if got := ValidateBools(&good); got != nil {
+		t.Errorf("valid config: got %q; want nil", got)
+	}
+
+	bad := good
+	bad.ShieldsUp = "yes"
+	bad.Prefs.SSH = "TRUE"
+	bad.Prefs.Auto = &yes
+	bad.Peers = []peer{{"a", "false"}, {"b", "1"}}
+	bad.Primary = &peer{"c", "on"}
+	bad.Features = map[string]Bool{"ssh": "maybe", "exit": "no", "ok": "false"}
+	bad.Fixed[1] = "?"
+	want := []string{
+		"ShieldsUp",
+		"Prefs.SSH",
+		"Prefs.Auto",
+		"Peers[1].Exit",
+		"Primary.Exit",
+		"Features[exit]",
+		"Features[ssh]",
+		"Fixed[1]",
+	}
+	if got := ValidateBools(&bad); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if got := ValidateBools(bad); !reflect.DeepEqual(got, want) {
+		t.Errorf("non-pointer: got %q; want %q", got, want)
+	}
+
+	if got := ValidateBools(yes); got != nil {
+		t.Errorf("non-struct: got %q; want nil", got)
+	}
+	if got := ValidateBools((*config)(nil)); got != nil {
+		t.Errorf("nil pointer: got %q; want nil", got)
+	}
+}
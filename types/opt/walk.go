@@ -0,0 +1,121 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var boolType = reflect.TypeOf(Bool(""))
+
+// boolStep is one step on the path from the value walkBools starts at
+// to a Bool within it: a struct field, or an element of a slice, array
+// or map.
+type boolStep struct {
+	field *reflect.StructField // nil for an element
+	elem  string               // the index or map key, for an element
+}
+
+// walkBools calls fn with the path to, and the value of, each Bool
+// within v. It walks exported struct fields, non-nil pointers, slices,
+// arrays and map values, the last in key order. Values reached through
+// struct fields and pointers are settable if v is.
+//
+// A pointer, slice or map that's already being walked further up the
+// path isn't walked again, so a cyclic structure doesn't recurse
+// forever. One that's shared by two fields without a cycle is walked
+// once for each.
+//
+// The path passed to fn is only valid until it returns.
+func walkBools(v reflect.Value, fn func(path []boolStep, b reflect.Value)) {
+	w := &boolWalker{fn: fn, active: map[walkKey]bool{}}
+	w.walk(v)
+}
+
+type boolWalker struct {
+	fn     func([]boolStep, reflect.Value)
+	path   []boolStep
+	active map[walkKey]bool // pointers, slices and maps on the path
+}
+
+// walkKey identifies a pointer, slice or map being walked. The type is
+// included because a struct and its first field have the same address.
+type walkKey struct {
+	p   uintptr
+	t   reflect.Type
+	len int // for slices, which can share a backing array
+}
+
+func (w *boolWalker) walk(v reflect.Value) {
+	if v.Type() == boolType {
+		w.fn(w.path, v)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		k := walkKey{p: v.Pointer(), t: v.Type()}
+		if v.Kind() == reflect.Slice {
+			k.len = v.Len()
+		}
+		if w.active[k] {
+			return
+		}
+		w.active[k] = true
+		defer delete(w.active, k)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() {
+				w.step(boolStep{field: &f}, v.Field(i))
+			}
+		}
+	case reflect.Pointer:
+		w.walk(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			w.step(boolStep{elem: strconv.Itoa(i)}, v.Index(i))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, k := range keys {
+			w.step(boolStep{elem: fmt.Sprint(k)}, v.MapIndex(k))
+		}
+	}
+}
+
+func (w *boolWalker) step(s boolStep, v reflect.Value) {
+	w.path = append(w.path, s)
+	w.walk(v)
+	w.path = w.path[:len(w.path)-1]
+}
+
+// boolPath formats path as dot-separated field names with indexes and
+// map keys in brackets, as in "Prefs.RouteAll" or "Peers[2].Exit".
+func boolPath(path []boolStep) string {
+	var sb strings.Builder
+	for _, s := range path {
+		if s.field == nil {
+			sb.WriteString("[" + s.elem + "]")
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(s.field.Name)
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2022 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWalkBools(t *testing.T) {
+	type node struct {
+		On       Bool
+		Next     *node
+		Children []node
+		ByName   map[string]*node
+	}
+	shared := &node{On: "false"}
+	root := &node{On: "true", ByName: map[string]*node{"a": shared, "b": shared}}
+	root.Next = root                          // pointer cycle
+	root.Children = []node{{On: "unset"}}     // slice cycle:
+	root.Children[0].Children = root.Children // Children[0].Children is Children
+	root.ByName["self"] = root                // pointer cycle through a map
+
+	var got []string
+	walkBools(reflect.ValueOf(root), func(path []boolStep, b reflect.Value) {
+		got = append(got, boolPath(path)+"="+string(b.Interface().(Bool)))
+	})
+	want := []string{
+		"On=true",
+		"Children[0].On=unset",
+		"ByName[a].On=false",
+		"ByName[b].On=false", // shared, but not a cycle
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	// The exported walkers all stop at the cycle too.
+	root.On = "bogus"
+	if got, want := ValidateBools(root), []string{"On"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateBools = %q; want %q", got, want)
+	}
+	if got := len(SummarizeBools(root)); got != 4 {
+		t.Errorf("SummarizeBools returned %d lines; want 4", got)
+	}
+	if got := SnapshotBools(root).Changed(root); len(got) != 0 {
+		t.Errorf("Changed = %q; want none", got)
+	}
+	t.Setenv("TS_ON", "false")
+	if err := ApplyEnvOverrides(root, "TS_"); err != nil {
+		t.Fatal(err)
+	}
+	if root.On != "false" {
+		t.Errorf("after ApplyEnvOverrides, On = %q; want false", root.On)
+	}
+}
+
+func TestBoolPath(t *testing.T) {
+	f := func(name string) boolStep {
+		return boolStep{field: &reflect.StructField{Name: name}}
+	}
+	e := func(elem string) boolStep { return boolStep{elem: elem} }
+	tests := []struct {
+		path []boolStep
+		want string
+	}{
+		{nil, ""},
+		{[]boolStep{f("Exit")}, "Exit"},
+		{[]boolStep{f("Prefs"), f("RouteAll")}, "Prefs.RouteAll"},
+		{[]boolStep{f("Peers"), e("2"), f("Exit")}, "Peers[2].Exit"},
+		{[]boolStep{f("Features"), e("ssh")}, "Features[ssh]"},
+	}
+	for _, tt := range tests {
+		if got := boolPath(tt.path); got != tt.want {
+			t.Errorf("boolPath = %q; want %q", got, tt.want)
+		}
+	}
+}
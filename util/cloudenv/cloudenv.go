@@ -82,6 +82,12 @@ func Get() Cloud {
 	return c
 }
 
+// Cached returns the cloud found by an earlier call to Get, and
+// whether there was one, without doing any lookup itself.
+func Cached() (c Cloud, ok bool) {
+	return cloudAtomic.LoadOk()
+}
+
 func readFileTrimmed(name string) string {
 	v, _ := os.ReadFile(name)
 	return strings.TrimSpace(string(v))
@@ -123,14 +129,20 @@ func getCloud() Cloud {
 	}
 
 	const maxWait = 2 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), maxWait)
+	defer cancel()
+	return ProbeMetadata(ctx)
+}
+
+// ProbeMetadata asks the link-local metadata server which cloud we're
+// on, returning the empty string if there's no metadata server or it's
+// not one we recognize. Unlike Get, it always hits the network, so
+// callers should bound it with ctx.
+func ProbeMetadata(ctx context.Context) Cloud {
 	tr := &http.Transport{
 		DisableKeepAlives: true,
-		Dial: (&net.Dialer{
-			Timeout: maxWait,
-		}).Dial,
+		DialContext:       new(net.Dialer).DialContext,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), maxWait)
-	defer cancel()
 
 	// We want to hit CommonNonRoutableMetadataIP to see if we're on AWS, GCP,
 	// or Azure. All three (and many others) use the same metadata IP.